@@ -1,27 +1,106 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"time"
+
 	"prutya/go-api-template/internal/app"
+	"prutya/go-api-template/internal/probe_server"
+	"prutya/go-api-template/internal/redis_client"
 	"prutya/go-api-template/internal/tasks_scheduler"
 )
 
+type schedulesResponse struct {
+	Leader    bool                            `json:"leader"`
+	Schedules []*tasks_scheduler.ScheduleInfo `json:"schedules"`
+}
+
 func main() {
 	appEssentials := app.NewAppEssentials()
 	ctx, cfg, logger := appEssentials.Context, appEssentials.Config, appEssentials.Logger
 
-	// Tasks server
+	// `scheduler schedules` prints the schedules with their next run times
+	if len(os.Args) > 1 && os.Args[1] == "schedules" {
+		schedules, err := tasks_scheduler.ListSchedules(
+			cfg.TasksSchedules,
+			cfg.AuthenticationEmailBlocklistSyncURL,
+			cfg.AuthenticationEmailBlocklistSyncSchedule,
+			time.Now(),
+		)
+		if err != nil {
+			logger.FatalContext(ctx, "Failed to list schedules", "error", err)
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		if err := encoder.Encode(schedules); err != nil {
+			logger.FatalContext(ctx, "Failed to print schedules", "error", err)
+		}
+
+		return
+	}
+
+	redisClient, err := redis_client.New(ctx, cfg.TasksRedisAddr, cfg.Secret("tasks_redis_password").Value)
+	if err != nil {
+		logger.FatalContext(ctx, "Failed to connect to Redis", "error", err)
+	}
+	defer redisClient.Close()
+
+	// Tasks scheduler
 	scheduler, err := tasks_scheduler.NewScheduler(
 		ctx,
-		cfg.TasksRedisAddr,
-		cfg.TasksRedisPassword,
+		cfg.TasksSchedules,
+		cfg.TasksSchedulerLeaderLockTTL,
+		cfg.AuthenticationEmailBlocklistSyncURL,
+		cfg.AuthenticationEmailBlocklistSyncSchedule,
+		redisClient,
 	)
 	if err != nil {
 		logger.FatalContext(ctx, "Failed to create scheduler", "error", err)
 	}
 
+	// Health probes. The scheduler doesn't use the database.
+	if cfg.SchedulerProbeListenAddr != "" {
+		probeServer := probe_server.NewProbeServer(
+			cfg.SchedulerProbeListenAddr,
+			cfg.ProbeCheckTimeout,
+			cfg.ProbeMetricsEnabled,
+			map[string]probe_server.Check{
+				"redis": func(ctx context.Context) error {
+					return scheduler.Ping(ctx)
+				},
+			},
+			logger,
+		)
+
+		probeServer.Handle("GET /schedules", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+
+			json.NewEncoder(w).Encode(&schedulesResponse{
+				Leader:    scheduler.IsLeader(),
+				Schedules: scheduler.Schedules(time.Now()),
+			})
+		}))
+
+		probeServer.Start()
+		defer probeServer.Shutdown(context.Background())
+	}
+
 	if err := scheduler.Run(); err != nil {
 		logger.FatalContext(ctx, "Scheduler start error", "error", err)
 	}
 
+	// Export the remaining spans
+	tracingCtx, cancel := context.WithTimeout(ctx, appEssentials.Config.ShutdownTimeout)
+	defer cancel()
+
+	if err := appEssentials.ShutdownTracing(tracingCtx); err != nil {
+		logger.ErrorContext(ctx, "Failed to shut down tracing", "error", err)
+	}
+
 	logger.InfoContext(ctx, "Bye!")
 }
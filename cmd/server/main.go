@@ -1,24 +1,46 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
 	"prutya/go-api-template/internal/app"
+	"prutya/go-api-template/internal/config"
 	"prutya/go-api-template/internal/server"
 )
 
 func main() {
+	// `server config check` prints the effective config with the secrets masked
+	// and all of its problems
+	if len(os.Args) > 2 && os.Args[1] == "config" && os.Args[2] == "check" {
+		os.Exit(checkConfig())
+	}
+
 	app := app.NewApp()
 	cfg, ctx, logger := app.Essentials.Config, app.Essentials.Context, app.Essentials.Logger
 
+	// Pick up blocklist changes made by the sync task or by hand
+	if err := app.EmailBlocklistService.Watch(ctx); err != nil {
+		logger.FatalContext(ctx, "Failed to watch the email blocklist", "error", err)
+	}
+
 	server := server.NewServer(
 		cfg,
 		server.NewRouter(
 			cfg,
+			app.Essentials.ConfigWatcher,
 			logger,
 			app.AuthenticationService,
 			app.UserService,
 			app.TransactionalEmailService,
 			app.CaptchaService,
+			app.RiskService,
+			app.FailedTaskService,
+			app.Readiness,
 		),
+		app.Readiness,
 		logger,
 	)
 
@@ -26,5 +48,41 @@ func main() {
 		logger.FatalContext(ctx, "Server error", "error", err)
 	}
 
+	// Export the remaining spans
+	tracingCtx, cancel := context.WithTimeout(ctx, app.Essentials.Config.ShutdownTimeout)
+	defer cancel()
+
+	if err := app.Essentials.ShutdownTracing(tracingCtx); err != nil {
+		logger.ErrorContext(ctx, "Failed to shut down tracing", "error", err)
+	}
+
 	logger.InfoContext(ctx, "Bye!")
 }
+
+func checkConfig() int {
+	cfg, err := config.Read()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+
+		return 1
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(cfg.Masked()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+
+		return 1
+	}
+
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+
+		return 1
+	}
+
+	fmt.Fprintln(os.Stderr, "config is valid")
+
+	return 0
+}
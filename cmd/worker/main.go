@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
+
 	"prutya/go-api-template/internal/app"
+	"prutya/go-api-template/internal/probe_server"
 	"prutya/go-api-template/internal/tasks_server"
 )
 
@@ -10,17 +13,57 @@ func main() {
 	ctx, cfg, logger := app.Essentials.Context, app.Essentials.Config, app.Essentials.Logger
 
 	// Tasks server
-	tasksServer := tasks_server.NewServer(
+	tasksServer, err := tasks_server.NewServer(
 		ctx,
 		cfg.TasksRedisAddr,
-		cfg.TasksRedisPassword,
+		cfg.Secret("tasks_redis_password").Value,
+		cfg.TasksConcurrency,
+		cfg.TasksQueues,
+		cfg.TasksStrictPriority,
+		cfg.TasksShutdownTimeout,
 		app.AuthenticationService,
 		app.TransactionalEmailService,
+		app.EmailBlocklistService,
+		app.FailedTaskService,
 	)
+	if err != nil {
+		logger.FatalContext(ctx, "Failed to create worker", "error", err)
+	}
+
+	// Health probes
+	if cfg.WorkerProbeListenAddr != "" {
+		probeServer := probe_server.NewProbeServer(
+			cfg.WorkerProbeListenAddr,
+			cfg.ProbeCheckTimeout,
+			cfg.ProbeMetricsEnabled,
+			map[string]probe_server.Check{
+				"db": func(ctx context.Context) error {
+					_, err := app.DB.ExecContext(ctx, "SELECT 1")
+					return err
+				},
+				// The tasks queue is on the same Redis instance
+				"redis": func(ctx context.Context) error {
+					return app.RedisClient.Ping(ctx).Err()
+				},
+			},
+			logger,
+		)
+
+		probeServer.Start()
+		defer probeServer.Shutdown(context.Background())
+	}
 
 	if err := tasksServer.Run(); err != nil {
 		logger.FatalContext(ctx, "Worker start error", "error", err)
 	}
 
+	// Export the remaining spans
+	tracingCtx, cancel := context.WithTimeout(ctx, app.Essentials.Config.ShutdownTimeout)
+	defer cancel()
+
+	if err := app.Essentials.ShutdownTracing(tracingCtx); err != nil {
+		logger.ErrorContext(ctx, "Failed to shut down tracing", "error", err)
+	}
+
 	logger.InfoContext(ctx, "Bye!")
 }
@@ -0,0 +1,43 @@
+-- migrate:up
+
+create table email_messages (
+  id uuid primary key default gen_random_uuid(),
+  user_id uuid references users(id) on update cascade on delete set null,
+  recipient text not null,
+  template text not null,
+  provider_message_id text,
+  status text not null default 'queued',
+  status_details text,
+  status_updated_at timestamptz not null default now(),
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now(),
+
+  constraint email_messages_status_check check (
+    status in ('queued', 'sent', 'failed', 'delivered', 'bounced', 'complained')
+  )
+);
+
+create unique index email_messages_provider_message_id_unique_idx on email_messages (provider_message_id);
+create index email_messages_user_id_idx on email_messages (user_id);
+create index email_messages_recipient_idx on email_messages (lower(recipient));
+
+-- Addresses that hard-bounced or complained. We never send to them again.
+create table email_suppressions (
+  id uuid primary key default gen_random_uuid(),
+  email text not null,
+  reason text not null,
+  email_message_id uuid references email_messages(id) on update cascade on delete set null,
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now(),
+
+  constraint email_suppressions_reason_check check (
+    reason in ('hard_bounce', 'complaint')
+  )
+);
+
+-- Unique case-insensitive email
+create unique index email_suppressions_email_unique_idx on email_suppressions (lower(email));
+
+-- migrate:down
+drop table email_suppressions;
+drop table email_messages;
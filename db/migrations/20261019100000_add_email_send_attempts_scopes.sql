@@ -0,0 +1,33 @@
+-- migrate:up
+
+alter table email_send_attempts
+  add column budget text not null default 'non_critical',
+  add column recipient text,
+  add column recipient_domain text,
+  add column requester_ip text,
+  add column user_id uuid,
+  add constraint email_send_attempts_budget_check check (
+    budget in ('critical', 'non_critical')
+  );
+
+-- Indexes for the sliding window counts of every rate limit scope
+create index email_send_attempts_budget_attempted_at_idx on email_send_attempts (budget, attempted_at);
+create index email_send_attempts_recipient_idx on email_send_attempts (recipient, attempted_at);
+create index email_send_attempts_recipient_domain_idx on email_send_attempts (recipient_domain, attempted_at);
+create index email_send_attempts_requester_ip_idx on email_send_attempts (requester_ip, attempted_at);
+create index email_send_attempts_user_id_idx on email_send_attempts (user_id, attempted_at);
+
+-- migrate:down
+drop index email_send_attempts_user_id_idx;
+drop index email_send_attempts_requester_ip_idx;
+drop index email_send_attempts_recipient_domain_idx;
+drop index email_send_attempts_recipient_idx;
+drop index email_send_attempts_budget_attempted_at_idx;
+
+alter table email_send_attempts
+  drop constraint email_send_attempts_budget_check,
+  drop column user_id,
+  drop column requester_ip,
+  drop column recipient_domain,
+  drop column recipient,
+  drop column budget;
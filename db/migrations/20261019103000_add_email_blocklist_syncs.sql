@@ -0,0 +1,16 @@
+-- migrate:up
+
+-- The blocklist downloaded by the sync task. It's stored in the database
+-- rather than on disk, so that every process picks it up, not only the worker
+-- that ran the sync. There's at most one row.
+create table email_blocklist_syncs (
+  id smallint primary key default 1 check (id = 1),
+  -- One domain per line
+  domains text not null,
+  synced_at timestamptz not null default now(),
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now()
+);
+
+-- migrate:down
+drop table email_blocklist_syncs;
@@ -0,0 +1,25 @@
+-- migrate:up
+
+-- Background tasks that exhausted their retries
+create table failed_tasks (
+  id uuid primary key default gen_random_uuid(),
+  task_id text not null,
+  task_type text not null,
+  queue text not null,
+  payload bytea,
+  error text not null,
+  -- Messages of the wrapped errors, outermost first
+  error_chain text[] not null default '{}',
+  attempts integer not null,
+  max_retry integer not null,
+  failed_at timestamptz not null default now(),
+  retried_at timestamptz,
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now()
+);
+
+create index failed_tasks_task_type_idx on failed_tasks (task_type, id);
+create index failed_tasks_task_id_idx on failed_tasks (task_id);
+
+-- migrate:down
+drop table failed_tasks;
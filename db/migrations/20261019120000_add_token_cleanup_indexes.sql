@@ -0,0 +1,13 @@
+-- migrate:up
+
+-- Used by the periodic cleanup of expired tokens and ended sessions
+create index access_tokens_expires_at_idx on access_tokens (expires_at);
+create index refresh_tokens_expires_at_idx on refresh_tokens (expires_at);
+create index sessions_expires_at_idx on sessions (expires_at);
+create index sessions_terminated_at_idx on sessions (terminated_at) where terminated_at is not null;
+
+-- migrate:down
+drop index sessions_terminated_at_idx;
+drop index sessions_expires_at_idx;
+drop index refresh_tokens_expires_at_idx;
+drop index access_tokens_expires_at_idx;
@@ -51,13 +51,52 @@ CREATE TABLE public.access_tokens (
 );
 
 
+--
+-- Name: email_blocklist_syncs; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.email_blocklist_syncs (
+    id smallint DEFAULT 1 NOT NULL,
+    domains text NOT NULL,
+    synced_at timestamp with time zone DEFAULT now() NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT email_blocklist_syncs_id_check CHECK ((id = 1))
+);
+
+
+--
+-- Name: email_messages; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.email_messages (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    user_id uuid,
+    recipient text NOT NULL,
+    template text NOT NULL,
+    provider_message_id text,
+    status text DEFAULT 'queued'::text NOT NULL,
+    status_details text,
+    status_updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT email_messages_status_check CHECK ((status = ANY (ARRAY['queued'::text, 'sent'::text, 'failed'::text, 'delivered'::text, 'bounced'::text, 'complained'::text])))
+);
+
+
 --
 -- Name: email_send_attempts; Type: TABLE; Schema: public; Owner: -
 --
 
 CREATE TABLE public.email_send_attempts (
     id integer NOT NULL,
-    attempted_at timestamp with time zone DEFAULT now() NOT NULL
+    attempted_at timestamp with time zone DEFAULT now() NOT NULL,
+    budget text DEFAULT 'non_critical'::text NOT NULL,
+    recipient text,
+    recipient_domain text,
+    requester_ip text,
+    user_id uuid,
+    CONSTRAINT email_send_attempts_budget_check CHECK ((budget = ANY (ARRAY['critical'::text, 'non_critical'::text])))
 );
 
 
@@ -81,6 +120,42 @@ CREATE SEQUENCE public.email_send_attempts_id_seq
 ALTER SEQUENCE public.email_send_attempts_id_seq OWNED BY public.email_send_attempts.id;
 
 
+--
+-- Name: email_suppressions; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.email_suppressions (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    email text NOT NULL,
+    reason text NOT NULL,
+    email_message_id uuid,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT email_suppressions_reason_check CHECK ((reason = ANY (ARRAY['hard_bounce'::text, 'complaint'::text])))
+);
+
+
+--
+-- Name: failed_tasks; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.failed_tasks (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    task_id text NOT NULL,
+    task_type text NOT NULL,
+    queue text NOT NULL,
+    payload bytea,
+    error text NOT NULL,
+    error_chain text[] DEFAULT '{}'::text[] NOT NULL,
+    attempts integer NOT NULL,
+    max_retry integer NOT NULL,
+    failed_at timestamp with time zone DEFAULT now() NOT NULL,
+    retried_at timestamp with time zone,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
 --
 -- Name: refresh_tokens; Type: TABLE; Schema: public; Owner: -
 --
@@ -164,6 +239,22 @@ ALTER TABLE ONLY public.access_tokens
     ADD CONSTRAINT access_tokens_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: email_blocklist_syncs email_blocklist_syncs_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.email_blocklist_syncs
+    ADD CONSTRAINT email_blocklist_syncs_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: email_messages email_messages_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.email_messages
+    ADD CONSTRAINT email_messages_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: email_send_attempts email_send_attempts_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -172,6 +263,22 @@ ALTER TABLE ONLY public.email_send_attempts
     ADD CONSTRAINT email_send_attempts_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: email_suppressions email_suppressions_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.email_suppressions
+    ADD CONSTRAINT email_suppressions_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: failed_tasks failed_tasks_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.failed_tasks
+    ADD CONSTRAINT failed_tasks_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: refresh_tokens refresh_tokens_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -204,6 +311,13 @@ ALTER TABLE ONLY public.users
     ADD CONSTRAINT users_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: access_tokens_expires_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX access_tokens_expires_at_idx ON public.access_tokens USING btree (expires_at);
+
+
 --
 -- Name: access_tokens_refresh_token_id_idx; Type: INDEX; Schema: public; Owner: -
 --
@@ -211,6 +325,27 @@ ALTER TABLE ONLY public.users
 CREATE INDEX access_tokens_refresh_token_id_idx ON public.access_tokens USING btree (refresh_token_id);
 
 
+--
+-- Name: email_messages_provider_message_id_unique_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE UNIQUE INDEX email_messages_provider_message_id_unique_idx ON public.email_messages USING btree (provider_message_id);
+
+
+--
+-- Name: email_messages_recipient_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX email_messages_recipient_idx ON public.email_messages USING btree (lower(recipient));
+
+
+--
+-- Name: email_messages_user_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX email_messages_user_id_idx ON public.email_messages USING btree (user_id);
+
+
 --
 -- Name: email_send_attempts_attempted_at_idx; Type: INDEX; Schema: public; Owner: -
 --
@@ -218,6 +353,62 @@ CREATE INDEX access_tokens_refresh_token_id_idx ON public.access_tokens USING bt
 CREATE INDEX email_send_attempts_attempted_at_idx ON public.email_send_attempts USING btree (attempted_at);
 
 
+--
+-- Name: email_send_attempts_budget_attempted_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX email_send_attempts_budget_attempted_at_idx ON public.email_send_attempts USING btree (budget, attempted_at);
+
+
+--
+-- Name: email_send_attempts_recipient_domain_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX email_send_attempts_recipient_domain_idx ON public.email_send_attempts USING btree (recipient_domain, attempted_at);
+
+
+--
+-- Name: email_send_attempts_recipient_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX email_send_attempts_recipient_idx ON public.email_send_attempts USING btree (recipient, attempted_at);
+
+
+--
+-- Name: email_send_attempts_requester_ip_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX email_send_attempts_requester_ip_idx ON public.email_send_attempts USING btree (requester_ip, attempted_at);
+
+
+--
+-- Name: email_send_attempts_user_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX email_send_attempts_user_id_idx ON public.email_send_attempts USING btree (user_id, attempted_at);
+
+
+--
+-- Name: email_suppressions_email_unique_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE UNIQUE INDEX email_suppressions_email_unique_idx ON public.email_suppressions USING btree (lower(email));
+
+
+--
+-- Name: failed_tasks_task_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX failed_tasks_task_id_idx ON public.failed_tasks USING btree (task_id);
+
+
+--
+-- Name: failed_tasks_task_type_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX failed_tasks_task_type_idx ON public.failed_tasks USING btree (task_type, id);
+
+
 --
 -- Name: idx_sessions_user_id; Type: INDEX; Schema: public; Owner: -
 --
@@ -225,6 +416,13 @@ CREATE INDEX email_send_attempts_attempted_at_idx ON public.email_send_attempts
 CREATE INDEX idx_sessions_user_id ON public.sessions USING btree (user_id);
 
 
+--
+-- Name: refresh_tokens_expires_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX refresh_tokens_expires_at_idx ON public.refresh_tokens USING btree (expires_at);
+
+
 --
 -- Name: refresh_tokens_parent_id_idx; Type: INDEX; Schema: public; Owner: -
 --
@@ -239,6 +437,20 @@ CREATE INDEX refresh_tokens_parent_id_idx ON public.refresh_tokens USING btree (
 CREATE INDEX refresh_tokens_session_id_idx ON public.refresh_tokens USING btree (session_id);
 
 
+--
+-- Name: sessions_expires_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX sessions_expires_at_idx ON public.sessions USING btree (expires_at);
+
+
+--
+-- Name: sessions_terminated_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX sessions_terminated_at_idx ON public.sessions USING btree (terminated_at) WHERE (terminated_at IS NOT NULL);
+
+
 --
 -- Name: users_email_unique_idx; Type: INDEX; Schema: public; Owner: -
 --
@@ -254,6 +466,22 @@ ALTER TABLE ONLY public.access_tokens
     ADD CONSTRAINT access_tokens_refresh_token_id_fkey FOREIGN KEY (refresh_token_id) REFERENCES public.refresh_tokens(id) ON UPDATE CASCADE ON DELETE CASCADE;
 
 
+--
+-- Name: email_messages email_messages_user_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.email_messages
+    ADD CONSTRAINT email_messages_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON UPDATE CASCADE ON DELETE SET NULL;
+
+
+--
+-- Name: email_suppressions email_suppressions_email_message_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.email_suppressions
+    ADD CONSTRAINT email_suppressions_email_message_id_fkey FOREIGN KEY (email_message_id) REFERENCES public.email_messages(id) ON UPDATE CASCADE ON DELETE SET NULL;
+
+
 --
 -- Name: refresh_tokens refresh_tokens_parent_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
 --
@@ -311,6 +539,11 @@ SET row_security = off;
 
 INSERT INTO public.schema_migrations VALUES ('20240918213449');
 INSERT INTO public.schema_migrations VALUES ('20251116174456');
+INSERT INTO public.schema_migrations VALUES ('20261019090000');
+INSERT INTO public.schema_migrations VALUES ('20261019100000');
+INSERT INTO public.schema_migrations VALUES ('20261019103000');
+INSERT INTO public.schema_migrations VALUES ('20261019110000');
+INSERT INTO public.schema_migrations VALUES ('20261019120000');
 
 
 --
@@ -2,24 +2,42 @@ package app
 
 import (
 	"context"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"slices"
 
+	"github.com/redis/go-redis/v9"
 	"github.com/uptrace/bun"
 
 	"prutya/go-api-template/internal/config"
 	"prutya/go-api-template/internal/db"
+	"prutya/go-api-template/internal/health"
+	"prutya/go-api-template/internal/httpclient"
 	loggerpkg "prutya/go-api-template/internal/logger"
+	"prutya/go-api-template/internal/redis_client"
 	"prutya/go-api-template/internal/repo"
 	"prutya/go-api-template/internal/services/authentication_service"
 	"prutya/go-api-template/internal/services/captcha_service"
+	"prutya/go-api-template/internal/services/email_blocklist_service"
+	"prutya/go-api-template/internal/services/email_domain_validation_service"
+	"prutya/go-api-template/internal/services/failed_task_service"
+	"prutya/go-api-template/internal/services/risk_service"
 	"prutya/go-api-template/internal/services/transactional_email_service"
 	"prutya/go-api-template/internal/services/user_service"
 	"prutya/go-api-template/internal/tasks_client"
+	"prutya/go-api-template/internal/tracing"
 )
 
 type AppEssentials struct {
-	Config  *config.Config
-	Logger  *loggerpkg.Logger
-	Context context.Context
+	// The config at startup, see `ConfigWatcher` for the reloaded one
+	Config        *config.Config
+	ConfigWatcher *config.Watcher
+	Logger        *loggerpkg.Logger
+	Context       context.Context
+	// Must be called before the process exits to export the remaining spans
+	ShutdownTracing tracing.ShutdownFunc
 }
 
 type App struct {
@@ -29,34 +47,90 @@ type App struct {
 	RepoFactory repo.RepoFactory
 
 	TasksClient tasks_client.Client
+	RedisClient *redis.Client
 
 	TransactionalEmailService transactional_email_service.TransactionalEmailService
+	EmailBlocklistService     email_blocklist_service.EmailBlocklistService
 	CaptchaService            captcha_service.CaptchaService
+	RiskService               risk_service.RiskService
 	AuthenticationService     authentication_service.AuthenticationService
 	UserService               user_service.UserService
+	FailedTaskService         failed_task_service.FailedTaskService
+
+	Readiness *health.Readiness
 }
 
 func NewAppEssentials() *AppEssentials {
 	// Config
 	cfg, err := config.Load()
 	if err != nil {
-		panic(err)
+		// The logger depends on the config, so the problems go to stderr
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 
 	// Logger
-	logger, err := loggerpkg.New(cfg.LogLevel, cfg.LogFormat, cfg.LogShowCallerInDebug)
+	logSamplingRates := make(map[string]int, len(cfg.LogSampling))
+	for _, sampling := range cfg.LogSampling {
+		logSamplingRates[sampling.Message] = sampling.Rate
+	}
+
+	logger, err := loggerpkg.New(
+		cfg.LogLevel,
+		cfg.LogFormat,
+		cfg.LogShowCallerInDebug,
+		cfg.LogRedactedKeys,
+		cfg.LogComponentLevels,
+		logSamplingRates,
+	)
 	if err != nil {
 		panic(err)
 	}
 
+	// Lets the operators turn debug logging on and off without a restart
+	logger.ToggleDebugOnSignal()
+
 	// Context
 	ctx := context.Background()
 	ctx = loggerpkg.NewContext(ctx, logger)
 
+	// Tracing
+	shutdownTracing, err := tracing.Setup(
+		ctx,
+		cfg.TracingExporter,
+		cfg.TracingOTLPEndpoint,
+		cfg.TracingOTLPInsecure,
+		cfg.TracingServiceName,
+		cfg.TracingSampleRatio,
+	)
+	if err != nil {
+		logger.FatalContext(ctx, "Failed to set up tracing", "error", err)
+	}
+
+	// Picks up the secrets rotated in their files or secret stores
+	cfg.WatchSecrets(ctx)
+
+	// Hot reload of the config
+	configWatcher := config.NewWatcher(cfg)
+	configWatcher.Subscribe(func(ctx context.Context, previous *config.Config, current *config.Config) {
+		// Keeps the levels changed via the admin API or SIGUSR1 until the
+		// configured ones change
+		if current.LogLevel == previous.LogLevel && maps.Equal(current.LogComponentLevels, previous.LogComponentLevels) {
+			return
+		}
+
+		if err := logger.Levels().Configure(current.LogLevel, current.LogComponentLevels); err != nil {
+			logger.ErrorContext(ctx, "Failed to apply the reloaded log levels", "error", err)
+		}
+	})
+	configWatcher.Watch(ctx)
+
 	return &AppEssentials{
-		Config:  cfg,
-		Logger:  logger,
-		Context: ctx,
+		Config:          cfg,
+		ConfigWatcher:   configWatcher,
+		Logger:          logger,
+		Context:         ctx,
+		ShutdownTracing: shutdownTracing,
 	}
 }
 
@@ -66,7 +140,7 @@ func NewApp() *App {
 
 	// Database
 	db, err := db.New(
-		cfg.DatabaseUrl,
+		cfg.Secret("database_url").Value,
 		cfg.DatabaseMaxOpenConnections,
 		cfg.DatabaseMaxIdleConnections,
 		cfg.DatabaseMaxConnectionLifetime,
@@ -84,13 +158,40 @@ func NewApp() *App {
 	}
 
 	// Tasks client
-	tasksClient := tasks_client.NewClient(cfg.TasksRedisAddr, cfg.TasksRedisPassword)
+	tasksClient := tasks_client.NewClient(cfg.TasksRedisAddr, cfg.Secret("tasks_redis_password").Value)
 	if err := tasksClient.Ping(); err == nil {
 		logger.InfoContext(ctx, "Tasks client OK")
 	} else {
 		logger.FatalContext(ctx, "Failed to ping tasks client", "error", err)
 	}
 
+	// Redis client for the services, e.g. captcha replay protection
+	redisClient, err := redis_client.New(ctx, cfg.TasksRedisAddr, cfg.Secret("tasks_redis_password").Value)
+	if err == nil {
+		logger.InfoContext(ctx, "Redis OK")
+	} else {
+		logger.FatalContext(ctx, "Failed to connect to Redis", "error", err)
+	}
+
+	// Outbound HTTP clients configuration, every integration gets its own client
+	httpClientConfig := &httpclient.Config{
+		Timeout:                        cfg.HTTPClientTimeout,
+		DialTimeout:                    cfg.HTTPClientDialTimeout,
+		TLSHandshakeTimeout:            cfg.HTTPClientTLSHandshakeTimeout,
+		ResponseHeaderTimeout:          cfg.HTTPClientResponseHeaderTimeout,
+		IdleConnTimeout:                cfg.HTTPClientIdleConnTimeout,
+		MaxIdleConns:                   cfg.HTTPClientMaxIdleConns,
+		MaxIdleConnsPerHost:            cfg.HTTPClientMaxIdleConnsPerHost,
+		MaxConnsPerHost:                cfg.HTTPClientMaxConnsPerHost,
+		MaxRedirects:                   cfg.HTTPClientMaxRedirects,
+		MaxRetries:                     cfg.HTTPClientMaxRetries,
+		RetryBaseDelay:                 cfg.HTTPClientRetryBaseDelay,
+		RetryMaxDelay:                  cfg.HTTPClientRetryMaxDelay,
+		CircuitBreakerFailureThreshold: cfg.HTTPClientCircuitBreakerFailureThreshold,
+		CircuitBreakerOpenTimeout:      cfg.HTTPClientCircuitBreakerOpenTimeout,
+		TimeoutOverrides:               cfg.HTTPClientTimeoutOverrides,
+	}
+
 	// Repositories factory
 	repoFactory := repo.NewRepoFactory()
 
@@ -98,13 +199,17 @@ func NewApp() *App {
 	transactionalEmailService, err := transactional_email_service.NewTransactionalEmailService(
 		ctx,
 		cfg.TransactionalEmailsEnabled,
-		cfg.TransactionalEmailsDailyGlobalLimit,
+		cfg.TransactionalEmailsGlobalLimits,
+		cfg.TransactionalEmailsRateLimits,
 		cfg.TransactionalEmailsSenderEmail,
 		cfg.TransactionalEmailsSenderName,
+		cfg.Secret("transactional_emails_webhook_secret").Value,
+		cfg.TransactionalEmailsWebhookTolerance,
 		cfg.TransactionalEmailsScalewayAccessKeyID,
-		cfg.TransactionalEmailsScalewaySecretKey,
+		cfg.Secret("transactional_emails_scaleway_secret_key").Value,
 		cfg.TransactionalEmailsScalewayRegion,
 		cfg.TransactionalEmailsScalewayProjectID,
+		httpclient.New(httpclient.IntegrationScaleway, httpClientConfig),
 		db,
 		repoFactory,
 	)
@@ -112,12 +217,75 @@ func NewApp() *App {
 		logger.FatalContext(ctx, "Failed to create transactional email service", "error", err)
 	}
 
-	captchaService := captcha_service.NewCaptchaService(
+	emailBlocklistService, err := email_blocklist_service.NewEmailBlocklistService(
+		ctx,
+		cfg.AuthenticationEmailBlocklistPath,
+		cfg.AuthenticationEmailAllowlistPath,
+		cfg.AuthenticationEmailBlocklistSyncURL,
+		httpclient.New(httpclient.IntegrationEmailBlocklist, httpClientConfig),
+		db,
+		repoFactory,
+	)
+	if err != nil {
+		logger.FatalContext(ctx, "Failed to create email blocklist service", "error", err)
+	}
+
+	emailDomainValidationService := email_domain_validation_service.NewEmailDomainValidationService(
+		ctx,
+		cfg.AuthenticationEmailDomainValidationEnabled,
+		net.DefaultResolver,
+		cfg.AuthenticationEmailDomainValidationTimeout,
+		cfg.AuthenticationEmailDomainValidationCacheTTL,
+		cfg.AuthenticationEmailDomainValidationSuggestionDomains,
+	)
+
+	captchaService, err := captcha_service.NewCaptchaService(
 		ctx,
 		cfg.CaptchaEnabled,
-		cfg.CaptchaTurnstileBaseURL,
-		cfg.CaptchaTurnstileSecretKey,
+		cfg.CaptchaProvider,
+		&captcha_service.ProviderConfig{
+			TurnstileBaseURL:         cfg.CaptchaTurnstileBaseURL,
+			TurnstileSecretKey:       cfg.Secret("captcha_turnstile_secret_key").Value,
+			HCaptchaBaseURL:          cfg.CaptchaHCaptchaBaseURL,
+			HCaptchaSecretKey:        cfg.Secret("captcha_hcaptcha_secret_key").Value,
+			HCaptchaSiteKey:          cfg.CaptchaHCaptchaSiteKey,
+			RecaptchaBaseURL:         cfg.CaptchaRecaptchaBaseURL,
+			RecaptchaSecretKey:       cfg.Secret("captcha_recaptcha_secret_key").Value,
+			RecaptchaScoreThreshold:  cfg.CaptchaRecaptchaScoreThreshold,
+			RecaptchaScoreThresholds: cfg.CaptchaRecaptchaScoreThresholds,
+			ProofOfWorkSecret:        cfg.Secret("captcha_pow_secret").Value,
+			ProofOfWorkDifficulty:    cfg.CaptchaPowDifficulty,
+			ProofOfWorkChallengeTTL:  cfg.CaptchaPowChallengeTTL,
+			ProofOfWorkPassTTL:       cfg.CaptchaPowPassTTL,
+		},
+		cfg.CaptchaAllowedHostnames,
+		cfg.CaptchaTokenReplayTTL,
+		httpclient.New(httpclient.IntegrationCaptcha, httpClientConfig),
+		redisClient,
 	)
+	if err != nil {
+		logger.FatalContext(ctx, "Failed to create captcha service", "error", err)
+	}
+
+	riskService, err := risk_service.NewRiskService(
+		ctx,
+		cfg.CaptchaRiskEnabled,
+		cfg.CaptchaRiskThreshold,
+		cfg.CaptchaRiskFailureWindow,
+		cfg.CaptchaRiskKnownDeviceTTL,
+		&risk_service.Weights{
+			IPFailure:      cfg.CaptchaRiskIPFailureWeight,
+			AccountFailure: cfg.CaptchaRiskAccountFailureWeight,
+			NewDevice:      cfg.CaptchaRiskNewDeviceWeight,
+			BlockedNetwork: cfg.CaptchaRiskBlockedNetworkWeight,
+		},
+		cfg.CaptchaRiskBlockedNetworks,
+		cfg.CaptchaRiskBlockedASNs,
+		redisClient,
+	)
+	if err != nil {
+		logger.FatalContext(ctx, "Failed to create risk service", "error", err)
+	}
 
 	authenticationService := authentication_service.NewAuthenticationService(
 		cfg,
@@ -125,8 +293,55 @@ func NewApp() *App {
 		repoFactory,
 		tasksClient,
 		transactionalEmailService,
+		emailBlocklistService,
+		emailDomainValidationService,
 	)
 	userService := user_service.NewUserService(db, repoFactory)
+	failedTaskService := failed_task_service.NewFailedTaskService(
+		cfg.Secret("tasks_failure_alert_webhook_url").Value,
+		httpclient.New(httpclient.IntegrationAlerts, httpClientConfig),
+		tasksClient,
+		db,
+		repoFactory,
+	)
+
+	// Apply the reloaded limits and email lists
+	appEssentials.ConfigWatcher.Subscribe(func(ctx context.Context, previous *config.Config, current *config.Config) {
+		if !slices.Equal(current.TransactionalEmailsGlobalLimits, previous.TransactionalEmailsGlobalLimits) ||
+			!slices.Equal(current.TransactionalEmailsRateLimits, previous.TransactionalEmailsRateLimits) {
+			if err := transactionalEmailService.SetLimits(
+				current.TransactionalEmailsGlobalLimits,
+				current.TransactionalEmailsRateLimits,
+			); err != nil {
+				logger.ErrorContext(ctx, "Failed to apply the reloaded email limits", "error", err)
+			}
+		}
+
+		if current.AuthenticationEmailBlocklistPath != previous.AuthenticationEmailBlocklistPath ||
+			current.AuthenticationEmailAllowlistPath != previous.AuthenticationEmailAllowlistPath {
+			if err := emailBlocklistService.SetPaths(
+				ctx,
+				current.AuthenticationEmailBlocklistPath,
+				current.AuthenticationEmailAllowlistPath,
+			); err != nil {
+				logger.ErrorContext(ctx, "Failed to apply the reloaded email blocklist paths", "error", err)
+			}
+		}
+	})
+
+	// Readiness probe of the API server
+	readinessChecks := []*health.Check{
+		{Name: "database", Run: db.PingContext},
+		{Name: "redis", Run: func(ctx context.Context) error { return redisClient.Ping(ctx).Err() }},
+	}
+	healthChecksHTTPClient := httpclient.New(httpclient.IntegrationHealthChecks, httpClientConfig)
+	for _, check := range cfg.HealthChecks {
+		readinessChecks = append(
+			readinessChecks,
+			health.NewHTTPCheck(check.Name, check.URL, check.Timeout, healthChecksHTTPClient),
+		)
+	}
+	readiness := health.NewReadiness(readinessChecks, cfg.HealthCheckTimeout, cfg.HealthCheckCacheTTL)
 
 	return &App{
 		Essentials: appEssentials,
@@ -134,10 +349,16 @@ func NewApp() *App {
 		DB: db,
 
 		TasksClient: tasksClient,
+		RedisClient: redisClient,
 
 		CaptchaService:            captchaService,
+		RiskService:               riskService,
 		TransactionalEmailService: transactionalEmailService,
+		EmailBlocklistService:     emailBlocklistService,
 		AuthenticationService:     authenticationService,
 		UserService:               userService,
+		FailedTaskService:         failedTaskService,
+
+		Readiness: readiness,
 	}
 }
@@ -1,89 +1,252 @@
 package config
 
 import (
-	"bufio"
-	"os"
+	"context"
+	"reflect"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/scaleway/scaleway-sdk-go/scw"
 	"github.com/spf13/viper"
+
+	"prutya/go-api-template/internal/secrets"
 )
 
+type TransactionalEmailsGlobalLimit struct {
+	Window time.Duration `mapstructure:"WINDOW" validate:"gt=0"`
+	Limit  int           `mapstructure:"LIMIT" validate:"gte=1"`
+}
+
+type TransactionalEmailsRateLimit struct {
+	Scope  string        `mapstructure:"SCOPE" validate:"oneof=global recipient recipient_domain requester_ip user"`
+	Budget string        `mapstructure:"BUDGET" validate:"oneof=critical non_critical"`
+	Window time.Duration `mapstructure:"WINDOW" validate:"gt=0"`
+	Limit  int           `mapstructure:"LIMIT" validate:"gte=1"`
+}
+
+// A task the scheduler enqueues periodically
+type TasksSchedule struct {
+	Name string `mapstructure:"NAME" validate:"required"`
+	// Standard 5-field cron spec or a descriptor, e.g. "@every 1h"
+	Cron string `mapstructure:"CRON" validate:"required,cron"`
+	// IANA time zone the cron spec is interpreted in, UTC when it's empty
+	Timezone string `mapstructure:"TIMEZONE" validate:"omitempty,timezone"`
+	TaskType string `mapstructure:"TASK_TYPE" validate:"required"`
+	// Overrides the queue from the task policy when it's set
+	Queue   string         `mapstructure:"QUEUE"`
+	Payload map[string]any `mapstructure:"PAYLOAD"`
+}
+
+// Keeps 1 in `Rate` log records with the message, e.g. "SQL query"
+type LogSampling struct {
+	Message string `mapstructure:"MESSAGE" validate:"required"`
+	Rate    int    `mapstructure:"RATE" validate:"gte=1"`
+}
+
+// A downstream service checked by the readiness probe of the API server
+type HealthCheck struct {
+	Name string `mapstructure:"NAME" validate:"required"`
+	// Must respond to a GET with a 2xx status
+	URL string `mapstructure:"URL" validate:"required,url"`
+	// Overrides "health_check_timeout" when it's set
+	Timeout time.Duration `mapstructure:"TIMEOUT" validate:"gte=0"`
+}
+
+// The fields tagged `reload` can change while the process runs, see `Watcher`
 type Config struct {
-	LogLevel             string        `mapstructure:"LOG_LEVEL"`
-	LogFormat            string        `mapstructure:"LOG_FORMAT"`
-	LogTimeFormat        string        `mapstructure:"LOG_TIME_FORMAT"`
-	LogShowCallerInDebug bool          `mapstructure:"LOG_SHOW_CALLER_IN_DEBUG"`
-	RequestTimeout       time.Duration `mapstructure:"REQUEST_TIMEOUT"`
-	CorsAllowedOrigins   []string      `mapstructure:"CORS_ALLOWED_ORIGINS"`
-	CorsAllowedMethods   []string      `mapstructure:"CORS_ALLOWED_METHODS"`
-	CorsAllowedHeaders   []string      `mapstructure:"CORS_ALLOWED_HEADERS"`
-	CorsExposedHeaders   []string      `mapstructure:"CORS_EXPOSED_HEADERS"`
-	CorsAllowCredentials bool          `mapstructure:"CORS_ALLOW_CREDENTIALS"`
-	CorsMaxAge           time.Duration `mapstructure:"CORS_MAX_AGE"`
-	ShutdownTimeout      time.Duration `mapstructure:"SHUTDOWN_TIMEOUT"`
-	ListenAddr           string        `mapstructure:"LISTEN_ADDR"`
+	LogLevel             string            `mapstructure:"LOG_LEVEL" validate:"oneof=debug info warn warning error" reload:"true"`
+	LogFormat            string            `mapstructure:"LOG_FORMAT" validate:"oneof=text json"`
+	LogTimeFormat        string            `mapstructure:"LOG_TIME_FORMAT"`
+	LogShowCallerInDebug bool              `mapstructure:"LOG_SHOW_CALLER_IN_DEBUG"`
+	LogRedactedKeys      []string          `mapstructure:"LOG_REDACTED_KEYS"`
+	LogComponentLevels   map[string]string `mapstructure:"LOG_COMPONENT_LEVELS" validate:"dive,oneof=debug info warn warning error" reload:"true"`
+	LogSampling          []LogSampling     `mapstructure:"LOG_SAMPLING" validate:"dive"`
+
+	AccessLogFields        []string      `mapstructure:"ACCESS_LOG_FIELDS" validate:"dive,oneof=route client_ip user_agent referer user_id bytes_read bytes_written"`
+	AccessLogSlowThreshold time.Duration `mapstructure:"ACCESS_LOG_SLOW_THRESHOLD" validate:"gte=0"`
+	AccessLogFormat        string        `mapstructure:"ACCESS_LOG_FORMAT" validate:"omitempty,oneof=common combined"`
+	AccessLogPath          string        `mapstructure:"ACCESS_LOG_PATH"`
+
+	RequestTimeout       time.Duration `mapstructure:"REQUEST_TIMEOUT" validate:"gt=0"`
+	CorsAllowedOrigins   []string      `mapstructure:"CORS_ALLOWED_ORIGINS" reload:"true"`
+	CorsAllowedMethods   []string      `mapstructure:"CORS_ALLOWED_METHODS" reload:"true"`
+	CorsAllowedHeaders   []string      `mapstructure:"CORS_ALLOWED_HEADERS" reload:"true"`
+	CorsExposedHeaders   []string      `mapstructure:"CORS_EXPOSED_HEADERS" reload:"true"`
+	CorsAllowCredentials bool          `mapstructure:"CORS_ALLOW_CREDENTIALS" reload:"true"`
+	CorsMaxAge           time.Duration `mapstructure:"CORS_MAX_AGE" reload:"true"`
+	ShutdownTimeout      time.Duration `mapstructure:"SHUTDOWN_TIMEOUT" validate:"gt=0"`
+	ShutdownDrainDelay   time.Duration `mapstructure:"SHUTDOWN_DRAIN_DELAY" validate:"gte=0"`
+	ListenAddr           string        `mapstructure:"LISTEN_ADDR" validate:"required"`
+	MetricsListenAddr    string        `mapstructure:"METRICS_LISTEN_ADDR"`
 	ReadTimeout          time.Duration `mapstructure:"READ_TIMEOUT"`
 	WriteTimeout         time.Duration `mapstructure:"WRITE_TIMEOUT"`
 	ReadHeaderTimeout    time.Duration `mapstructure:"READ_HEADER_TIMEOUT"`
 	IdleTimeout          time.Duration `mapstructure:"IDLE_TIMEOUT"`
+	AdminAPIToken        string        `mapstructure:"ADMIN_API_TOKEN" secret:"true"`
 
-	DatabaseUrl                   string        `mapstructure:"DATABASE_URL"`
-	DatabaseMaxOpenConnections    int           `mapstructure:"DATABASE_MAX_OPEN_CONNECTIONS"`
-	DatabaseMaxIdleConnections    int           `mapstructure:"DATABASE_MAX_IDLE_CONNECTIONS"`
+	TracingExporter     string  `mapstructure:"TRACING_EXPORTER" validate:"oneof=none stdout otlp"`
+	TracingOTLPEndpoint string  `mapstructure:"TRACING_OTLP_ENDPOINT" validate:"required_if=TracingExporter otlp"`
+	TracingOTLPInsecure bool    `mapstructure:"TRACING_OTLP_INSECURE"`
+	TracingServiceName  string  `mapstructure:"TRACING_SERVICE_NAME"`
+	TracingSampleRatio  float64 `mapstructure:"TRACING_SAMPLE_RATIO" validate:"gte=0,lte=1"`
+
+	DatabaseUrl                   string        `mapstructure:"DATABASE_URL" validate:"required,url" secret:"url"`
+	DatabaseMaxOpenConnections    int           `mapstructure:"DATABASE_MAX_OPEN_CONNECTIONS" validate:"gte=0"`
+	DatabaseMaxIdleConnections    int           `mapstructure:"DATABASE_MAX_IDLE_CONNECTIONS" validate:"gte=0"`
 	DatabaseMaxConnectionLifetime time.Duration `mapstructure:"DATABASE_MAX_CONNECTION_LIFETIME"`
 	DatabaseMaxConnectionIdleTime time.Duration `mapstructure:"DATABASE_MAX_CONNECTION_IDLE_TIME"`
 
-	AuthenticationTimingAttackDelay            time.Duration `mapstructure:"AUTHENTICATION_TIMING_ATTACK_DELAY"`
-	AuthenticationRefreshTokenTTL              time.Duration `mapstructure:"AUTHENTICATION_REFRESH_TOKEN_TTL"`
-	AuthenticationRefreshTokenSecretLength     uint32        `mapstructure:"AUTHENTICATION_REFRESH_TOKEN_SECRET_LENGTH"`
-	AuthenticationRefreshTokenLeeway           time.Duration `mapstructure:"AUTHENTICATION_REFRESH_TOKEN_LEEWAY"`
-	AuthenticationRefreshTokenCookieName       string        `mapstructure:"AUTHENTICATION_REFRESH_TOKEN_COOKIE_NAME"`
+	AuthenticationTimingAttackDelay            time.Duration `mapstructure:"AUTHENTICATION_TIMING_ATTACK_DELAY" validate:"gte=0"`
+	AuthenticationRefreshTokenTTL              time.Duration `mapstructure:"AUTHENTICATION_REFRESH_TOKEN_TTL" validate:"gt=0"`
+	AuthenticationRefreshTokenSecretLength     uint32        `mapstructure:"AUTHENTICATION_REFRESH_TOKEN_SECRET_LENGTH" validate:"gte=16"`
+	AuthenticationRefreshTokenLeeway           time.Duration `mapstructure:"AUTHENTICATION_REFRESH_TOKEN_LEEWAY" validate:"gte=0"`
+	AuthenticationRefreshTokenCookieName       string        `mapstructure:"AUTHENTICATION_REFRESH_TOKEN_COOKIE_NAME" validate:"required"`
 	AuthenticationRefreshTokenCookieDomain     string        `mapstructure:"AUTHENTICATION_REFRESH_TOKEN_COOKIE_DOMAIN"`
 	AuthenticationRefreshTokenCookiePath       string        `mapstructure:"AUTHENTICATION_REFRESH_TOKEN_COOKIE_PATH"`
 	AuthenticationRefreshTokenCookieSecure     bool          `mapstructure:"AUTHENTICATION_REFRESH_TOKEN_COOKIE_SECURE"`
 	AuthenticationRefreshTokenCookieHttpOnly   bool          `mapstructure:"AUTHENTICATION_REFRESH_TOKEN_COOKIE_HTTP_ONLY"`
-	AuthenticationAccessTokenTTL               time.Duration `mapstructure:"AUTHENTICATION_ACCESS_TOKEN_TTL"`
-	AuthenticationAccessTokenSecretLength      uint32        `mapstructure:"AUTHENTICATION_ACCESS_TOKEN_SECRET_LENGTH"`
+	AuthenticationAccessTokenTTL               time.Duration `mapstructure:"AUTHENTICATION_ACCESS_TOKEN_TTL" validate:"gt=0,ltfield=AuthenticationRefreshTokenTTL"`
+	AuthenticationAccessTokenSecretLength      uint32        `mapstructure:"AUTHENTICATION_ACCESS_TOKEN_SECRET_LENGTH" validate:"gte=16"`
 	AuthenticationEmailVerificationCooldown    time.Duration `mapstructure:"AUTHENTICATION_EMAIL_VERIFICATION_COOLDOWN"`
-	AuthenticationEmailVerificationCodeTTL     time.Duration `mapstructure:"AUTHENTICATION_EMAIL_VERIFICATION_CODE_TTL"`
-	AuthenticationEmailVerificationMaxAttempts int           `mapstructure:"AUTHENTICATION_EMAIL_VERIFICATION_MAX_ATTEMPTS"`
+	AuthenticationEmailVerificationCodeTTL     time.Duration `mapstructure:"AUTHENTICATION_EMAIL_VERIFICATION_CODE_TTL" validate:"gt=0"`
+	AuthenticationEmailVerificationMaxAttempts int           `mapstructure:"AUTHENTICATION_EMAIL_VERIFICATION_MAX_ATTEMPTS" validate:"gte=1"`
 	AuthenticationPasswordResetCooldown        time.Duration `mapstructure:"AUTHENTICATION_PASSWORD_RESET_COOLDOWN"`
-	AuthenticationPasswordResetCodeTTL         time.Duration `mapstructure:"AUTHENTICATION_PASSWORD_RESET_CODE_TTL"`
-	AuthenticationPasswordResetMaxAttempts     int           `mapstructure:"AUTHENTICATION_PASSWORD_RESET_MAX_ATTEMPTS"`
-	AuthenticationPasswordResetTokenTTL        time.Duration `mapstructure:"AUTHENTICATION_PASSWORD_RESET_TOKEN_TTL"`
-	AuthenticationPasswordArgon2Memory         uint32        `mapstructure:"AUTHENTICATION_PASSWORD_ARGON2_MEMORY"`
-	AuthenticationPasswordArgon2Iterations     uint32        `mapstructure:"AUTHENTICATION_PASSWORD_ARGON2_ITERATIONS"`
-	AuthenticationPasswordArgon2Parallelism    uint8         `mapstructure:"AUTHENTICATION_PASSWORD_ARGON2_PARALLELISM"`
-	AuthenticationPasswordArgon2SaltLength     uint32        `mapstructure:"AUTHENTICATION_PASSWORD_ARGON2_SALT_LENGTH"`
-	AuthenticationPasswordArgon2KeyLength      uint32        `mapstructure:"AUTHENTICATION_PASSWORD_ARGON2_KEY_LENGTH"`
-	AuthenticationOTPArgon2Memory              uint32        `mapstructure:"AUTHENTICATION_OTP_ARGON2_MEMORY"`
-	AuthenticationOTPArgon2Iterations          uint32        `mapstructure:"AUTHENTICATION_OTP_ARGON2_ITERATIONS"`
-	AuthenticationOTPArgon2Parallelism         uint8         `mapstructure:"AUTHENTICATION_OTP_ARGON2_PARALLELISM"`
-	AuthenticationOTPArgon2SaltLength          uint32        `mapstructure:"AUTHENTICATION_OTP_ARGON2_SALT_LENGTH"`
-	AuthenticationOTPArgon2KeyLength           uint32        `mapstructure:"AUTHENTICATION_OTP_ARGON2_KEY_LENGTH"`
-	AuthenticationEmailBlocklist               map[string]struct{}
-
-	CaptchaEnabled            bool   `mapstructure:"CAPTCHA_ENABLED"`
-	CaptchaTurnstileBaseURL   string `mapstructure:"CAPTCHA_TURNSTILE_BASE_URL"`
-	CaptchaTurnstileSecretKey string `mapstructure:"CAPTCHA_TURNSTILE_SECRET_KEY"`
-
-	TransactionalEmailsEnabled             bool   `mapstructure:"TRANSACTIONAL_EMAILS_ENABLED"`
-	TransactionalEmailsDailyGlobalLimit    int    `mapstructure:"TRANSACTIONAL_EMAILS_DAILY_GLOBAL_LIMIT"`
-	TransactionalEmailsSenderEmail         string `mapstructure:"TRANSACTIONAL_EMAILS_SENDER_EMAIL"`
-	TransactionalEmailsSenderName          string `mapstructure:"TRANSACTIONAL_EMAILS_SENDER_NAME"`
-	TransactionalEmailsScalewayAccessKeyID string `mapstructure:"TRANSACTIONAL_EMAILS_SCALEWAY_ACCESS_KEY_ID"`
-	TransactionalEmailsScalewaySecretKey   string `mapstructure:"TRANSACTIONAL_EMAILS_SCALEWAY_SECRET_KEY"`
-	TransactionalEmailsScalewayRegionRaw   string `mapstructure:"TRANSACTIONAL_EMAILS_SCALEWAY_REGION"`
+	AuthenticationPasswordResetCodeTTL         time.Duration `mapstructure:"AUTHENTICATION_PASSWORD_RESET_CODE_TTL" validate:"gt=0"`
+	AuthenticationPasswordResetMaxAttempts     int           `mapstructure:"AUTHENTICATION_PASSWORD_RESET_MAX_ATTEMPTS" validate:"gte=1"`
+	AuthenticationPasswordResetTokenTTL        time.Duration `mapstructure:"AUTHENTICATION_PASSWORD_RESET_TOKEN_TTL" validate:"gt=0"`
+	AuthenticationPasswordArgon2Memory         uint32        `mapstructure:"AUTHENTICATION_PASSWORD_ARGON2_MEMORY" validate:"gt=0"`
+	AuthenticationPasswordArgon2Iterations     uint32        `mapstructure:"AUTHENTICATION_PASSWORD_ARGON2_ITERATIONS" validate:"gt=0"`
+	AuthenticationPasswordArgon2Parallelism    uint8         `mapstructure:"AUTHENTICATION_PASSWORD_ARGON2_PARALLELISM" validate:"gt=0"`
+	AuthenticationPasswordArgon2SaltLength     uint32        `mapstructure:"AUTHENTICATION_PASSWORD_ARGON2_SALT_LENGTH" validate:"gt=0"`
+	AuthenticationPasswordArgon2KeyLength      uint32        `mapstructure:"AUTHENTICATION_PASSWORD_ARGON2_KEY_LENGTH" validate:"gt=0"`
+	AuthenticationOTPArgon2Memory              uint32        `mapstructure:"AUTHENTICATION_OTP_ARGON2_MEMORY" validate:"gt=0"`
+	AuthenticationOTPArgon2Iterations          uint32        `mapstructure:"AUTHENTICATION_OTP_ARGON2_ITERATIONS" validate:"gt=0"`
+	AuthenticationOTPArgon2Parallelism         uint8         `mapstructure:"AUTHENTICATION_OTP_ARGON2_PARALLELISM" validate:"gt=0"`
+	AuthenticationOTPArgon2SaltLength          uint32        `mapstructure:"AUTHENTICATION_OTP_ARGON2_SALT_LENGTH" validate:"gt=0"`
+	AuthenticationOTPArgon2KeyLength           uint32        `mapstructure:"AUTHENTICATION_OTP_ARGON2_KEY_LENGTH" validate:"gt=0"`
+	AuthenticationEmailBlocklistPath           string        `mapstructure:"AUTHENTICATION_EMAIL_BLOCKLIST_PATH" validate:"required" reload:"true"`
+	AuthenticationEmailAllowlistPath           string        `mapstructure:"AUTHENTICATION_EMAIL_ALLOWLIST_PATH" reload:"true"`
+	AuthenticationEmailBlocklistSyncURL        string        `mapstructure:"AUTHENTICATION_EMAIL_BLOCKLIST_SYNC_URL" validate:"omitempty,url"`
+	AuthenticationEmailBlocklistSyncSchedule   string        `mapstructure:"AUTHENTICATION_EMAIL_BLOCKLIST_SYNC_SCHEDULE" validate:"required_with=AuthenticationEmailBlocklistSyncURL,omitempty,cron"`
+
+	AuthenticationEmailDomainValidationEnabled           bool          `mapstructure:"AUTHENTICATION_EMAIL_DOMAIN_VALIDATION_ENABLED"`
+	AuthenticationEmailDomainValidationTimeout           time.Duration `mapstructure:"AUTHENTICATION_EMAIL_DOMAIN_VALIDATION_TIMEOUT" validate:"required_if=AuthenticationEmailDomainValidationEnabled true,gte=0"`
+	AuthenticationEmailDomainValidationCacheTTL          time.Duration `mapstructure:"AUTHENTICATION_EMAIL_DOMAIN_VALIDATION_CACHE_TTL"`
+	AuthenticationEmailDomainValidationSuggestionDomains []string      `mapstructure:"AUTHENTICATION_EMAIL_DOMAIN_VALIDATION_SUGGESTION_DOMAINS"`
+
+	AuthenticationCleanupTokenLeeway      time.Duration `mapstructure:"AUTHENTICATION_CLEANUP_TOKEN_LEEWAY" validate:"gte=0"`
+	AuthenticationCleanupSessionRetention time.Duration `mapstructure:"AUTHENTICATION_CLEANUP_SESSION_RETENTION" validate:"gte=0"`
+	AuthenticationCleanupBatchSize        int           `mapstructure:"AUTHENTICATION_CLEANUP_BATCH_SIZE" validate:"gte=1"`
+
+	CaptchaEnabled                  bool               `mapstructure:"CAPTCHA_ENABLED"`
+	CaptchaProvider                 string             `mapstructure:"CAPTCHA_PROVIDER" validate:"required_if=CaptchaEnabled true,omitempty,oneof=turnstile hcaptcha recaptcha pow"`
+	CaptchaTurnstileBaseURL         string             `mapstructure:"CAPTCHA_TURNSTILE_BASE_URL" validate:"required_if=CaptchaEnabled true CaptchaProvider turnstile,omitempty,url"`
+	CaptchaTurnstileSecretKey       string             `mapstructure:"CAPTCHA_TURNSTILE_SECRET_KEY" validate:"required_if=CaptchaEnabled true CaptchaProvider turnstile" secret:"true"`
+	CaptchaHCaptchaBaseURL          string             `mapstructure:"CAPTCHA_HCAPTCHA_BASE_URL" validate:"required_if=CaptchaEnabled true CaptchaProvider hcaptcha,omitempty,url"`
+	CaptchaHCaptchaSecretKey        string             `mapstructure:"CAPTCHA_HCAPTCHA_SECRET_KEY" validate:"required_if=CaptchaEnabled true CaptchaProvider hcaptcha" secret:"true"`
+	CaptchaHCaptchaSiteKey          string             `mapstructure:"CAPTCHA_HCAPTCHA_SITE_KEY"`
+	CaptchaRecaptchaBaseURL         string             `mapstructure:"CAPTCHA_RECAPTCHA_BASE_URL" validate:"required_if=CaptchaEnabled true CaptchaProvider recaptcha,omitempty,url"`
+	CaptchaRecaptchaSecretKey       string             `mapstructure:"CAPTCHA_RECAPTCHA_SECRET_KEY" validate:"required_if=CaptchaEnabled true CaptchaProvider recaptcha" secret:"true"`
+	CaptchaRecaptchaScoreThreshold  float64            `mapstructure:"CAPTCHA_RECAPTCHA_SCORE_THRESHOLD" validate:"gte=0,lte=1"`
+	CaptchaRecaptchaScoreThresholds map[string]float64 `mapstructure:"CAPTCHA_RECAPTCHA_SCORE_THRESHOLDS" validate:"dive,gte=0,lte=1"`
+	CaptchaPowSecret                string             `mapstructure:"CAPTCHA_POW_SECRET" validate:"required_if=CaptchaEnabled true CaptchaProvider pow,omitempty,min=32" secret:"true"`
+	CaptchaPowDifficulty            int                `mapstructure:"CAPTCHA_POW_DIFFICULTY" validate:"required_if=CaptchaEnabled true CaptchaProvider pow,gte=0,lte=32"`
+	CaptchaPowChallengeTTL          time.Duration      `mapstructure:"CAPTCHA_POW_CHALLENGE_TTL" validate:"gte=0"`
+	CaptchaPowPassTTL               time.Duration      `mapstructure:"CAPTCHA_POW_PASS_TTL" validate:"gte=0"`
+	CaptchaAllowedHostnames         []string           `mapstructure:"CAPTCHA_ALLOWED_HOSTNAMES"`
+	CaptchaTokenReplayTTL           time.Duration      `mapstructure:"CAPTCHA_TOKEN_REPLAY_TTL" validate:"gte=0"`
+
+	CaptchaRiskEnabled              bool          `mapstructure:"CAPTCHA_RISK_ENABLED"`
+	CaptchaRiskThreshold            int           `mapstructure:"CAPTCHA_RISK_THRESHOLD" validate:"required_if=CaptchaRiskEnabled true,gte=0"`
+	CaptchaRiskFailureWindow        time.Duration `mapstructure:"CAPTCHA_RISK_FAILURE_WINDOW" validate:"required_if=CaptchaRiskEnabled true,gte=0"`
+	CaptchaRiskKnownDeviceTTL       time.Duration `mapstructure:"CAPTCHA_RISK_KNOWN_DEVICE_TTL" validate:"gte=0"`
+	CaptchaRiskIPFailureWeight      int           `mapstructure:"CAPTCHA_RISK_IP_FAILURE_WEIGHT"`
+	CaptchaRiskAccountFailureWeight int           `mapstructure:"CAPTCHA_RISK_ACCOUNT_FAILURE_WEIGHT"`
+	CaptchaRiskNewDeviceWeight      int           `mapstructure:"CAPTCHA_RISK_NEW_DEVICE_WEIGHT"`
+	CaptchaRiskBlockedNetworkWeight int           `mapstructure:"CAPTCHA_RISK_BLOCKED_NETWORK_WEIGHT"`
+	CaptchaRiskBlockedNetworks      []string      `mapstructure:"CAPTCHA_RISK_BLOCKED_NETWORKS" validate:"dive,cidr"`
+	CaptchaRiskBlockedASNs          []string      `mapstructure:"CAPTCHA_RISK_BLOCKED_ASNS"`
+	CaptchaRiskASNHeader            string        `mapstructure:"CAPTCHA_RISK_ASN_HEADER"`
+
+	TransactionalEmailsEnabled             bool                             `mapstructure:"TRANSACTIONAL_EMAILS_ENABLED"`
+	TransactionalEmailsGlobalLimits        []TransactionalEmailsGlobalLimit `mapstructure:"TRANSACTIONAL_EMAILS_GLOBAL_LIMITS" validate:"dive" reload:"true"`
+	TransactionalEmailsRateLimits          []TransactionalEmailsRateLimit   `mapstructure:"TRANSACTIONAL_EMAILS_RATE_LIMITS" validate:"dive" reload:"true"`
+	TransactionalEmailsSenderEmail         string                           `mapstructure:"TRANSACTIONAL_EMAILS_SENDER_EMAIL" validate:"required_if=TransactionalEmailsEnabled true,omitempty,email"`
+	TransactionalEmailsSenderName          string                           `mapstructure:"TRANSACTIONAL_EMAILS_SENDER_NAME"`
+	TransactionalEmailsWebhookSecret       string                           `mapstructure:"TRANSACTIONAL_EMAILS_WEBHOOK_SECRET" secret:"true"`
+	TransactionalEmailsWebhookTolerance    time.Duration                    `mapstructure:"TRANSACTIONAL_EMAILS_WEBHOOK_TOLERANCE" validate:"gte=0"`
+	TransactionalEmailsScalewayAccessKeyID string                           `mapstructure:"TRANSACTIONAL_EMAILS_SCALEWAY_ACCESS_KEY_ID" validate:"required_if=TransactionalEmailsEnabled true"`
+	TransactionalEmailsScalewaySecretKey   string                           `mapstructure:"TRANSACTIONAL_EMAILS_SCALEWAY_SECRET_KEY" validate:"required_if=TransactionalEmailsEnabled true" secret:"true"`
+	TransactionalEmailsScalewayRegionRaw   string                           `mapstructure:"TRANSACTIONAL_EMAILS_SCALEWAY_REGION" validate:"required_if=TransactionalEmailsEnabled true,omitempty,scaleway_region"`
 	TransactionalEmailsScalewayRegion      scw.Region
-	TransactionalEmailsScalewayProjectID   string `mapstructure:"TRANSACTIONAL_EMAILS_SCALEWAY_PROJECT_ID"`
+	TransactionalEmailsScalewayProjectID   string `mapstructure:"TRANSACTIONAL_EMAILS_SCALEWAY_PROJECT_ID" validate:"required_if=TransactionalEmailsEnabled true"`
+
+	// Deprecated, see `applyDailyGlobalLimit`
+	TransactionalEmailsDailyGlobalLimit *int `mapstructure:"TRANSACTIONAL_EMAILS_DAILY_GLOBAL_LIMIT" reload:"true"`
+
+	HTTPClientTimeout                        time.Duration            `mapstructure:"HTTP_CLIENT_TIMEOUT" validate:"gt=0"`
+	HTTPClientDialTimeout                    time.Duration            `mapstructure:"HTTP_CLIENT_DIAL_TIMEOUT" validate:"gte=0"`
+	HTTPClientTLSHandshakeTimeout            time.Duration            `mapstructure:"HTTP_CLIENT_TLS_HANDSHAKE_TIMEOUT" validate:"gte=0"`
+	HTTPClientResponseHeaderTimeout          time.Duration            `mapstructure:"HTTP_CLIENT_RESPONSE_HEADER_TIMEOUT" validate:"gte=0"`
+	HTTPClientIdleConnTimeout                time.Duration            `mapstructure:"HTTP_CLIENT_IDLE_CONN_TIMEOUT" validate:"gte=0"`
+	HTTPClientMaxIdleConns                   int                      `mapstructure:"HTTP_CLIENT_MAX_IDLE_CONNS" validate:"gte=0"`
+	HTTPClientMaxIdleConnsPerHost            int                      `mapstructure:"HTTP_CLIENT_MAX_IDLE_CONNS_PER_HOST" validate:"gte=0"`
+	HTTPClientMaxConnsPerHost                int                      `mapstructure:"HTTP_CLIENT_MAX_CONNS_PER_HOST" validate:"gte=0"`
+	HTTPClientMaxRedirects                   int                      `mapstructure:"HTTP_CLIENT_MAX_REDIRECTS" validate:"gte=0"`
+	HTTPClientMaxRetries                     int                      `mapstructure:"HTTP_CLIENT_MAX_RETRIES" validate:"gte=0"`
+	HTTPClientRetryBaseDelay                 time.Duration            `mapstructure:"HTTP_CLIENT_RETRY_BASE_DELAY" validate:"gte=0"`
+	HTTPClientRetryMaxDelay                  time.Duration            `mapstructure:"HTTP_CLIENT_RETRY_MAX_DELAY" validate:"gtefield=HTTPClientRetryBaseDelay"`
+	HTTPClientCircuitBreakerFailureThreshold int                      `mapstructure:"HTTP_CLIENT_CIRCUIT_BREAKER_FAILURE_THRESHOLD" validate:"gte=0"`
+	HTTPClientCircuitBreakerOpenTimeout      time.Duration            `mapstructure:"HTTP_CLIENT_CIRCUIT_BREAKER_OPEN_TIMEOUT" validate:"gte=0"`
+	HTTPClientTimeoutOverrides               map[string]time.Duration `mapstructure:"HTTP_CLIENT_TIMEOUT_OVERRIDES" validate:"dive,gt=0"`
+
+	TasksRedisAddr              string         `mapstructure:"TASKS_REDIS_ADDR" validate:"required"`
+	TasksRedisPassword          string         `mapstructure:"TASKS_REDIS_PASSWORD" secret:"true"`
+	TasksConcurrency            int            `mapstructure:"TASKS_CONCURRENCY" validate:"gte=1"`
+	TasksQueues                 map[string]int `mapstructure:"TASKS_QUEUES" validate:"required,dive,gte=0"`
+	TasksStrictPriority         bool           `mapstructure:"TASKS_STRICT_PRIORITY"`
+	TasksFailureAlertWebhookURL string         `mapstructure:"TASKS_FAILURE_ALERT_WEBHOOK_URL" validate:"omitempty,url" secret:"true"`
+	TasksShutdownTimeout        time.Duration  `mapstructure:"TASKS_SHUTDOWN_TIMEOUT" validate:"gt=0"`
+
+	TasksSchedules              []TasksSchedule `mapstructure:"TASKS_SCHEDULES" validate:"dive"`
+	TasksSchedulerLeaderLockTTL time.Duration   `mapstructure:"TASKS_SCHEDULER_LEADER_LOCK_TTL" validate:"gt=0"`
+
+	WorkerProbeListenAddr    string        `mapstructure:"WORKER_PROBE_LISTEN_ADDR"`
+	SchedulerProbeListenAddr string        `mapstructure:"SCHEDULER_PROBE_LISTEN_ADDR"`
+	ProbeMetricsEnabled      bool          `mapstructure:"PROBE_METRICS_ENABLED"`
+	ProbeCheckTimeout        time.Duration `mapstructure:"PROBE_CHECK_TIMEOUT" validate:"gt=0"`
+
+	HealthCheckTimeout  time.Duration `mapstructure:"HEALTH_CHECK_TIMEOUT" validate:"gt=0"`
+	HealthCheckCacheTTL time.Duration `mapstructure:"HEALTH_CHECK_CACHE_TTL" validate:"gte=0"`
+	HealthChecks        []HealthCheck `mapstructure:"HEALTH_CHECKS" validate:"dive"`
 
-	TasksRedisAddr     string `mapstructure:"TASKS_REDIS_ADDR"`
-	TasksRedisPassword string `mapstructure:"TASKS_REDIS_PASSWORD"`
+	SecretsRefreshInterval time.Duration `mapstructure:"SECRETS_REFRESH_INTERVAL" validate:"gte=0"`
+	SecretsVaultAddress    string        `mapstructure:"SECRETS_VAULT_ADDRESS" validate:"omitempty,url"`
+	SecretsVaultToken      string        `mapstructure:"SECRETS_VAULT_TOKEN" validate:"required_with=SecretsVaultAddress" secret:"true"`
+	SecretsVaultMount      string        `mapstructure:"SECRETS_VAULT_MOUNT" validate:"required_with=SecretsVaultAddress"`
+	SecretsVaultTimeout    time.Duration `mapstructure:"SECRETS_VAULT_TIMEOUT" validate:"gt=0"`
+
+	// The current values of the fields tagged `secret`, see `Secret`
+	secrets *secrets.Store
 }
 
+// Reads and validates the config
 func Load() (*Config, error) {
+	config, err := Read()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Reads the config without validating it, see `Validate`
+func Read() (*Config, error) {
 	viper.AddConfigPath(".")
 	viper.SetConfigName("app")
 	viper.SetConfigType("json")
@@ -91,11 +254,55 @@ func Load() (*Config, error) {
 	viper.SetEnvPrefix("APP")
 	viper.AutomaticEnv()
 
+	// Unmarshal only sees the environment variables of the known settings, so
+	// the ones without defaults, e.g. the secrets, are bound explicitly
+	for _, field := range reflect.VisibleFields(reflect.TypeFor[Config]()) {
+		if key := field.Tag.Get("mapstructure"); key != "" {
+			viper.BindEnv(strings.ToLower(key))
+		}
+	}
+
 	// Server configuration
 	viper.SetDefault("log_level", "info")
 	viper.SetDefault("log_format", "json")
 	viper.SetDefault("log_time_format", "iso8601")
 	viper.SetDefault("log_show_caller_in_debug", true)
+	// Patterns of the attribute keys whose values are never logged, matched
+	// case-insensitively with the `path.Match` syntax
+	viper.SetDefault("log_redacted_keys", []string{
+		"*password*",
+		"*token",
+		"*secret*",
+		"*otp",
+		"authorization",
+		"cookie",
+	})
+	// Levels of the components that differ from "log_level", e.g.
+	// {"db": "debug"}. The components are "db", "http", "httpclient" and
+	// "tasks". The levels can be changed at runtime via the admin API, and
+	// SIGUSR1 toggles the default level to debug and back.
+	viper.SetDefault("log_component_levels", map[string]string{})
+	// High-volume messages logged at a rate, e.g.
+	// [{"message": "SQL query", "rate": 10}]. Warnings and errors are always
+	// logged.
+	viper.SetDefault("log_sampling", []map[string]any{})
+	// Fields added to the "Request ended" lines, out of "route", "client_ip",
+	// "user_agent", "referer", "user_id", "bytes_read" and "bytes_written"
+	viper.SetDefault("access_log_fields", []string{
+		"route",
+		"client_ip",
+		"user_agent",
+		"user_id",
+		"bytes_read",
+		"bytes_written",
+	})
+	// Requests taking longer are logged as warnings. Disabled when it's 0.
+	viper.SetDefault("access_log_slow_threshold", 1*time.Second)
+	// "common" or "combined" to also write the requests in the Common or
+	// Combined Log Format to "access_log_path", or to stdout when it's empty.
+	// Disabled when it's empty.
+	viper.SetDefault("access_log_format", "")
+	viper.SetDefault("access_log_path", "")
 	viper.SetDefault("request_timeout", 60*time.Second)
 	// No default for CORS Origins
 	viper.SetDefault("cors_allowed_methods", []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"})
@@ -104,11 +311,26 @@ func Load() (*Config, error) {
 	viper.SetDefault("cors_allow_credentials", true)
 	viper.SetDefault("cors_max_age", 5*time.Minute)
 	viper.SetDefault("shutdown_timeout", 15*time.Second)
+	// How long the readiness probe fails before the server stops accepting
+	// connections, so that the load balancers stop routing requests to it first
+	viper.SetDefault("shutdown_drain_delay", 5*time.Second)
 	viper.SetDefault("listen_addr", ":3333")
+	// The metrics are served on a separate listener, disabled when it's empty
+	viper.SetDefault("metrics_listen_addr", ":9090")
 	viper.SetDefault("read_timeout", 0)
 	viper.SetDefault("write_timeout", 0)
 	viper.SetDefault("read_header_timeout", 10*time.Second)
 	viper.SetDefault("idle_timeout", 0*time.Second)
+	// No default for admin API token, admin routes are disabled when it's empty
+
+	// Tracing. The exporter is "none", "stdout" or "otlp" (HTTP).
+	viper.SetDefault("tracing_exporter", "none")
+	viper.SetDefault("tracing_otlp_endpoint", "localhost:4318")
+	viper.SetDefault("tracing_otlp_insecure", false)
+	viper.SetDefault("tracing_service_name", "go-api-template")
+	// Share of the traces started here that are recorded. Traces started by the
+	// callers follow their decision.
+	viper.SetDefault("tracing_sample_ratio", 1.0)
 
 	// Database configuration
 	// No default for database URL
@@ -145,27 +367,227 @@ func Load() (*Config, error) {
 	viper.SetDefault("authentication_otp_argon2_parallelism", uint8(2))
 	viper.SetDefault("authentication_otp_argon2_salt_length", uint32(16))
 	viper.SetDefault("authentication_otp_argon2_key_length", uint32(16))
-	// AuthenticationEmailBlocklist is loaded from a file and parsed later
+	// See https://github.com/disposable-email-domains/disposable-email-domains
+	viper.SetDefault("authentication_email_blocklist_path", "./config/email-blocklist.conf")
+	// Domains in the allowlist are never blocked, even if a parent domain is
+	viper.SetDefault("authentication_email_allowlist_path", "./config/email-allowlist.conf")
+	// No default for blocklist sync URL, the blocklist is not synced when it's
+	// empty. The synced blocklist is stored in the database and blocked in
+	// addition to the blocklist file.
+	viper.SetDefault("authentication_email_blocklist_sync_schedule", "0 4 * * *")
+	// Registrations are rejected when the email domain has no MX or A records
+	viper.SetDefault("authentication_email_domain_validation_enabled", true)
+	viper.SetDefault("authentication_email_domain_validation_timeout", 3*time.Second)
+	viper.SetDefault("authentication_email_domain_validation_cache_ttl", 1*time.Hour)
+	// Undeliverable domains close to these are reported as typos
+	viper.SetDefault("authentication_email_domain_validation_suggestion_domains", []string{
+		"gmail.com",
+		"googlemail.com",
+		"yahoo.com",
+		"hotmail.com",
+		"outlook.com",
+		"live.com",
+		"icloud.com",
+		"me.com",
+		"aol.com",
+		"proton.me",
+		"protonmail.com",
+		"gmx.com",
+		"yandex.com",
+		"mail.ru",
+	})
+	// Expired tokens are deleted this long after their expiration
+	viper.SetDefault("authentication_cleanup_token_leeway", 1*time.Hour)
+	// Terminated and expired sessions are kept this long, e.g. for audits
+	viper.SetDefault("authentication_cleanup_session_retention", 30*24*time.Hour)
+	// Rows deleted per statement, smaller batches hold the locks for less time
+	viper.SetDefault("authentication_cleanup_batch_size", 1000)
 
 	// Captcha
 	viper.SetDefault("captcha_enabled", true)
+	// One of "turnstile", "hcaptcha", "recaptcha" (v3) or "pow" (self-hosted
+	// proof-of-work)
+	viper.SetDefault("captcha_provider", "turnstile")
 	viper.SetDefault("captcha_turnstile_base_url", "https://challenges.cloudflare.com/turnstile/v0")
 	// No default for Turnstile secret key
+	viper.SetDefault("captcha_hcaptcha_base_url", "https://api.hcaptcha.com")
+	// No default for hCaptcha secret key
+	// No default for hCaptcha site key, any site key is accepted when it's empty
+	viper.SetDefault("captcha_recaptcha_base_url", "https://www.google.com/recaptcha/api")
+	// No default for reCAPTCHA secret key
+	viper.SetDefault("captcha_recaptcha_score_threshold", 0.5)
+	// Per-action overrides of the score threshold, e.g. {"login": 0.3}
+	viper.SetDefault("captcha_recaptcha_score_thresholds", map[string]float64{})
+	// No default for proof-of-work secret
+	// The expected number of hashes to solve a challenge is 2^difficulty
+	viper.SetDefault("captcha_pow_difficulty", 20)
+	viper.SetDefault("captcha_pow_challenge_ttl", 5*time.Minute)
+	viper.SetDefault("captcha_pow_pass_ttl", 2*time.Minute)
+	// Hostnames of the sites the captcha widget is rendered on. Tokens solved on
+	// other hostnames are rejected. Any hostname is accepted when it's empty.
+	viper.SetDefault("captcha_allowed_hostnames", []string{})
+	// Successful tokens are remembered for this long, so that they can't be used
+	// twice. Should be at least the token lifetime of the provider (5 minutes
+	// for Turnstile, 2 minutes for reCAPTCHA and hCaptcha).
+	viper.SetDefault("captcha_token_replay_ttl", 5*time.Minute)
+	// Adaptive captcha: a captcha is only required when the risk score of the
+	// request exceeds the threshold. When disabled, it is always required.
+	viper.SetDefault("captcha_risk_enabled", true)
+	viper.SetDefault("captcha_risk_threshold", 50)
+	viper.SetDefault("captcha_risk_failure_window", 1*time.Hour)
+	viper.SetDefault("captcha_risk_known_device_ttl", 90*24*time.Hour)
+	viper.SetDefault("captcha_risk_ip_failure_weight", 10)
+	viper.SetDefault("captcha_risk_account_failure_weight", 15)
+	viper.SetDefault("captcha_risk_new_device_weight", 20)
+	viper.SetDefault("captcha_risk_blocked_network_weight", 100)
+	// CIDR ranges or single IP addresses
+	viper.SetDefault("captcha_risk_blocked_networks", []string{})
+	// e.g. "AS14061" or "14061"
+	viper.SetDefault("captcha_risk_blocked_asns", []string{})
+	// Header with the ASN of the client set by a trusted proxy. Blocked ASNs are
+	// ignored when it's empty. Make sure clients can't set it themselves.
+	viper.SetDefault("captcha_risk_asn_header", "")
 
 	// Transactional Emails
 	viper.SetDefault("transactional_emails_enabled", true)
-	viper.SetDefault("transactional_emails_daily_global_limit", 500)
+	// Sliding window limits on the total volume across all budgets. The
+	// deprecated "transactional_emails_daily_global_limit" overrides the limit
+	// of the 24h window when it's set.
+	viper.SetDefault("transactional_emails_global_limits", []map[string]any{
+		{"window": 1 * time.Minute, "limit": 20},
+		{"window": 1 * time.Hour, "limit": 200},
+		{"window": 24 * time.Hour, "limit": 500},
+	})
+	// Sliding window limits. Critical emails (e.g. password resets) have their
+	// own budget so that non-critical traffic can't starve them.
+	viper.SetDefault("transactional_emails_rate_limits", []map[string]any{
+		{"scope": "global", "budget": "non_critical", "window": 24 * time.Hour, "limit": 400},
+		{"scope": "recipient", "budget": "non_critical", "window": 1 * time.Hour, "limit": 3},
+		{"scope": "recipient", "budget": "critical", "window": 1 * time.Hour, "limit": 5},
+		{"scope": "recipient_domain", "budget": "non_critical", "window": 1 * time.Hour, "limit": 100},
+		{"scope": "requester_ip", "budget": "non_critical", "window": 1 * time.Hour, "limit": 10},
+		{"scope": "requester_ip", "budget": "critical", "window": 1 * time.Hour, "limit": 10},
+		{"scope": "user", "budget": "non_critical", "window": 24 * time.Hour, "limit": 10},
+		{"scope": "user", "budget": "critical", "window": 24 * time.Hour, "limit": 10},
+	})
 	viper.SetDefault("transactional_emails_sender_email", "noreply@example.com.com")
 	viper.SetDefault("transactional_emails_sender_name", "Go API Template")
+	// No default for webhook secret
+	viper.SetDefault("transactional_emails_webhook_tolerance", 5*time.Minute)
 	// No default for Scaleway access key ID
 	// No default for Scaleway secret key
 	viper.SetDefault("transactional_emails_scaleway_region", "fr-par")
 	// No default for Scaleway project ID
 
+	// Outbound HTTP clients (captcha, email provider, blocklist sync)
+	viper.SetDefault("http_client_timeout", 10*time.Second)
+	viper.SetDefault("http_client_dial_timeout", 5*time.Second)
+	viper.SetDefault("http_client_tls_handshake_timeout", 5*time.Second)
+	viper.SetDefault("http_client_response_header_timeout", 10*time.Second)
+	viper.SetDefault("http_client_idle_conn_timeout", 90*time.Second)
+	viper.SetDefault("http_client_max_idle_conns", 100)
+	viper.SetDefault("http_client_max_idle_conns_per_host", 10)
+	viper.SetDefault("http_client_max_conns_per_host", 100)
+	viper.SetDefault("http_client_max_redirects", 5)
+	// Only idempotent requests are retried
+	viper.SetDefault("http_client_max_retries", 2)
+	viper.SetDefault("http_client_retry_base_delay", 100*time.Millisecond)
+	viper.SetDefault("http_client_retry_max_delay", 2*time.Second)
+	// Set the threshold to 0 to disable the circuit breaker
+	viper.SetDefault("http_client_circuit_breaker_failure_threshold", 5)
+	viper.SetDefault("http_client_circuit_breaker_open_timeout", 30*time.Second)
+	// Per-integration timeouts ("captcha", "scaleway", "email_blocklist")
+	viper.SetDefault("http_client_timeout_overrides", map[string]time.Duration{
+		"email_blocklist": 60 * time.Second,
+	})
+
 	// Tasks
 	viper.SetDefault("tasks_redis_addr", "localhost:6379")
 	viper.SetDefault("tasks_redis_password", "")
+	viper.SetDefault("tasks_concurrency", 10)
+	// Queue weights, e.g. "critical" is processed 6 times as often as "low".
+	// Every queue the task policies route to must be listed.
+	viper.SetDefault("tasks_queues", map[string]int{
+		"critical": 6,
+		"default":  3,
+		"low":      1,
+	})
+	// Process lower priority queues only when the higher ones are empty
+	viper.SetDefault("tasks_strict_priority", false)
+	// Slack-compatible incoming webhook notified when a task exhausts its
+	// retries. No alerts are sent when it's empty.
+	viper.SetDefault("tasks_failure_alert_webhook_url", "")
+	// How long the worker waits for the tasks in flight on shutdown before
+	// putting them back to their queues
+	viper.SetDefault("tasks_shutdown_timeout", 30*time.Second)
+	// Periodic tasks. The email blocklist sync is scheduled separately by
+	// "authentication_email_blocklist_sync_schedule" when the sync URL is set.
+	// The email limits only count the attempts in their windows, so they don't
+	// depend on the cleanup task.
+	viper.SetDefault("tasks_schedules", []map[string]any{
+		{
+			"name":      "cleanup_email_send_attempts",
+			"cron":      "0 * * * *",
+			"task_type": "cleanup_email_send_attempts",
+		},
+		{
+			"name":      "cleanup_expired_tokens",
+			"cron":      "30 * * * *",
+			"task_type": "cleanup_expired_tokens",
+		},
+	})
+	// Only the scheduler replica holding the lock enqueues the periodic tasks.
+	// Another replica takes over within this time if the leader dies.
+	viper.SetDefault("tasks_scheduler_leader_lock_ttl", 15*time.Second)
+
+	// Health probes of the worker and the scheduler. A probe server is disabled
+	// when its address is empty.
+	viper.SetDefault("worker_probe_listen_addr", ":3334")
+	viper.SetDefault("scheduler_probe_listen_addr", ":3335")
+	// Serve the metrics on the probe servers as well
+	viper.SetDefault("probe_metrics_enabled", true)
+	viper.SetDefault("probe_check_timeout", 2*time.Second)
+
+	// Readiness probe of the API server. It checks the database, Redis and the
+	// downstream services listed in "health_checks".
+	viper.SetDefault("health_check_timeout", 2*time.Second)
+	// The results are reused for this long, so that frequent probes don't load
+	// the dependencies
+	viper.SetDefault("health_check_cache_ttl", 1*time.Second)
+	viper.SetDefault("health_checks", []map[string]any{})
+
+	// Any secret setting, i.e. tagged `secret`, can be read from a file instead,
+	// e.g. a Docker or Kubernetes secret, with the path in "<setting>_file", or
+	// from Vault with the path and the key in "<setting>_vault", e.g.
+	// "app/database#url". The secrets are re-read this often to pick up their
+	// rotations, 0 disables it.
+	viper.SetDefault("secrets_refresh_interval", 1*time.Minute)
+	// Vault or a compatible store with a KV version 2 secrets engine. The token
+	// can be read from a file, but not from Vault.
+	viper.SetDefault("secrets_vault_address", "")
+	viper.SetDefault("secrets_vault_token", "")
+	viper.SetDefault("secrets_vault_mount", "secret")
+	viper.SetDefault("secrets_vault_timeout", 5*time.Second)
+
+	config, err := readSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	secretStore, err := readSecrets(context.Background(), config)
+	if err != nil {
+		return nil, err
+	}
+
+	config.secrets = secretStore
 
+	return config, nil
+}
+
+// Reads the config file and the environment variables without the secrets
+// from their files and secret stores. The global viper instance isn't safe for
+// concurrent use, so it must not be called concurrently.
+func readSettings() (*Config, error) {
 	if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
 			return nil, err
@@ -177,45 +599,37 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 
-	config.TransactionalEmailsScalewayRegion = parseScalewayRegion(config.TransactionalEmailsScalewayRegionRaw)
-	config.AuthenticationEmailBlocklist = loadAuthenticationEmailBlocklist()
+	// An invalid region is reported by `Validate`
+	config.TransactionalEmailsScalewayRegion, _ = scw.ParseRegion(config.TransactionalEmailsScalewayRegionRaw)
+
+	applyDailyGlobalLimit(config)
 
 	return config, nil
 }
 
-func parseScalewayRegion(s string) scw.Region {
-	region, err := scw.ParseRegion(s)
-
-	if err != nil {
-		panic("invalid Scaleway region: " + s)
+// Maps the deprecated daily global limit onto the 24h window of the global
+// limits, so that the deployments that still set it keep their limit
+func applyDailyGlobalLimit(config *Config) {
+	if config.TransactionalEmailsDailyGlobalLimit == nil {
+		return
 	}
 
-	return region
-}
+	limit := *config.TransactionalEmailsDailyGlobalLimit
 
-// See https://github.com/disposable-email-domains/disposable-email-domains
-func loadAuthenticationEmailBlocklist() map[string]struct{} {
-	list := make(map[string]struct{})
+	// The slice may be shared with the defaults of viper
+	globalLimits := slices.Clone(config.TransactionalEmailsGlobalLimits)
 
-	f, err := os.Open("./config/email-blocklist.conf")
-	if err != nil {
-		panic(err)
-	}
-	defer f.Close()
+	for i := range globalLimits {
+		if globalLimits[i].Window == 24*time.Hour {
+			globalLimits[i].Limit = limit
+			config.TransactionalEmailsGlobalLimits = globalLimits
 
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
+			return
 		}
-
-		list[line] = struct{}{}
-	}
-
-	if err := scanner.Err(); err != nil {
-		panic(err)
 	}
 
-	return list
+	config.TransactionalEmailsGlobalLimits = append(
+		globalLimits,
+		TransactionalEmailsGlobalLimit{Window: 24 * time.Hour, Limit: limit},
+	)
 }
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const maskedValue = "[MASKED]"
+
+// Returns the effective config keyed like the config file, with the values of
+// the fields tagged `secret:"true"` masked and the passwords removed from the
+// URLs of the fields tagged `secret:"url"`. Empty secrets are left empty, so
+// that the missing ones stand out.
+func (c *Config) Masked() map[string]any {
+	return maskedStruct(reflect.ValueOf(c).Elem())
+}
+
+func maskedStruct(value reflect.Value) map[string]any {
+	result := make(map[string]any, value.NumField())
+
+	for i := range value.NumField() {
+		field := value.Type().Field(i)
+
+		// Derived fields, e.g. the parsed Scaleway region, aren't read from the
+		// config
+		if field.Tag.Get("mapstructure") == "" {
+			continue
+		}
+
+		key := strings.ToLower(mapstructureName(field))
+		fieldValue := value.Field(i)
+
+		switch secret := field.Tag.Get("secret"); {
+		case secret == "" || fieldValue.IsZero():
+			result[key] = maskedAny(fieldValue)
+		case secret == "url":
+			result[key] = maskedURL(fieldValue.String())
+		default:
+			result[key] = maskedValue
+		}
+	}
+
+	return result
+}
+
+func maskedAny(value reflect.Value) any {
+	if duration, ok := value.Interface().(time.Duration); ok {
+		return duration.String()
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		return maskedStruct(value)
+	case reflect.Slice:
+		if value.Type().Elem().Kind() != reflect.Struct {
+			return value.Interface()
+		}
+
+		result := make([]any, value.Len())
+		for i := range value.Len() {
+			result[i] = maskedAny(value.Index(i))
+		}
+
+		return result
+	case reflect.Map:
+		if value.Type().Elem() != reflect.TypeFor[time.Duration]() {
+			return value.Interface()
+		}
+
+		result := make(map[string]string, value.Len())
+		for iter := value.MapRange(); iter.Next(); {
+			result[iter.Key().String()] = iter.Value().Interface().(time.Duration).String()
+		}
+
+		return result
+	default:
+		return value.Interface()
+	}
+}
+
+func maskedURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return maskedValue
+	}
+
+	return parsed.Redacted()
+}
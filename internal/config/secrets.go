@@ -0,0 +1,117 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"prutya/go-api-template/internal/secrets"
+)
+
+var ErrSecretSetMoreThanOnce = errors.New("secret is set more than once")
+
+// Returns the secret setting, e.g. "database_url". Its value follows the
+// rotations in its file or secret store, unlike the field of the config, which
+// keeps the value read at startup.
+func (c *Config) Secret(key string) *secrets.Secret {
+	secret := c.secrets.Get(key)
+	if secret == nil {
+		panic("unknown secret setting: " + key)
+	}
+
+	return secret
+}
+
+// Re-reads the secrets from their files and secret stores every
+// "secrets_refresh_interval"
+func (c *Config) WatchSecrets(ctx context.Context) {
+	c.secrets.Watch(ctx, c.SecretsRefreshInterval)
+}
+
+// Reads the secrets from the providers referenced by the settings with their
+// names, e.g. "database_url_file" or "database_url_vault", into the fields
+// tagged `secret`
+func readSecrets(ctx context.Context, config *Config) (*secrets.Store, error) {
+	store := secrets.NewStore()
+	fileProvider := secrets.NewFileProvider()
+
+	// The Vault token can't be stored in Vault itself
+	vaultTokenField, _ := reflect.TypeFor[Config]().FieldByName("SecretsVaultToken")
+
+	if err := readSecret(ctx, store, config, vaultTokenField, map[string]secrets.Provider{"file": fileProvider}); err != nil {
+		return nil, err
+	}
+
+	providers := map[string]secrets.Provider{
+		"file": fileProvider,
+	}
+
+	if config.SecretsVaultAddress != "" {
+		providers["vault"] = secrets.NewVaultProvider(
+			&http.Client{Timeout: config.SecretsVaultTimeout},
+			config.SecretsVaultAddress,
+			config.SecretsVaultMount,
+			store.Get(strings.ToLower(mapstructureName(vaultTokenField))).Value,
+		)
+	}
+
+	var errs []error
+
+	for _, field := range reflect.VisibleFields(reflect.TypeFor[Config]()) {
+		if field.Tag.Get("secret") == "" || field.Name == vaultTokenField.Name {
+			continue
+		}
+
+		if err := readSecret(ctx, store, config, field, providers); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return store, nil
+}
+
+func readSecret(
+	ctx context.Context,
+	store *secrets.Store,
+	config *Config,
+	field reflect.StructField,
+	providers map[string]secrets.Provider,
+) error {
+	key := strings.ToLower(mapstructureName(field))
+	value := reflect.ValueOf(config).Elem().FieldByIndex(field.Index)
+
+	for name, provider := range providers {
+		referenceKey := key + "_" + name
+
+		reference := viper.GetString(referenceKey)
+		if reference == "" {
+			continue
+		}
+
+		if value.String() != "" {
+			return fmt.Errorf("%w: %s", ErrSecretSetMoreThanOnce, envPrefix+strings.ToUpper(key))
+		}
+
+		secret, err := store.Load(ctx, key, provider, reference)
+		if err != nil {
+			return fmt.Errorf("%s: %w", envPrefix+strings.ToUpper(referenceKey), err)
+		}
+
+		value.SetString(secret.Value())
+	}
+
+	if store.Get(key) == nil {
+		store.Set(key, value.String())
+	}
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/robfig/cron/v3"
+	"github.com/scaleway/scaleway-sdk-go/scw"
+)
+
+// Prefix of the environment variables, see `Load`
+const envPrefix = "APP_"
+
+var ErrInvalidConfig = errors.New("invalid config")
+
+var validate = newValidator()
+
+// Describes the formats checked by the validation tags
+var formatNames = map[string]string{
+	"cidr":            "CIDR",
+	"cron":            "cron spec",
+	"email":           "email address",
+	"scaleway_region": "Scaleway region",
+	"timezone":        "IANA time zone",
+	"url":             "URL",
+}
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// Report the fields by their environment variable names
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		return mapstructureName(field)
+	})
+
+	v.RegisterValidation("cron", func(fl validator.FieldLevel) bool {
+		_, err := cron.ParseStandard(fl.Field().String())
+
+		return err == nil
+	})
+
+	v.RegisterValidation("scaleway_region", func(fl validator.FieldLevel) bool {
+		_, err := scw.ParseRegion(fl.Field().String())
+
+		return err == nil
+	})
+
+	return v
+}
+
+// Checks the whole config and reports all the problems at once
+func (c *Config) Validate() error {
+	err := validate.Struct(c)
+	if err == nil {
+		return nil
+	}
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return err
+	}
+
+	problems := make([]string, len(validationErrors))
+	for i, fieldError := range validationErrors {
+		problems[i] = "  " + describeFieldError(fieldError)
+	}
+
+	return fmt.Errorf("%w:\n%s", ErrInvalidConfig, strings.Join(problems, "\n"))
+}
+
+func describeFieldError(fieldError validator.FieldError) string {
+	name := envName(fieldError.Namespace())
+	param := fieldError.Param()
+
+	switch fieldError.Tag() {
+	case "required":
+		return name + " is required"
+	case "required_if":
+		return name + " is required when " + describeConditions(param)
+	case "required_with":
+		return name + " is required when " + fieldEnvName(param) + " is set"
+	case "oneof":
+		return name + " must be one of: " + strings.Join(strings.Fields(param), ", ")
+	case "gt":
+		return name + " must be greater than " + param
+	case "gte", "min":
+		return name + " must be at least " + param
+	case "gtefield":
+		return name + " must be at least " + fieldEnvName(param)
+	case "lt":
+		return name + " must be less than " + param
+	case "lte", "max":
+		return name + " must be at most " + param
+	case "ltfield":
+		return name + " must be less than " + fieldEnvName(param)
+	default:
+		format, found := formatNames[fieldError.Tag()]
+		if !found {
+			format = fieldError.Tag()
+		}
+
+		return name + " must be a valid " + format
+	}
+}
+
+// Formats the field-value pairs of a conditional rule, e.g.
+// "APP_CAPTCHA_ENABLED=true and APP_CAPTCHA_PROVIDER=turnstile"
+func describeConditions(param string) string {
+	fields := strings.Fields(param)
+	conditions := make([]string, 0, len(fields)/2)
+
+	for i := 0; i+1 < len(fields); i += 2 {
+		conditions = append(conditions, fieldEnvName(fields[i])+"="+fields[i+1])
+	}
+
+	return strings.Join(conditions, " and ")
+}
+
+// Turns a validator namespace, e.g. "Config.TASKS_SCHEDULES[0].NAME", into the
+// name of the setting, e.g. "APP_TASKS_SCHEDULES[0].NAME"
+func envName(namespace string) string {
+	_, name, _ := strings.Cut(namespace, ".")
+
+	return envPrefix + name
+}
+
+// Returns the environment variable name of a top-level field of the config
+func fieldEnvName(fieldName string) string {
+	field, found := reflect.TypeFor[Config]().FieldByName(fieldName)
+	if !found {
+		return fieldName
+	}
+
+	return envPrefix + mapstructureName(field)
+}
+
+func mapstructureName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+	if name == "" {
+		return field.Name
+	}
+
+	return name
+}
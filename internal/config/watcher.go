@@ -0,0 +1,206 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+
+	"prutya/go-api-template/internal/logger"
+)
+
+// Called after every reload that changed the config. The snapshots must not be
+// modified.
+type Subscriber func(ctx context.Context, previous *Config, current *Config)
+
+// Holds the current config and reloads it when the config file changes or the
+// process receives SIGHUP. Only the fields tagged `reload` are reloaded, the
+// others keep their values until the process restarts. The secrets follow
+// their rotations instead, see `Config.Secret`.
+type Watcher struct {
+	current atomic.Pointer[Config]
+
+	// Serializes the reloads and the subscriptions, so that every subscriber
+	// sees every snapshot in order
+	mutex       sync.Mutex
+	subscribers []Subscriber
+}
+
+func NewWatcher(config *Config) *Watcher {
+	watcher := &Watcher{}
+	watcher.current.Store(config)
+
+	return watcher
+}
+
+// Returns the latest snapshot of the config
+func (w *Watcher) Current() *Config {
+	return w.current.Load()
+}
+
+func (w *Watcher) Subscribe(subscriber Subscriber) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.subscribers = append(w.subscribers, subscriber)
+}
+
+// Reads the config again and pushes the new snapshot to the subscribers if a
+// reloadable field changed. The changes of the other fields are ignored with a
+// warning. An invalid config is rejected as a whole. The secrets aren't read
+// again, they follow their rotations on their own.
+func (w *Watcher) Reload(ctx context.Context) error {
+	logger := logger.MustFromContext(ctx)
+
+	// Also keeps viper from reading the config concurrently
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	loaded, err := readSettings()
+	if err != nil {
+		return err
+	}
+
+	previous := w.current.Load()
+	current := *previous
+
+	previousValue := reflect.ValueOf(previous).Elem()
+	loadedValue := reflect.ValueOf(loaded).Elem()
+	currentValue := reflect.ValueOf(&current).Elem()
+
+	var reloaded []string
+	var ignored []string
+
+	for _, field := range reflect.VisibleFields(reflect.TypeFor[Config]()) {
+		if field.Tag.Get("mapstructure") == "" || field.Tag.Get("secret") != "" {
+			continue
+		}
+
+		loadedFieldValue := loadedValue.FieldByIndex(field.Index)
+		if reflect.DeepEqual(previousValue.FieldByIndex(field.Index).Interface(), loadedFieldValue.Interface()) {
+			continue
+		}
+
+		key := strings.ToLower(mapstructureName(field))
+
+		if field.Tag.Get("reload") == "" {
+			ignored = append(ignored, key)
+
+			continue
+		}
+
+		currentValue.FieldByIndex(field.Index).Set(loadedFieldValue)
+		reloaded = append(reloaded, key)
+	}
+
+	if len(ignored) > 0 {
+		logger.WarnContext(ctx, "Config changes that require a restart were ignored", "settings", ignored)
+	}
+
+	if len(reloaded) == 0 {
+		return nil
+	}
+
+	if err := current.Validate(); err != nil {
+		return err
+	}
+
+	w.current.Store(&current)
+
+	logger.InfoContext(ctx, "Config reloaded", "settings", reloaded)
+
+	for _, subscriber := range w.subscribers {
+		subscriber(ctx, previous, &current)
+	}
+
+	return nil
+}
+
+// Reloads the config whenever the config file changes or the process receives
+// SIGHUP, until the context is done. The reloads run on a single goroutine.
+func (w *Watcher) Watch(ctx context.Context) {
+	logger := logger.MustFromContext(ctx)
+
+	// Nil when there's no config file, receiving from it blocks forever
+	var events chan fsnotify.Event
+	var errs chan error
+
+	configFile := viper.ConfigFileUsed()
+	if configFile != "" {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to watch the config file", "error", err)
+		} else if err := watcher.Add(filepath.Dir(configFile)); err != nil {
+			logger.ErrorContext(ctx, "Failed to watch the config file", "error", err)
+			watcher.Close()
+		} else {
+			events = watcher.Events
+			errs = watcher.Errors
+
+			go func() {
+				<-ctx.Done()
+				watcher.Close()
+			}()
+		}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	reload := func() {
+		if err := w.Reload(ctx); err != nil {
+			logger.ErrorContext(ctx, "Failed to reload the config", "error", err)
+		}
+	}
+
+	go func() {
+		defer signal.Stop(signals)
+
+		configFile = filepath.Clean(configFile)
+		realConfigFile, _ := filepath.EvalSymlinks(configFile)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+				logger.InfoContext(ctx, "SIGHUP received, reloading the config")
+
+				reload()
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+
+				// A ConfigMap volume replaces the file by swapping a symlink
+				// in the directory, which only shows in the resolved path
+				currentConfigFile, _ := filepath.EvalSymlinks(configFile)
+				changed := filepath.Clean(event.Name) == configFile && (event.Has(fsnotify.Write) || event.Has(fsnotify.Create))
+
+				if !changed && (currentConfigFile == "" || currentConfigFile == realConfigFile) {
+					continue
+				}
+
+				realConfigFile = currentConfigFile
+
+				logger.InfoContext(ctx, "Config file changed, reloading", "path", event.Name, "op", event.Op.String())
+
+				reload()
+			case err, ok := <-errs:
+				if !ok {
+					return
+				}
+
+				logger.ErrorContext(ctx, "Config file watcher error", "error", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"prutya/go-api-template/internal/logger"
+)
+
+// Reads a valid config from the environment, like at startup. The returned
+// function returns the log output so far.
+func newTestWatcher(t *testing.T) (*Watcher, context.Context, func() string) {
+	t.Helper()
+
+	config := loadTestConfig(t)
+
+	// The handlers write to the stdout they're created with
+	logPath := filepath.Join(t.TempDir(), "log")
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { logFile.Close() })
+
+	stdout := os.Stdout
+	os.Stdout = logFile
+	testLogger, err := logger.New("info", "text", false, nil, nil, nil)
+	os.Stdout = stdout
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logs := func() string {
+		content, err := os.ReadFile(logPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return string(content)
+	}
+
+	return NewWatcher(config), logger.NewContext(context.Background(), testLogger), logs
+}
+
+func TestReloadAppliesReloadableFields(t *testing.T) {
+	watcher, ctx, _ := newTestWatcher(t)
+	previous := watcher.Current()
+
+	var notified []*Config
+	watcher.Subscribe(func(ctx context.Context, previous *Config, current *Config) {
+		notified = append(notified, previous, current)
+	})
+
+	t.Setenv("APP_LOG_LEVEL", "debug")
+
+	if err := watcher.Reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	current := watcher.Current()
+
+	if current.LogLevel != "debug" {
+		t.Errorf("expected the log level to be reloaded, got %q", current.LogLevel)
+	}
+
+	if previous.LogLevel != "info" {
+		t.Errorf("expected the previous snapshot to be left as is, got %q", previous.LogLevel)
+	}
+
+	if len(notified) != 2 || notified[0] != previous || notified[1] != current {
+		t.Errorf("expected the subscriber to get the previous and the current snapshots")
+	}
+}
+
+func TestReloadIgnoresOtherFields(t *testing.T) {
+	watcher, ctx, logs := newTestWatcher(t)
+	previous := watcher.Current()
+
+	notified := false
+	watcher.Subscribe(func(ctx context.Context, previous *Config, current *Config) {
+		notified = true
+	})
+
+	t.Setenv("APP_LISTEN_ADDR", ":4444")
+
+	if err := watcher.Reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if watcher.Current() != previous {
+		t.Errorf("expected the snapshot to be kept")
+	}
+
+	if notified {
+		t.Errorf("expected the subscriber not to be notified")
+	}
+
+	if output := logs(); !strings.Contains(output, "Config changes that require a restart were ignored") ||
+		!strings.Contains(output, "listen_addr") {
+		t.Errorf("expected a warning about listen_addr, got %q", output)
+	}
+}
+
+func TestReloadRejectsInvalidConfig(t *testing.T) {
+	watcher, ctx, _ := newTestWatcher(t)
+	previous := watcher.Current()
+
+	// The valid change is rejected along with the invalid one
+	t.Setenv("APP_CORS_MAX_AGE", "10m")
+	t.Setenv("APP_LOG_LEVEL", "verbose")
+
+	err := watcher.Reload(ctx)
+	if err == nil || !strings.Contains(err.Error(), "APP_LOG_LEVEL") {
+		t.Fatalf("expected the log level to be reported, got %v", err)
+	}
+
+	if watcher.Current() != previous {
+		t.Errorf("expected the snapshot to be kept")
+	}
+}
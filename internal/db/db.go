@@ -1,22 +1,27 @@
 package db
 
 import (
+	"context"
 	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/pgdialect"
+
+	"prutya/go-api-template/internal/metrics"
 )
 
+// The credentials are read from the URL for every new connection, so that
+// rotated ones are picked up
 func New(
-	url string,
+	url func() string,
 	maxOpenConns int,
 	maxIdleConns int,
 	maxConnLifetime time.Duration,
 	maxConnIdleTime time.Duration,
 ) (*bun.DB, error) {
-	dbConfig, err := pgx.ParseConfig(url)
+	dbConfig, err := pgx.ParseConfig(url())
 	if err != nil {
 		return nil, err
 	}
@@ -26,12 +31,26 @@ func New(
 	// https://bun.uptrace.dev/postgres/#pgx
 	dbConfig.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol
 
-	sqldb := stdlib.OpenDB(*dbConfig)
+	sqldb := stdlib.OpenDB(*dbConfig, stdlib.OptionBeforeConnect(func(ctx context.Context, connConfig *pgx.ConnConfig) error {
+		currentConfig, err := pgx.ParseConfig(url())
+		if err != nil {
+			return err
+		}
+
+		connConfig.User = currentConfig.User
+		connConfig.Password = currentConfig.Password
+
+		return nil
+	}))
 	sqldb.SetMaxOpenConns(maxOpenConns)
 	sqldb.SetMaxIdleConns(maxIdleConns)
 	sqldb.SetConnMaxLifetime(maxConnLifetime)
 	sqldb.SetConnMaxIdleTime(maxConnIdleTime)
 
+	if err := metrics.RegisterDBStats(sqldb); err != nil {
+		return nil, err
+	}
+
 	db := bun.NewDB(sqldb, pgdialect.New())
 
 	db.AddQueryHook(QueryHook{})
@@ -7,8 +7,12 @@ import (
 	"time"
 
 	"github.com/uptrace/bun"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 
 	internal_logger "prutya/go-api-template/internal/logger"
+	"prutya/go-api-template/internal/metrics"
+	"prutya/go-api-template/internal/tracing"
 )
 
 type QueryHook struct {
@@ -16,19 +20,50 @@ type QueryHook struct {
 }
 
 func (qh QueryHook) BeforeQuery(ctx context.Context, event *bun.QueryEvent) context.Context {
+	ctx, span := tracing.Start(
+		ctx,
+		"db "+event.Operation(),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNamePostgreSQL,
+			semconv.DBOperationName(event.Operation()),
+		),
+	)
+
+	// The queries are interpolated and may contain secrets, so they're only
+	// recorded in debug builds when they're logged as well
+	if internal_logger.Debug && internal_logger.MustFromContext(ctx).Component("db").DebugEnabled(ctx) {
+		span.SetAttributes(semconv.DBQueryText(event.Query))
+	}
+
 	return ctx
 }
 
 func (qh QueryHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
-	logger := internal_logger.MustFromContext(ctx)
+	logger := internal_logger.MustFromContext(ctx).Component("db")
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
 
 	queryDuration := time.Since(event.StartTime)
-	query := event.Query
+	operation := event.Operation()
+
+	args := []any{"operation", operation, "duration", queryDuration}
+
+	// The queries are interpolated and may contain secrets, e.g. emails and
+	// token digests, so release builds only log the operation
+	if internal_logger.Debug {
+		args = append(args, "query", event.Query)
+	}
+
+	metrics.DBQueryDuration.WithLabelValues(operation).Observe(queryDuration.Seconds())
 
 	if event.Err != nil && !errors.Is(event.Err, sql.ErrNoRows) {
-		logger.ErrorContext(ctx, "SQL query error", "query", query, "duration", queryDuration, "error", event.Err)
+		metrics.DBQueryErrorsTotal.WithLabelValues(operation).Inc()
+		tracing.RecordError(span, event.Err)
+
+		logger.ErrorContext(ctx, "SQL query error", append(args, "error", event.Err)...)
 		return
 	}
 
-	logger.DebugContext(ctx, "SQL query", "query", query, "duration", queryDuration)
+	logger.DebugContext(ctx, "SQL query", args...)
 }
@@ -31,9 +31,24 @@ func NewRegisterHandler(authenticationService authentication_service.Authenticat
 		}
 
 		// Register
-		if err := authenticationService.Register(r.Context(), reqBody.Email, reqBody.Password); err != nil {
+		if err := authenticationService.Register(r.Context(), reqBody.Email, reqBody.Password, utils.GetClientIP(r)); err != nil {
 			logger.MustWarnContext(r.Context(), "Registration failed", "error", err.Error())
 
+			var undeliverableErr *authentication_service.EmailDomainUndeliverableError
+			if errors.As(err, &undeliverableErr) {
+				serverError := utils.NewServerError(utils.ErrCodeInvalidParams, http.StatusUnprocessableEntity)
+				serverError.Details = []utils.ServerErrorDetail{
+					{
+						Subject:    "email",
+						Constraint: "deliverable_domain",
+						Args:       undeliverableErr.Suggestion,
+					},
+				}
+
+				utils.RenderError(w, r, serverError)
+				return
+			}
+
 			if errors.Is(err, authentication_service.ErrEmailDomainNotAllowed) {
 				utils.RenderError(w, r, utils.NewServerError(err.Error(), http.StatusUnprocessableEntity))
 				return
@@ -32,7 +32,7 @@ func NewRequestEmailVerificationHandler(
 		}
 
 		// Request new verification email
-		if err := authenticationService.RequestNewVerificationEmail(r.Context(), reqBody.Email); err != nil {
+		if err := authenticationService.RequestNewVerificationEmail(r.Context(), reqBody.Email, utils.GetClientIP(r)); err != nil {
 			logger.MustWarnContext(r.Context(), "Email verification request failed", "error", err.Error())
 
 			if errors.Is(err, authentication_service.ErrEmailDomainNotAllowed) {
@@ -31,7 +31,7 @@ func NewRequestPasswordResetHandler(
 		}
 
 		// Request password reset
-		if err := authenticationService.RequestPasswordReset(r.Context(), reqBody.Email); err != nil {
+		if err := authenticationService.RequestPasswordReset(r.Context(), reqBody.Email, utils.GetClientIP(r)); err != nil {
 			logger.MustWarnContext(r.Context(), "Password reset request failed", "error", err.Error())
 
 			// We don't want to leak information about whether the email is already
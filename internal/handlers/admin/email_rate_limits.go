@@ -0,0 +1,62 @@
+package admin
+
+import (
+	"net/http"
+
+	"prutya/go-api-template/internal/handlers/utils"
+	"prutya/go-api-template/internal/services/transactional_email_service"
+)
+
+type EmailRateLimitsResponse struct {
+	Items []*EmailRateLimitsResponseItem `json:"items"`
+}
+
+type EmailRateLimitsResponseItem struct {
+	Scope         string                          `json:"scope"`
+	Budget        string                          `json:"budget"`
+	WindowSeconds int64                           `json:"windowSeconds"`
+	Limit         int                             `json:"limit"`
+	Top           []*EmailRateLimitsResponseUsage `json:"top"`
+}
+
+type EmailRateLimitsResponseUsage struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+func NewEmailRateLimitsHandler(
+	transactionalEmailService transactional_email_service.TransactionalEmailService,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		usages, err := transactionalEmailService.GetRateLimitsUsage(r.Context())
+		if err != nil {
+			utils.RenderError(w, r, err)
+			return
+		}
+
+		response := &EmailRateLimitsResponse{
+			Items: make([]*EmailRateLimitsResponseItem, len(usages)),
+		}
+
+		for i, usage := range usages {
+			item := &EmailRateLimitsResponseItem{
+				Scope:         usage.Scope,
+				Budget:        usage.Budget,
+				WindowSeconds: int64(usage.Window.Seconds()),
+				Limit:         usage.Limit,
+				Top:           make([]*EmailRateLimitsResponseUsage, len(usage.Top)),
+			}
+
+			for j, entry := range usage.Top {
+				item.Top[j] = &EmailRateLimitsResponseUsage{
+					Key:   entry.Key,
+					Count: entry.Count,
+				}
+			}
+
+			response.Items[i] = item
+		}
+
+		utils.RenderJson(w, r, response, http.StatusOK, nil)
+	}
+}
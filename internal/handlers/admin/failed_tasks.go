@@ -0,0 +1,214 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"prutya/go-api-template/internal/handlers/utils"
+	"prutya/go-api-template/internal/helpers"
+	"prutya/go-api-template/internal/models"
+	"prutya/go-api-template/internal/services/failed_task_service"
+)
+
+const defaultFailedTasksPageSize int = 50
+
+type FailedTasksListRequestQuery struct {
+	TaskType string `query:"taskType" validate:"omitempty,lte=128"`
+	PageSize int    `query:"pageSize" validate:"gte=1,lte=100"`
+	Before   string `query:"before" validate:"omitempty,uuid"`
+}
+
+type FailedTasksListResponse struct {
+	Items   []*FailedTaskResponse `json:"items"`
+	HasMore bool                  `json:"hasMore"`
+}
+
+type FailedTaskResponse struct {
+	ID         string   `json:"id"`
+	TaskID     string   `json:"taskId"`
+	TaskType   string   `json:"taskType"`
+	Queue      string   `json:"queue"`
+	Payload    string   `json:"payload"`
+	Error      string   `json:"error"`
+	ErrorChain []string `json:"errorChain"`
+	Attempts   int      `json:"attempts"`
+	MaxRetry   int      `json:"maxRetry"`
+	FailedAt   string   `json:"failedAt"`
+	RetriedAt  *string  `json:"retriedAt"`
+}
+
+type FailedTasksPurgeResponse struct {
+	Purged int `json:"purged"`
+}
+
+func NewFailedTasksListHandler(failedTaskService failed_task_service.FailedTaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		queryValues, err := url.ParseQuery(r.URL.RawQuery)
+		if err != nil {
+			utils.RenderError(w, r, utils.ErrInvalidQuery)
+			return
+		}
+
+		query := &FailedTasksListRequestQuery{
+			TaskType: queryValues.Get("taskType"),
+			PageSize: defaultFailedTasksPageSize,
+			Before:   queryValues.Get("before"),
+		}
+
+		// Get the page size from the query
+		if queryPageSize := queryValues.Get("pageSize"); queryPageSize != "" {
+			pageSize, err := strconv.Atoi(queryPageSize)
+			if err != nil {
+				utils.RenderError(w, r, utils.ErrInvalidQuery)
+				return
+			}
+
+			query.PageSize = pageSize
+		}
+
+		// Validate the query params
+		if err := utils.Validate.Struct(query); err != nil {
+			utils.RenderError(w, r, err)
+			return
+		}
+
+		failedTasks, hasMore, err := failedTaskService.List(r.Context(), query.TaskType, query.PageSize, query.Before)
+		if err != nil {
+			utils.RenderError(w, r, err)
+			return
+		}
+
+		response := &FailedTasksListResponse{
+			Items:   make([]*FailedTaskResponse, len(failedTasks)),
+			HasMore: hasMore,
+		}
+
+		for i, failedTask := range failedTasks {
+			response.Items[i] = newFailedTaskResponse(failedTask)
+		}
+
+		utils.RenderJson(w, r, response, http.StatusOK, nil)
+	}
+}
+
+func NewFailedTasksGetHandler(failedTaskService failed_task_service.FailedTaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		failedTaskID, ok := parseFailedTaskID(w, r)
+		if !ok {
+			return
+		}
+
+		failedTask, err := failedTaskService.Get(r.Context(), failedTaskID)
+		if err != nil {
+			renderFailedTaskError(w, r, err)
+			return
+		}
+
+		utils.RenderJson(w, r, newFailedTaskResponse(failedTask), http.StatusOK, nil)
+	}
+}
+
+func NewFailedTasksRetryHandler(failedTaskService failed_task_service.FailedTaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		failedTaskID, ok := parseFailedTaskID(w, r)
+		if !ok {
+			return
+		}
+
+		if err := failedTaskService.Retry(r.Context(), failedTaskID); err != nil {
+			renderFailedTaskError(w, r, err)
+			return
+		}
+
+		utils.RenderNoContent(w, r, nil)
+	}
+}
+
+func NewFailedTasksPurgeHandler(failedTaskService failed_task_service.FailedTaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		failedTaskID, ok := parseFailedTaskID(w, r)
+		if !ok {
+			return
+		}
+
+		if err := failedTaskService.Purge(r.Context(), failedTaskID); err != nil {
+			renderFailedTaskError(w, r, err)
+			return
+		}
+
+		utils.RenderNoContent(w, r, nil)
+	}
+}
+
+// Purges the tasks that failed before the time in the "before" query param
+// (RFC 3339). It's required so that everything isn't purged by accident.
+func NewFailedTasksPurgeBeforeHandler(failedTaskService failed_task_service.FailedTaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		before, err := time.Parse(time.RFC3339, r.URL.Query().Get("before"))
+		if err != nil {
+			utils.RenderError(w, r, utils.ErrInvalidQuery)
+			return
+		}
+
+		purged, err := failedTaskService.PurgeBefore(r.Context(), before)
+		if err != nil {
+			utils.RenderError(w, r, err)
+			return
+		}
+
+		utils.RenderJson(w, r, &FailedTasksPurgeResponse{Purged: purged}, http.StatusOK, nil)
+	}
+}
+
+func parseFailedTaskID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	failedTaskID := chi.URLParam(r, "failedTaskID")
+
+	if err := helpers.ValidateUUIDV7(failedTaskID); err != nil {
+		utils.RenderError(w, r, utils.NewServerError(err.Error(), http.StatusUnprocessableEntity))
+		return "", false
+	}
+
+	return failedTaskID, true
+}
+
+func renderFailedTaskError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, failed_task_service.ErrFailedTaskNotFound) {
+		utils.RenderError(w, r, utils.ErrNotFound)
+		return
+	}
+
+	if errors.Is(err, failed_task_service.ErrFailedTaskAlreadyRetried) {
+		utils.RenderError(w, r, utils.ErrConflict)
+		return
+	}
+
+	utils.RenderError(w, r, err)
+}
+
+func newFailedTaskResponse(failedTask *models.FailedTask) *FailedTaskResponse {
+	var retriedAt *string
+
+	if failedTask.RetriedAt.Valid {
+		formattedRetriedAt := failedTask.RetriedAt.Time.Format(time.RFC3339)
+		retriedAt = &formattedRetriedAt
+	}
+
+	return &FailedTaskResponse{
+		ID:         failedTask.ID,
+		TaskID:     failedTask.TaskID,
+		TaskType:   failedTask.TaskType,
+		Queue:      failedTask.Queue,
+		Payload:    string(failedTask.Payload),
+		Error:      failedTask.Error,
+		ErrorChain: failedTask.ErrorChain,
+		Attempts:   failedTask.Attempts,
+		MaxRetry:   failedTask.MaxRetry,
+		FailedAt:   failedTask.FailedAt.Format(time.RFC3339),
+		RetriedAt:  retriedAt,
+	}
+}
@@ -0,0 +1,77 @@
+package admin
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"prutya/go-api-template/internal/handlers/utils"
+	loggerpkg "prutya/go-api-template/internal/logger"
+)
+
+type LogLevelsRequest struct {
+	Default    string            `json:"default" validate:"required,oneof=debug info warn warning error"`
+	Components map[string]string `json:"components" validate:"dive,keys,required,lte=64,endkeys,oneof=debug info warn warning error"`
+}
+
+type LogLevelsResponse struct {
+	Default    string            `json:"default"`
+	Components map[string]string `json:"components"`
+}
+
+func NewLogLevelsGetHandler(levels *loggerpkg.Levels) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.RenderJson(w, r, newLogLevelsResponse(levels), http.StatusOK, nil)
+	}
+}
+
+// Replaces the levels of this process until it restarts. The components left
+// out follow the default level.
+func NewLogLevelsUpdateHandler(levels *loggerpkg.Levels) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reqBody := &LogLevelsRequest{}
+
+		if err := json.NewDecoder(r.Body).Decode(reqBody); err != nil {
+			utils.RenderInvalidJsonError(w, r)
+			return
+		}
+
+		if err := utils.Validate.Struct(reqBody); err != nil {
+			utils.RenderError(w, r, err)
+			return
+		}
+
+		// The levels are validated above
+		defaultLevel, _ := loggerpkg.ParseLevel(reqBody.Default)
+
+		componentLevels := make(map[string]slog.Level, len(reqBody.Components))
+		for component, levelStr := range reqBody.Components {
+			componentLevels[component], _ = loggerpkg.ParseLevel(levelStr)
+		}
+
+		levels.SetDefault(defaultLevel)
+		levels.SetComponents(componentLevels)
+
+		loggerpkg.MustWarnContext(
+			r.Context(),
+			"Log levels changed",
+			"default", reqBody.Default,
+			"components", reqBody.Components,
+		)
+
+		utils.RenderJson(w, r, newLogLevelsResponse(levels), http.StatusOK, nil)
+	}
+}
+
+func newLogLevelsResponse(levels *loggerpkg.Levels) *LogLevelsResponse {
+	response := &LogLevelsResponse{
+		Default:    loggerpkg.FormatLevel(levels.Default()),
+		Components: make(map[string]string),
+	}
+
+	for component, level := range levels.Components() {
+		response.Components[component] = loggerpkg.FormatLevel(level)
+	}
+
+	return response
+}
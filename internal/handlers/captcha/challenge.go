@@ -0,0 +1,57 @@
+package captcha
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"prutya/go-api-template/internal/handlers/utils"
+	"prutya/go-api-template/internal/services/captcha_service"
+)
+
+type ChallengeRequest struct {
+	Action string `json:"action" validate:"required,oneof=login register request_email_verification request_password_reset verify_password_reset_otp"`
+}
+
+type ChallengeResponse struct {
+	Challenge  string `json:"challenge"`
+	Difficulty int    `json:"difficulty"`
+	ExpiresAt  string `json:"expiresAt"`
+}
+
+// Issues a proof-of-work challenge for the action. Only available with the
+// "pow" captcha provider.
+func NewChallengeHandler(captchaService captcha_service.CaptchaService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse the request body
+		reqBody := &ChallengeRequest{}
+		if err := json.NewDecoder(r.Body).Decode(reqBody); err != nil {
+			utils.RenderInvalidJsonError(w, r)
+			return
+		}
+
+		// Validate the request body
+		if err := utils.Validate.Struct(reqBody); err != nil {
+			utils.RenderError(w, r, err)
+			return
+		}
+
+		challenge, err := captchaService.IssueChallenge(r.Context(), reqBody.Action)
+		if err != nil {
+			if errors.Is(err, captcha_service.ErrChallengesNotSupported) {
+				utils.RenderError(w, r, utils.ErrNotFound)
+				return
+			}
+
+			utils.RenderError(w, r, err)
+			return
+		}
+
+		utils.RenderJson(w, r, &ChallengeResponse{
+			Challenge:  challenge.Challenge,
+			Difficulty: challenge.Difficulty,
+			ExpiresAt:  challenge.ExpiresAt.UTC().Format(time.RFC3339),
+		}, http.StatusOK, nil)
+	}
+}
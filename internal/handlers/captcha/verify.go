@@ -0,0 +1,69 @@
+package captcha
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"prutya/go-api-template/internal/handlers/utils"
+	"prutya/go-api-template/internal/logger"
+	"prutya/go-api-template/internal/services/captcha_service"
+)
+
+type VerifyRequest struct {
+	Challenge string `json:"challenge" validate:"required,lte=1024"`
+	Solution  string `json:"solution" validate:"required,lte=64"`
+}
+
+type VerifyResponse struct {
+	// Sent in the "X-Captcha-Response" header of the protected request
+	Token     string `json:"token"`
+	ExpiresAt string `json:"expiresAt"`
+}
+
+// Exchanges a solved proof-of-work challenge for a single-use captcha response.
+// Only available with the "pow" captcha provider.
+func NewVerifyHandler(captchaService captcha_service.CaptchaService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse the request body
+		reqBody := &VerifyRequest{}
+		if err := json.NewDecoder(r.Body).Decode(reqBody); err != nil {
+			utils.RenderInvalidJsonError(w, r)
+			return
+		}
+
+		// Validate the request body
+		if err := utils.Validate.Struct(reqBody); err != nil {
+			utils.RenderError(w, r, err)
+			return
+		}
+
+		pass, err := captchaService.RedeemChallenge(r.Context(), reqBody.Challenge, reqBody.Solution)
+		if err != nil {
+			logger.MustWarnContext(r.Context(), "Captcha challenge verification failed", "error", err.Error())
+
+			if errors.Is(err, captcha_service.ErrChallengesNotSupported) {
+				utils.RenderError(w, r, utils.ErrNotFound)
+				return
+			}
+
+			if errors.Is(err, captcha_service.ErrInvalidChallenge) ||
+				errors.Is(err, captcha_service.ErrChallengeExpired) ||
+				errors.Is(err, captcha_service.ErrInvalidSolution) ||
+				errors.Is(err, captcha_service.ErrChallengeAlreadyUsed) {
+
+				utils.RenderError(w, r, utils.ErrInvalidCaptcha)
+				return
+			}
+
+			utils.RenderError(w, r, err)
+			return
+		}
+
+		utils.RenderJson(w, r, &VerifyResponse{
+			Token:     pass.Token,
+			ExpiresAt: pass.ExpiresAt.UTC().Format(time.RFC3339),
+		}, http.StatusOK, nil)
+	}
+}
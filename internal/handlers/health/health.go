@@ -4,12 +4,29 @@ import (
 	"net/http"
 
 	"prutya/go-api-template/internal/handlers/utils"
+	"prutya/go-api-template/internal/health"
 )
 
 var MessageOK = []byte(`{"health":"ok"}`)
 
+// Liveness probe. It passes as long as the process serves requests.
 func NewHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		utils.RenderRawJson(w, r, MessageOK, http.StatusOK, nil)
 	}
 }
+
+// Readiness probe. It fails when a dependency is unavailable or the server is
+// draining before shutting down.
+func NewReadinessHandler(readiness *health.Readiness) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := readiness.Report(r.Context())
+
+		httpStatus := http.StatusOK
+		if report.Status != health.StatusOK {
+			httpStatus = http.StatusServiceUnavailable
+		}
+
+		utils.RenderJson(w, r, report, httpStatus, nil)
+	}
+}
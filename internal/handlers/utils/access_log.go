@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Optional fields of the access log
+const AccessLogFieldRoute = "route"
+const AccessLogFieldClientIP = "client_ip"
+const AccessLogFieldUserAgent = "user_agent"
+const AccessLogFieldReferer = "referer"
+const AccessLogFieldUserID = "user_id"
+const AccessLogFieldBytesRead = "bytes_read"
+const AccessLogFieldBytesWritten = "bytes_written"
+
+// Formats of the additional access log for the tools that ingest web server
+// logs
+const AccessLogFormatCommon = "common"
+const AccessLogFormatCombined = "combined"
+
+var ErrUnknownAccessLogField = errors.New("unknown access log field")
+var ErrUnknownAccessLogFormat = errors.New("unknown access log format")
+
+var accessLogFields = []string{
+	AccessLogFieldRoute,
+	AccessLogFieldClientIP,
+	AccessLogFieldUserAgent,
+	AccessLogFieldReferer,
+	AccessLogFieldUserID,
+	AccessLogFieldBytesRead,
+	AccessLogFieldBytesWritten,
+}
+
+// Describes a request once it's handled
+type accessLogEntry struct {
+	request      *http.Request
+	route        string
+	status       int
+	bytesRead    int64
+	bytesWritten int
+	userID       string
+	startedAt    time.Time
+	duration     time.Duration
+}
+
+// Decides what the "Request ended" log line contains and optionally writes the
+// requests in the Common or Combined Log Format as well
+type AccessLog struct {
+	fields        []string
+	slowThreshold time.Duration
+	format        string
+
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+// The requests that take longer than the slow threshold are logged as
+// warnings, unless it's 0. The Common or Combined Log Format lines are written
+// to the path, or to stdout if it's empty, unless the format is empty.
+func NewAccessLog(fields []string, slowThreshold time.Duration, format string, path string) (*AccessLog, error) {
+	for _, field := range fields {
+		if !slices.Contains(accessLogFields, field) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownAccessLogField, field)
+		}
+	}
+
+	accessLog := &AccessLog{
+		fields:        fields,
+		slowThreshold: slowThreshold,
+		format:        format,
+	}
+
+	switch format {
+	case "":
+		return accessLog, nil
+	case AccessLogFormatCommon, AccessLogFormatCombined:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAccessLogFormat, format)
+	}
+
+	if path == "" {
+		accessLog.writer = os.Stdout
+
+		return accessLog, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	accessLog.writer = file
+
+	return accessLog, nil
+}
+
+func (a *AccessLog) isSlow(entry *accessLogEntry) bool {
+	return a.slowThreshold > 0 && entry.duration > a.slowThreshold
+}
+
+// Returns the configured fields of the entry as logger arguments
+func (a *AccessLog) args(entry *accessLogEntry) []any {
+	args := make([]any, 0, len(a.fields)*2)
+
+	for _, field := range a.fields {
+		switch field {
+		case AccessLogFieldRoute:
+			args = append(args, field, entry.route)
+		case AccessLogFieldClientIP:
+			args = append(args, field, GetClientIP(entry.request))
+		case AccessLogFieldUserAgent:
+			args = append(args, field, entry.request.UserAgent())
+		case AccessLogFieldReferer:
+			args = append(args, field, entry.request.Referer())
+		case AccessLogFieldUserID:
+			// Only the authenticated requests have a user
+			if entry.userID != "" {
+				args = append(args, field, entry.userID)
+			}
+		case AccessLogFieldBytesRead:
+			args = append(args, field, entry.bytesRead)
+		case AccessLogFieldBytesWritten:
+			args = append(args, field, entry.bytesWritten)
+		}
+	}
+
+	return args
+}
+
+// Writes the entry in the Common or Combined Log Format, if it's enabled
+func (a *AccessLog) write(entry *accessLogEntry) error {
+	if a.writer == nil {
+		return nil
+	}
+
+	r := entry.request
+
+	line := fmt.Sprintf(
+		"%s - %s [%s] %s %d %s",
+		GetClientIP(r),
+		clfValue(entry.userID),
+		entry.startedAt.Format("02/Jan/2006:15:04:05 -0700"),
+		strconv.Quote(r.Method+" "+r.URL.RequestURI()+" "+r.Proto),
+		entry.status,
+		clfBytes(entry.bytesWritten),
+	)
+
+	if a.format == AccessLogFormatCombined {
+		line += " " + strconv.Quote(clfValue(r.Referer())) + " " + strconv.Quote(clfValue(r.UserAgent()))
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	_, err := io.WriteString(a.writer, line+"\n")
+
+	return err
+}
+
+func clfValue(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
+
+func clfBytes(bytes int) string {
+	if bytes == 0 {
+		return "-"
+	}
+
+	return strconv.Itoa(bytes)
+}
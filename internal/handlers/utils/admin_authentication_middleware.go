@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"prutya/go-api-template/internal/logger"
+)
+
+// Protects the admin routes with a static bearer token. When the token is not
+// configured, all requests are rejected.
+func NewAdminAuthenticationMiddleware(adminAPIToken func() string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			logger := logger.MustFromContext(ctx)
+
+			// Read on every request, so that a rotated token is picked up
+			token := adminAPIToken()
+
+			if token == "" {
+				logger.WarnContext(ctx, "Admin API token is not set, admin routes are disabled")
+
+				RenderError(w, r, ErrUnauthorized)
+				return
+			}
+
+			tokenString, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !found || tokenString == "" {
+				logger.WarnContext(ctx, "Admin authorization header is missing or malformed")
+
+				RenderError(w, r, ErrUnauthorized)
+				return
+			}
+
+			if subtle.ConstantTimeCompare([]byte(tokenString), []byte(token)) != 1 {
+				logger.WarnContext(ctx, "Admin authentication failed")
+
+				RenderError(w, r, ErrUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"prutya/go-api-template/internal/logger"
 	"prutya/go-api-template/internal/services/authentication_service"
+	"prutya/go-api-template/internal/tasks"
 )
 
 type accessTokenClaimsContextKeyType struct{}
@@ -52,6 +53,14 @@ func NewAuthenticationMiddleware(authenticationService authentication_service.Au
 
 			// Store the access token claims in the context
 			ctx = NewContextWithAccessTokenClaims(ctx, accessTokenClaims)
+
+			// The tasks enqueued on behalf of the user can be tied back to them
+			ctx = tasks.NewContextWithUserID(ctx, accessTokenClaims.UserID)
+
+			// Let the access log show who made the request
+			if responseInfo, hasResponseInfo := GetRequestResponseInfo(r); hasResponseInfo {
+				responseInfo.UserID = accessTokenClaims.UserID
+			}
 			r = r.WithContext(ctx)
 
 			logger.InfoContext(ctx, "User authenticated", "user_id", accessTokenClaims.UserID)
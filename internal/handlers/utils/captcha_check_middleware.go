@@ -1,37 +1,146 @@
 package utils
 
 import (
+	"bytes"
+	"encoding/json"
+	"io"
 	"net/http"
 
+	"prutya/go-api-template/internal/metrics"
 	"prutya/go-api-template/internal/services/captcha_service"
+	"prutya/go-api-template/internal/services/risk_service"
 )
 
-func NewCaptchaCheckMiddleware(captchaService captcha_service.CaptchaService) func(next http.Handler) http.Handler {
+// Only this much of the body is read to find the account the request is made
+// for, larger bodies are passed through untouched
+const captchaCheckMaxPeekSize = 64 * 1024
+
+// The action identifies the protected route, score-based providers check that
+// the token was issued for it.
+//
+// A captcha is only required when the risk of the request is elevated. The
+// outcome of the request is fed back to the risk service: failures raise the
+// risk of the IP address and the account, a successful login remembers the
+// device.
+func NewCaptchaCheckMiddleware(
+	captchaService captcha_service.CaptchaService,
+	riskService risk_service.RiskService,
+	asnHeader string,
+	action string,
+) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
-			// Read captcha response from headers
-			captchaResponse := r.Header.Get("X-Captcha-Response")
+			signals := &risk_service.Signals{
+				IP:      GetClientIP(r),
+				Account: peekAccount(r),
+				Device:  risk_service.DeviceFingerprint(r.UserAgent(), r.Header.Get("Accept-Language")),
+			}
 
-			if len(captchaResponse) > 2048 {
-				RenderError(w, r, ErrInvalidCaptcha)
-				return
+			if asnHeader != "" {
+				signals.ASN = r.Header.Get(asnHeader)
 			}
 
-			// Verify captcha response
-			captchaValid, err := captchaService.Verify(r.Context(), captchaResponse, r.RemoteAddr)
-			if err != nil {
-				RenderError(w, r, err)
-				return
+			assessment := riskService.Assess(r.Context(), signals)
+
+			if assessment.RequiresCaptcha {
+				metrics.CaptchaRiskAssessmentsTotal.WithLabelValues(action, "challenged").Inc()
+
+				if !checkCaptcha(w, r, captchaService, action) {
+					return
+				}
+			} else {
+				metrics.CaptchaRiskAssessmentsTotal.WithLabelValues(action, "skipped").Inc()
 			}
 
-			if !captchaValid {
-				RenderError(w, r, ErrInvalidCaptcha)
+			next.ServeHTTP(w, r)
+
+			responseInfo, hasResponseInfo := GetRequestResponseInfo(r)
+			if !hasResponseInfo {
 				return
 			}
 
-			next.ServeHTTP(w, r)
+			switch {
+			case isFailedAttempt(responseInfo):
+				riskService.RecordFailure(r.Context(), signals)
+			case action == captcha_service.ActionLogin && responseInfo.HttpStatus == http.StatusOK:
+				riskService.RecordSuccess(r.Context(), signals)
+			}
 		}
 
 		return http.HandlerFunc(fn)
 	}
 }
+
+// Renders the error and returns false if the captcha response is missing or
+// invalid
+func checkCaptcha(
+	w http.ResponseWriter,
+	r *http.Request,
+	captchaService captcha_service.CaptchaService,
+	action string,
+) bool {
+	// Read captcha response from headers
+	captchaResponse := r.Header.Get("X-Captcha-Response")
+
+	if captchaResponse == "" {
+		RenderError(w, r, ErrCaptchaRequired)
+		return false
+	}
+
+	if len(captchaResponse) > 2048 {
+		RenderError(w, r, ErrInvalidCaptcha)
+		return false
+	}
+
+	// Verify captcha response
+	captchaValid, err := captchaService.Verify(r.Context(), captchaResponse, GetClientIP(r), action)
+	if err != nil {
+		RenderError(w, r, err)
+		return false
+	}
+
+	if !captchaValid {
+		RenderError(w, r, ErrInvalidCaptcha)
+		return false
+	}
+
+	return true
+}
+
+// Reads the email address from the JSON body and restores the body for the
+// handler
+func peekAccount(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+
+	peeked, err := io.ReadAll(io.LimitReader(r.Body, captchaCheckMaxPeekSize))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(peeked), r.Body), r.Body}
+	if err != nil {
+		return ""
+	}
+
+	body := struct {
+		Email string `json:"email"`
+	}{}
+
+	if err := json.Unmarshal(peeked, &body); err != nil {
+		return ""
+	}
+
+	return body.Email
+}
+
+// Rejections that a legitimate user rarely runs into, e.g. a wrong password or
+// OTP. Params validation errors don't count.
+func isFailedAttempt(responseInfo *ResponseInfo) bool {
+	switch responseInfo.HttpStatus {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusUnprocessableEntity:
+		return responseInfo.ErrorCode != "" && responseInfo.ErrorCode != ErrCodeInvalidParams
+	default:
+		return false
+	}
+}
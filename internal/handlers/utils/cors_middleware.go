@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/go-chi/cors"
+)
+
+// Applies a CORS policy that can be replaced while the server is running, e.g.
+// after the config is reloaded
+type CorsMiddleware struct {
+	cors atomic.Pointer[cors.Cors]
+}
+
+func NewCorsMiddleware(options cors.Options) *CorsMiddleware {
+	m := &CorsMiddleware{}
+	m.SetOptions(options)
+
+	return m
+}
+
+func (m *CorsMiddleware) SetOptions(options cors.Options) {
+	m.cors.Store(cors.New(options))
+}
+
+func (m *CorsMiddleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.cors.Load().Handler(next).ServeHTTP(w, r)
+	})
+}
@@ -14,6 +14,7 @@ const ErrCodeInvalidParams = "invalid_params"
 const ErrCodeTimeout = "timeout"
 const ErrCodeInvalidPayload = "invalid_payload"
 const ErrCodeInvalidCaptcha = "invalid_captcha"
+const ErrCodeCaptchaRequired = "captcha_required"
 const ErrCodeTooManyRequests = "too_many_requests"
 
 var ErrNotFound = NewServerError(ErrCodeNotFound, http.StatusNotFound)
@@ -25,6 +26,7 @@ var ErrConflict = NewServerError(ErrCodeConflict, http.StatusConflict)
 var ErrUnprocessableContent = NewServerError(ErrCodeUnprocessableContent, http.StatusUnprocessableEntity)
 var ErrInvalidPayload = NewServerError(ErrCodeInvalidPayload, http.StatusUnprocessableEntity)
 var ErrInvalidCaptcha = NewServerError(ErrCodeInvalidCaptcha, http.StatusUnprocessableEntity)
+var ErrCaptchaRequired = NewServerError(ErrCodeCaptchaRequired, http.StatusUnprocessableEntity)
 var ErrTimeout = NewServerError(ErrCodeTimeout, http.StatusGatewayTimeout)
 var ErrTooManyRequests = NewServerError(ErrCodeTooManyRequests, http.StatusTooManyRequests)
 
@@ -2,23 +2,64 @@ package utils
 
 import (
 	"context"
+	"io"
 	"net/http"
+	"slices"
+	"strconv"
 	"time"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
 	loggerpkg "prutya/go-api-template/internal/logger"
+	"prutya/go-api-template/internal/metrics"
 )
 
+// Route label of the requests that didn't match a route, so that random paths
+// don't create new series
+const unmatchedRoute = "unmatched"
+
+// Method label of the requests with a non-standard method, for the same reason
+const otherMethod = "other"
+
+var standardMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodConnect,
+	http.MethodOptions,
+	http.MethodTrace,
+}
+
 type ResponseInfo struct {
 	HttpStatus int
 	ErrorCode  string
 	InnerError error
+	// Set by the authentication middleware for the access log
+	UserID string
 }
 
 type responseInfoContextKeyType struct{}
 
 var responseInfoContextKey = responseInfoContextKeyType{}
 
-func NewLoggerMiddleware(logger *loggerpkg.Logger) func(http.Handler) http.Handler {
+// Counts the bytes of the request body read by the handlers
+type countingReadCloser struct {
+	io.ReadCloser
+	bytesRead int64
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.bytesRead += int64(n)
+
+	return n, err
+}
+
+func NewLoggerMiddleware(logger *loggerpkg.Logger, accessLog *AccessLog) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
 			var requestLogger *loggerpkg.Logger
@@ -44,29 +85,53 @@ func NewLoggerMiddleware(logger *loggerpkg.Logger) func(http.Handler) http.Handl
 				"url", r.URL.String(),
 			)
 
+			// Measure the request and response sizes
+			requestBody := &countingReadCloser{ReadCloser: r.Body}
+			r.Body = requestBody
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
 			// Measure the request duration
 			start := time.Now()
 
-			next.ServeHTTP(w, r)
+			metrics.HTTPRequestsInFlight.Inc()
 
-			duration := time.Since(start)
+			next.ServeHTTP(ww, r)
+
+			metrics.HTTPRequestsInFlight.Dec()
+
+			entry := &accessLogEntry{
+				request:      r,
+				route:        routePattern(r),
+				status:       responseStatus(ww, responseInfo),
+				bytesRead:    requestBody.bytesRead,
+				bytesWritten: ww.BytesWritten(),
+				userID:       responseInfo.UserID,
+				startedAt:    start,
+				duration:     time.Since(start),
+			}
+
+			observeRequest(entry)
+
+			args := append(
+				[]any{
+					"duration", entry.duration,
+					"status", entry.status,
+				},
+				accessLog.args(entry)...,
+			)
 
 			if responseInfo.ErrorCode != "" {
-				requestLogger.InfoContext(
-					r.Context(),
-					"Request ended",
-					"duration", duration,
-					"status", responseInfo.HttpStatus,
-					"error_code", responseInfo.ErrorCode,
-					"error", responseInfo.InnerError,
-				)
+				args = append(args, "error_code", responseInfo.ErrorCode, "error", responseInfo.InnerError)
+			}
+
+			if accessLog.isSlow(entry) {
+				requestLogger.WarnContext(r.Context(), "Request ended", append(args, "slow", true)...)
 			} else {
-				requestLogger.InfoContext(
-					r.Context(),
-					"Request ended",
-					"duration", duration,
-					"status", responseInfo.HttpStatus,
-				)
+				requestLogger.InfoContext(r.Context(), "Request ended", args...)
+			}
+
+			if err := accessLog.write(entry); err != nil {
+				requestLogger.ErrorContext(r.Context(), "Failed to write the access log", "error", err)
 			}
 		}
 
@@ -74,6 +139,38 @@ func NewLoggerMiddleware(logger *loggerpkg.Logger) func(http.Handler) http.Handl
 	}
 }
 
+func routePattern(r *http.Request) string {
+	if routeContext := chi.RouteContext(r.Context()); routeContext != nil && routeContext.RoutePattern() != "" {
+		return routeContext.RoutePattern()
+	}
+
+	return unmatchedRoute
+}
+
+// Handlers that write the response directly don't set the status in the
+// response info
+func responseStatus(ww middleware.WrapResponseWriter, responseInfo *ResponseInfo) int {
+	if responseInfo.HttpStatus != 0 {
+		return responseInfo.HttpStatus
+	}
+
+	if ww.Status() != 0 {
+		return ww.Status()
+	}
+
+	return http.StatusOK
+}
+
+func observeRequest(entry *accessLogEntry) {
+	method := entry.request.Method
+	if !slices.Contains(standardMethods, method) {
+		method = otherMethod
+	}
+
+	metrics.HTTPRequestsTotal.WithLabelValues(method, entry.route, strconv.Itoa(entry.status)).Inc()
+	metrics.HTTPRequestDuration.WithLabelValues(method, entry.route).Observe(entry.duration.Seconds())
+}
+
 func SetRequestLogger(r *http.Request, logger *loggerpkg.Logger) *http.Request {
 	ctxWithLogger := loggerpkg.NewContext(r.Context(), logger)
 
@@ -3,6 +3,8 @@ package utils
 import (
 	"context"
 	"net/http"
+
+	"prutya/go-api-template/internal/tasks"
 )
 
 type RequestIdContextKeyType struct{}
@@ -31,6 +33,12 @@ func NewRequestIDMiddleware(generateRequestID GenerateRequestIDFunc) func(http.H
 
 			r = SetRequestId(r, requestId)
 
+			// The tasks enqueued while handling the request carry its ID to the worker
+			r = r.WithContext(tasks.NewContextWithMetadata(r.Context(), tasks.Metadata{
+				RequestID: requestId,
+				Origin:    "http " + r.Method + " " + r.URL.Path,
+			}))
+
 			next.ServeHTTP(w, r)
 		}
 
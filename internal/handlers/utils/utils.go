@@ -3,6 +3,7 @@ package utils
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"strconv"
 
@@ -144,3 +145,15 @@ func RenderNoContent(
 		responseInfo.HttpStatus = http.StatusNoContent
 	}
 }
+
+// Returns the IP address of the client without the port. `middleware.RealIP`
+// sets `RemoteAddr` to a bare IP when a proxy header is present, otherwise it
+// contains the "host:port" of the connection.
+func GetClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,80 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"prutya/go-api-template/internal/handlers/utils"
+	"prutya/go-api-template/internal/logger"
+	"prutya/go-api-template/internal/services/transactional_email_service"
+)
+
+const maxTransactionalEmailsEventSize = 64 * 1024
+
+var HeaderXWebhookTimestamp = http.CanonicalHeaderKey("X-Webhook-Timestamp")
+var HeaderXWebhookSignature = http.CanonicalHeaderKey("X-Webhook-Signature")
+
+type TransactionalEmailsEventRequest struct {
+	MessageID  string `json:"messageId" validate:"required,lte=256"`
+	Type       string `json:"type" validate:"required,oneof=delivered bounced complained"`
+	BounceType string `json:"bounceType" validate:"omitempty,oneof=hard soft"`
+	Details    string `json:"details" validate:"lte=1024"`
+}
+
+func NewTransactionalEmailsHandler(
+	transactionalEmailService transactional_email_service.TransactionalEmailService,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// The raw body is needed to verify the signature
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxTransactionalEmailsEventSize))
+		if err != nil {
+			utils.RenderInvalidJsonError(w, r)
+			return
+		}
+
+		if err := transactionalEmailService.VerifyWebhookSignature(
+			r.Header.Get(HeaderXWebhookTimestamp),
+			r.Header.Get(HeaderXWebhookSignature),
+			body,
+		); err != nil {
+			logger.MustWarnContext(r.Context(), "Webhook signature verification failed", "error", err.Error())
+
+			utils.RenderError(w, r, utils.ErrUnauthorized)
+			return
+		}
+
+		reqBody := &TransactionalEmailsEventRequest{}
+		if err := json.Unmarshal(body, reqBody); err != nil {
+			utils.RenderInvalidJsonError(w, r)
+			return
+		}
+
+		if err := utils.Validate.Struct(reqBody); err != nil {
+			utils.RenderError(w, r, err)
+			return
+		}
+
+		if err := transactionalEmailService.HandleDeliveryEvent(r.Context(), &transactional_email_service.DeliveryEvent{
+			ProviderMessageID: reqBody.MessageID,
+			Type:              reqBody.Type,
+			BounceType:        reqBody.BounceType,
+			Details:           reqBody.Details,
+		}); err != nil {
+			// Acknowledge events for messages we don't know about, otherwise the
+			// provider would keep retrying them
+			if errors.Is(err, transactional_email_service.ErrEmailMessageNotFound) {
+				logger.MustWarnContext(r.Context(), "Delivery event for an unknown message", "message_id", reqBody.MessageID)
+
+				utils.RenderNoContent(w, r, nil)
+				return
+			}
+
+			utils.RenderError(w, r, err)
+			return
+		}
+
+		utils.RenderNoContent(w, r, nil)
+	}
+}
@@ -0,0 +1,159 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"prutya/go-api-template/internal/logger"
+)
+
+const StatusOK = "ok"
+const StatusFailing = "failing"
+const StatusDraining = "draining"
+
+// A dependency the process needs to serve requests, e.g. the database
+type Check struct {
+	Name string
+	// The default timeout is used when it's zero
+	Timeout time.Duration
+	Run     func(ctx context.Context) error
+}
+
+// The errors of the checks are only logged, the probes are public and the
+// errors may reveal the internals, e.g. the addresses of the dependencies
+type CheckResult struct {
+	Status   string `json:"status"`
+	Duration string `json:"duration"`
+}
+
+type Report struct {
+	Status    string                  `json:"status"`
+	Checks    map[string]*CheckResult `json:"checks"`
+	CheckedAt time.Time               `json:"checked_at"`
+}
+
+// Runs the checks of the readiness probe and fails it while the process drains
+// before shutting down
+type Readiness struct {
+	checks         []*Check
+	defaultTimeout time.Duration
+	cacheTTL       time.Duration
+
+	// Serializes the runs, so that concurrent probes share the results
+	mutex      sync.Mutex
+	lastReport *Report
+
+	draining atomic.Bool
+}
+
+func NewReadiness(checks []*Check, defaultTimeout time.Duration, cacheTTL time.Duration) *Readiness {
+	return &Readiness{
+		checks:         checks,
+		defaultTimeout: defaultTimeout,
+		cacheTTL:       cacheTTL,
+	}
+}
+
+// Fails the readiness probe from now on, so that the load balancers stop
+// routing requests to the process
+func (r *Readiness) Drain() {
+	r.draining.Store(true)
+}
+
+// Runs the checks in parallel, or returns the results of the last run if they
+// are recent enough
+func (r *Readiness) Report(ctx context.Context) *Report {
+	report := r.checkedReport(ctx)
+
+	if !r.draining.Load() {
+		return report
+	}
+
+	// The cached report is shared, so it's copied to override the status
+	return &Report{
+		Status:    StatusDraining,
+		Checks:    report.Checks,
+		CheckedAt: report.CheckedAt,
+	}
+}
+
+func (r *Readiness) checkedReport(ctx context.Context) *Report {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.lastReport != nil && time.Since(r.lastReport.CheckedAt) < r.cacheTTL {
+		return r.lastReport
+	}
+
+	report := &Report{
+		Status:    StatusOK,
+		Checks:    make(map[string]*CheckResult, len(r.checks)),
+		CheckedAt: time.Now(),
+	}
+
+	var resultsMutex sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, check := range r.checks {
+		wg.Go(func() {
+			result := r.run(ctx, check)
+
+			resultsMutex.Lock()
+			defer resultsMutex.Unlock()
+
+			report.Checks[check.Name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusFailing
+			}
+		})
+	}
+
+	wg.Wait()
+
+	r.lastReport = report
+
+	return report
+}
+
+func (r *Readiness) run(ctx context.Context, check *Check) *CheckResult {
+	timeout := check.Timeout
+	if timeout == 0 {
+		timeout = r.defaultTimeout
+	}
+
+	// The results are shared with the other probes, so a probe that gives up
+	// doesn't fail the checks
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	// Some checks don't take a context, e.g. the Redis ping of the tasks
+	// client, so the timeout is enforced here as well
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- check.Run(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := &CheckResult{
+		Status:   StatusOK,
+		Duration: time.Since(start).String(),
+	}
+
+	if err != nil {
+		result.Status = StatusFailing
+
+		logger.MustWarnContext(ctx, "Readiness check failed", "check", check.Name, "error", err)
+	}
+
+	return result
+}
@@ -0,0 +1,35 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Checks a downstream service that responds to a GET with a 2xx status when
+// it's available
+func NewHTTPCheck(name string, url string, timeout time.Duration, httpClient *http.Client) *Check {
+	return &Check{
+		Name:    name,
+		Timeout: timeout,
+		Run: func(ctx context.Context) error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				return err
+			}
+
+			res, err := httpClient.Do(req)
+			if err != nil {
+				return err
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode < 200 || res.StatusCode >= 300 {
+				return fmt.Errorf("unexpected status %d", res.StatusCode)
+			}
+
+			return nil
+		},
+	}
+}
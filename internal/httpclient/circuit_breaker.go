@@ -0,0 +1,141 @@
+package httpclient
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"prutya/go-api-template/internal/metrics"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type circuitState int
+
+// The values are reported by the state gauge
+const (
+	circuitClosed circuitState = iota
+	circuitHalfOpen
+	circuitOpen
+)
+
+type circuitBreaker struct {
+	integration      string
+	failureThreshold int
+	openTimeout      time.Duration
+
+	mutex               sync.Mutex
+	state               circuitState
+	consecutiveFailures int
+	openedAt            time.Time
+	trialInFlight       bool
+}
+
+func newCircuitBreaker(integration string, failureThreshold int, openTimeout time.Duration) *circuitBreaker {
+	breaker := &circuitBreaker{
+		integration:      integration,
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+	}
+
+	breaker.setState(circuitClosed)
+
+	return breaker
+}
+
+// Reports whether the call may be made. In the half-open state only a single
+// trial call is allowed at a time.
+func (b *circuitBreaker) allow() bool {
+	if b.failureThreshold <= 0 {
+		return true
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if time.Since(b.openedAt) < b.openTimeout {
+			return false
+		}
+
+		b.setState(circuitHalfOpen)
+		b.trialInFlight = true
+
+		return true
+	case circuitHalfOpen:
+		if b.trialInFlight {
+			return false
+		}
+
+		b.trialInFlight = true
+
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *circuitBreaker) record(failed bool) {
+	if b.failureThreshold <= 0 {
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.trialInFlight = false
+
+	if !failed {
+		b.consecutiveFailures = 0
+		b.setState(circuitClosed)
+		return
+	}
+
+	b.consecutiveFailures++
+
+	if b.state == circuitHalfOpen || b.consecutiveFailures >= b.failureThreshold {
+		b.openedAt = time.Now()
+		b.setState(circuitOpen)
+	}
+}
+
+// Lets another trial call through without changing the state
+func (b *circuitBreaker) release() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.trialInFlight = false
+}
+
+// Must be called with the mutex held
+func (b *circuitBreaker) setState(state circuitState) {
+	b.state = state
+
+	metrics.HTTPClientCircuitBreakerState.WithLabelValues(b.integration).Set(float64(state))
+}
+
+type circuitBreakerTransport struct {
+	breaker *circuitBreaker
+	next    http.RoundTripper
+}
+
+func (t *circuitBreakerTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if !t.breaker.allow() {
+		metrics.HTTPClientCircuitBreakerRejectionsTotal.WithLabelValues(t.breaker.integration).Inc()
+
+		return nil, ErrCircuitOpen
+	}
+
+	response, err := t.next.RoundTrip(request)
+
+	// A call given up by the caller tells nothing about the service
+	if err != nil && request.Context().Err() != nil {
+		t.breaker.release()
+	} else {
+		t.breaker.record(isServerFailure(response, err))
+	}
+
+	return response, err
+}
@@ -0,0 +1,127 @@
+package httpclient
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// The integrations using the client. They label the logs and the metrics.
+const IntegrationCaptcha = "captcha"
+const IntegrationScaleway = "scaleway"
+const IntegrationEmailBlocklist = "email_blocklist"
+const IntegrationAlerts = "alerts"
+const IntegrationHealthChecks = "health_checks"
+
+type Config struct {
+	// Limits the whole call, including retries and redirects
+	Timeout               time.Duration
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	IdleConnTimeout       time.Duration
+	MaxIdleConns          int
+	MaxIdleConnsPerHost   int
+	MaxConnsPerHost       int
+	MaxRedirects          int
+
+	// Only idempotent requests are retried, see `WithIdempotent`
+	MaxRetries     int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+
+	// Consecutive failures after which calls are rejected without being made.
+	// The circuit breaker is disabled when it's 0.
+	CircuitBreakerFailureThreshold int
+	// Time after which a single trial call is let through an open circuit
+	CircuitBreakerOpenTimeout time.Duration
+
+	// Per-integration overrides of `Timeout`, e.g. for large downloads
+	TimeoutOverrides map[string]time.Duration
+}
+
+type idempotentContextKeyType struct{}
+
+var idempotentContextKey = idempotentContextKeyType{}
+
+// Marks the requests made with the context as safe to retry even though their
+// method is not idempotent, e.g. a POST carrying an idempotency key
+func WithIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentContextKey, true)
+}
+
+func isMarkedIdempotent(ctx context.Context) bool {
+	idempotent, _ := ctx.Value(idempotentContextKey).(bool)
+
+	return idempotent
+}
+
+// Returns a client for the integration. Every integration gets its own
+// connection pool and circuit breaker, so that a failing provider doesn't
+// affect the others.
+func New(integration string, config *Config) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   config.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.TLSHandshakeTimeout = config.TLSHandshakeTimeout
+	transport.ResponseHeaderTimeout = config.ResponseHeaderTimeout
+	transport.IdleConnTimeout = config.IdleConnTimeout
+	transport.MaxIdleConns = config.MaxIdleConns
+	transport.MaxIdleConnsPerHost = config.MaxIdleConnsPerHost
+	transport.MaxConnsPerHost = config.MaxConnsPerHost
+
+	timeout := config.Timeout
+	if override, found := config.TimeoutOverrides[integration]; found {
+		timeout = override
+	}
+
+	// Every attempt is logged and measured, the circuit breaker sees the
+	// outcome of the call after the retries
+	var roundTripper http.RoundTripper = &instrumentedTransport{
+		integration: integration,
+		next:        transport,
+	}
+	roundTripper = &retryTransport{
+		integration: integration,
+		maxRetries:  config.MaxRetries,
+		baseDelay:   config.RetryBaseDelay,
+		maxDelay:    config.RetryMaxDelay,
+		next:        roundTripper,
+	}
+	roundTripper = &circuitBreakerTransport{
+		breaker: newCircuitBreaker(
+			integration,
+			config.CircuitBreakerFailureThreshold,
+			config.CircuitBreakerOpenTimeout,
+		),
+		next: roundTripper,
+	}
+
+	maxRedirects := config.MaxRedirects
+
+	return &http.Client{
+		Transport: roundTripper,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return http.ErrUseLastResponse
+			}
+
+			return nil
+		},
+	}
+}
+
+// Whether the outcome counts as a failure of the remote service
+func isServerFailure(response *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	return response.StatusCode >= http.StatusInternalServerError
+}
@@ -0,0 +1,275 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"prutya/go-api-template/internal/logger"
+	"prutya/go-api-template/internal/metrics"
+	"prutya/go-api-template/internal/tracing"
+)
+
+const redacted = "[REDACTED]"
+
+// Bodies are only logged in debug builds and only up to this size
+const maxLoggedBodySize = 4 * 1024
+
+var sensitiveHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Auth-Token",
+	"X-Api-Key",
+}
+
+// Query params, form fields and JSON keys containing any of these are redacted
+var sensitiveKeyParts = []string{
+	"secret",
+	"token",
+	"password",
+	"key",
+	"response",
+	"auth",
+}
+
+type instrumentedTransport struct {
+	integration string
+	next        http.RoundTripper
+}
+
+func (t *instrumentedTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	// Every attempt gets its own span. The trace context is not sent, the
+	// integrations are third parties.
+	ctx, span := tracing.Start(
+		request.Context(),
+		"HTTP "+request.Method+" "+t.integration,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("integration", t.integration),
+			semconv.HTTPRequestMethodKey.String(request.Method),
+			semconv.ServerAddress(request.URL.Hostname()),
+		),
+	)
+	defer span.End()
+
+	request = request.WithContext(ctx)
+
+	// The client is also used outside of requests, e.g. by the worker
+	requestLogger, loggerErr := logger.FromContext(ctx)
+	if loggerErr == nil {
+		requestLogger = requestLogger.Component("httpclient")
+	}
+
+	// The bodies may contain secrets, e.g. emails, so they're only logged in
+	// debug builds
+	var requestBody string
+	if logger.Debug && loggerErr == nil && requestLogger.DebugEnabled(ctx) {
+		requestBody = peekRequestBody(request)
+	}
+
+	start := time.Now()
+
+	response, err := t.next.RoundTrip(request)
+
+	duration := time.Since(start)
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(response.StatusCode)
+	}
+
+	if err != nil {
+		tracing.RecordError(span, err)
+	} else {
+		span.SetAttributes(semconv.HTTPResponseStatusCode(response.StatusCode))
+
+		if response.StatusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, response.Status)
+		}
+	}
+
+	metrics.HTTPClientRequestsTotal.WithLabelValues(t.integration, request.Method, status).Inc()
+	metrics.HTTPClientRequestDuration.WithLabelValues(t.integration, request.Method).Observe(duration.Seconds())
+
+	if loggerErr != nil {
+		return response, err
+	}
+
+	args := []any{
+		"integration", t.integration,
+		"method", request.Method,
+		"url", redactURL(request.URL),
+		"duration", duration,
+	}
+
+	if err != nil {
+		requestLogger.WarnContext(ctx, "Outbound request failed", append(args, "error", err)...)
+		return response, err
+	}
+
+	args = append(args, "status", response.StatusCode)
+
+	if response.StatusCode >= http.StatusInternalServerError {
+		requestLogger.WarnContext(ctx, "Outbound request failed", args...)
+	}
+
+	if requestLogger.DebugEnabled(ctx) {
+		args = append(
+			args,
+			"request_headers", redactHeaders(request.Header),
+			"response_headers", redactHeaders(response.Header),
+		)
+
+		if logger.Debug {
+			args = append(args, "request_body", requestBody, "response_body", peekResponseBody(response))
+		}
+
+		requestLogger.DebugContext(ctx, "Outbound request", args...)
+	}
+
+	return response, err
+}
+
+func redactURL(u *url.URL) string {
+	redactedURL := *u
+	redactedURL.User = nil
+
+	query := redactedURL.Query()
+	for key := range query {
+		if isSensitiveKey(key) {
+			query.Set(key, redacted)
+		}
+	}
+
+	redactedURL.RawQuery = query.Encode()
+
+	return redactedURL.String()
+}
+
+func redactHeaders(header http.Header) map[string]string {
+	result := make(map[string]string, len(header))
+
+	for name := range header {
+		result[name] = header.Get(name)
+	}
+
+	for _, name := range sensitiveHeaders {
+		if _, found := result[name]; found {
+			result[name] = redacted
+		}
+	}
+
+	return result
+}
+
+func redactBody(contentType string, body []byte) string {
+	switch {
+	case strings.HasPrefix(contentType, "application/json"):
+		var value any
+		if err := json.Unmarshal(body, &value); err != nil {
+			return redacted
+		}
+
+		redactedBody, err := json.Marshal(redactJSON(value))
+		if err != nil {
+			return redacted
+		}
+
+		return string(redactedBody)
+	case strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return redacted
+		}
+
+		for key := range form {
+			if isSensitiveKey(key) {
+				form.Set(key, redacted)
+			}
+		}
+
+		return form.Encode()
+	default:
+		// Unknown formats can't be redacted
+		return strconv.Itoa(len(body)) + " bytes"
+	}
+}
+
+func redactJSON(value any) any {
+	switch typedValue := value.(type) {
+	case map[string]any:
+		for key, nestedValue := range typedValue {
+			if isSensitiveKey(key) {
+				typedValue[key] = redacted
+			} else {
+				typedValue[key] = redactJSON(nestedValue)
+			}
+		}
+	case []any:
+		for i, nestedValue := range typedValue {
+			typedValue[i] = redactJSON(nestedValue)
+		}
+	}
+
+	return value
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reads a copy of the body without consuming it
+func peekRequestBody(request *http.Request) string {
+	if request.Body == nil || request.Body == http.NoBody || request.GetBody == nil {
+		return ""
+	}
+
+	body, err := request.GetBody()
+	if err != nil {
+		return ""
+	}
+	defer body.Close()
+
+	peeked, err := io.ReadAll(io.LimitReader(body, maxLoggedBodySize))
+	if err != nil {
+		return ""
+	}
+
+	return redactBody(request.Header.Get("Content-Type"), peeked)
+}
+
+// Reads the beginning of the body and puts it back for the caller
+func peekResponseBody(response *http.Response) string {
+	peeked, err := io.ReadAll(io.LimitReader(response.Body, maxLoggedBodySize))
+
+	response.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(peeked), response.Body), response.Body}
+
+	if err != nil {
+		return ""
+	}
+
+	return redactBody(response.Header.Get("Content-Type"), peeked)
+}
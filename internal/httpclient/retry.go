@@ -0,0 +1,133 @@
+package httpclient
+
+import (
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"prutya/go-api-template/internal/metrics"
+)
+
+// Responses drained before a retry so that the connection can be reused
+const maxDrainSize = 64 * 1024
+
+type retryTransport struct {
+	integration string
+	maxRetries  int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	next        http.RoundTripper
+}
+
+func (t *retryTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if t.maxRetries <= 0 || !isRetryable(request) {
+		return t.next.RoundTrip(request)
+	}
+
+	ctx := request.Context()
+	attemptRequest := request
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			var err error
+
+			attemptRequest, err = rewindRequest(request)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		response, err := t.next.RoundTrip(attemptRequest)
+
+		if attempt >= t.maxRetries || !shouldRetry(request, response, err) {
+			return response, err
+		}
+
+		delay := t.backoff(attempt, response)
+
+		if response != nil {
+			io.CopyN(io.Discard, response.Body, maxDrainSize)
+			response.Body.Close()
+		}
+
+		metrics.HTTPClientRetriesTotal.WithLabelValues(t.integration).Inc()
+
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Exponential backoff with full jitter. A `Retry-After` from the server is
+// respected as long as it's within the maximum delay.
+func (t *retryTransport) backoff(attempt int, response *http.Response) time.Duration {
+	ceiling := min(t.baseDelay<<attempt, t.maxDelay)
+	if ceiling <= 0 {
+		return 0
+	}
+
+	delay := rand.N(ceiling + 1)
+
+	if response != nil {
+		if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil {
+			retryAfter := time.Duration(seconds) * time.Second
+
+			if retryAfter > delay && retryAfter <= t.maxDelay {
+				delay = retryAfter
+			}
+		}
+	}
+
+	return delay
+}
+
+func isRetryable(request *http.Request) bool {
+	// The body must be replayable
+	if request.Body != nil && request.Body != http.NoBody && request.GetBody == nil {
+		return false
+	}
+
+	switch request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return isMarkedIdempotent(request.Context())
+	}
+}
+
+func shouldRetry(request *http.Request, response *http.Response, err error) bool {
+	if err != nil {
+		return request.Context().Err() == nil
+	}
+
+	switch response.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// A round tripper must not modify the request, every attempt gets a copy with
+// a fresh body
+func rewindRequest(request *http.Request) (*http.Request, error) {
+	rewound := request.Clone(request.Context())
+
+	if request.GetBody != nil {
+		body, err := request.GetBody()
+		if err != nil {
+			return nil, err
+		}
+
+		rewound.Body = body
+	}
+
+	return rewound, nil
+}
@@ -2,4 +2,8 @@
 
 package logger
 
+// Set by the "debug" build tag. Adds the callers to the log records when
+// "log_show_caller_in_debug" is set, as well as the SQL queries and the
+// outbound request bodies, which may contain secrets. The levels are checked
+// at runtime regardless.
 const Debug = true
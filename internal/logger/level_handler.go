@@ -0,0 +1,40 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Filters the records by the level of the component the logger belongs to and
+// adds the component to them
+type levelHandler struct {
+	slog.Handler
+	levels    *Levels
+	component string
+}
+
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.levels.Level(h.component)
+}
+
+func (h *levelHandler) Handle(ctx context.Context, record slog.Record) error {
+	// Added here rather than with WithAttrs, so that a logger moved to another
+	// component doesn't end up with both
+	if h.component != "" {
+		record.AddAttrs(slog.String("component", h.component))
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithAttrs(attrs), levels: h.levels, component: h.component}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithGroup(name), levels: h.levels, component: h.component}
+}
+
+func (h *levelHandler) withComponent(component string) *levelHandler {
+	return &levelHandler{Handler: h.Handler, levels: h.levels, component: component}
+}
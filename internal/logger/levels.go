@@ -0,0 +1,153 @@
+package logger
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// Minimum levels of the log records, changeable at runtime. Every component
+// (e.g. "db") follows the default level unless it has a level of its own.
+type Levels struct {
+	defaultLevel slog.LevelVar
+
+	mutex      sync.RWMutex
+	components map[string]*slog.LevelVar
+	// The level `ToggleDebug` switches back to
+	configuredDefaultLevel slog.Level
+}
+
+func newLevels(defaultLevel slog.Level, componentLevels map[string]slog.Level) *Levels {
+	levels := &Levels{configuredDefaultLevel: defaultLevel}
+	levels.defaultLevel.Set(defaultLevel)
+	levels.SetComponents(componentLevels)
+
+	return levels
+}
+
+// Returns the level of the component, or the default level if the component
+// is empty or doesn't have a level of its own
+func (l *Levels) Level(component string) slog.Level {
+	if component != "" {
+		l.mutex.RLock()
+		componentLevel, found := l.components[component]
+		l.mutex.RUnlock()
+
+		if found {
+			return componentLevel.Level()
+		}
+	}
+
+	return l.defaultLevel.Level()
+}
+
+func (l *Levels) Default() slog.Level {
+	return l.defaultLevel.Level()
+}
+
+func (l *Levels) SetDefault(level slog.Level) {
+	l.defaultLevel.Set(level)
+}
+
+// Returns the components with a level of their own
+func (l *Levels) Components() map[string]slog.Level {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	componentLevels := make(map[string]slog.Level, len(l.components))
+	for component, level := range l.components {
+		componentLevels[component] = level.Level()
+	}
+
+	return componentLevels
+}
+
+// Replaces the levels of the components. The components left out follow the
+// default level from now on.
+func (l *Levels) SetComponents(componentLevels map[string]slog.Level) {
+	components := make(map[string]*slog.LevelVar, len(componentLevels))
+	for component, level := range componentLevels {
+		components[component] = new(slog.LevelVar)
+		components[component].Set(level)
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.components = components
+}
+
+// Replaces all the levels with the configured ones, e.g. after the config is
+// reloaded. `ToggleDebug` switches back to the new default level.
+func (l *Levels) Configure(levelStr string, componentLevelStrs map[string]string) error {
+	defaultLevel, err := ParseLevel(levelStr)
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, levelStr)
+	}
+
+	componentLevels, err := parseComponentLevels(componentLevelStrs)
+	if err != nil {
+		return err
+	}
+
+	l.SetComponents(componentLevels)
+
+	l.mutex.Lock()
+	l.configuredDefaultLevel = defaultLevel
+	l.mutex.Unlock()
+
+	l.defaultLevel.Set(defaultLevel)
+
+	return nil
+}
+
+// Switches the default level to debug, or back to the configured level if it's
+// already debug. Returns the new default level.
+func (l *Levels) ToggleDebug() slog.Level {
+	level := slog.LevelDebug
+	if l.defaultLevel.Level() == slog.LevelDebug {
+		l.mutex.RLock()
+		level = l.configuredDefaultLevel
+		l.mutex.RUnlock()
+	}
+
+	l.defaultLevel.Set(level)
+
+	return level
+}
+
+func ParseLevel(levelStr string) (slog.Level, error) {
+	switch strings.ToLower(levelStr) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, ErrUnknownLogLevel
+	}
+}
+
+// Formats the level the way it's configured, e.g. "debug"
+func FormatLevel(level slog.Level) string {
+	return strings.ToLower(level.String())
+}
+
+func parseComponentLevels(componentLevelStrs map[string]string) (map[string]slog.Level, error) {
+	componentLevels := make(map[string]slog.Level, len(componentLevelStrs))
+
+	for component, levelStr := range componentLevelStrs {
+		level, err := ParseLevel(levelStr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s=%s", err, component, levelStr)
+		}
+
+		componentLevels[component] = level
+	}
+
+	return componentLevels, nil
+}
@@ -7,7 +7,6 @@ import (
 	"log/slog"
 	"os"
 	"runtime"
-	"strings"
 )
 
 var ErrUnknownLogLevel = errors.New("unknown log level")
@@ -18,43 +17,61 @@ type Logger struct {
 	showCallerInDebug bool
 }
 
-func New(levelStr string, format string, showCallerInDebug bool) (*Logger, error) {
-	level, err := parseLevel(levelStr)
+// The values of the attributes whose keys match one of the redacted key
+// patterns, e.g. "*token", are replaced. See `path.Match` for the syntax.
+//
+// The components, e.g. "db", can have levels of their own. The records with a
+// sampled message are kept at the given rate, e.g. 1 in 10.
+func New(
+	levelStr string,
+	format string,
+	showCallerInDebug bool,
+	redactedKeys []string,
+	componentLevelStrs map[string]string,
+	samplingRates map[string]int,
+) (*Logger, error) {
+	level, err := ParseLevel(levelStr)
 	if err != nil {
 		return nil, err
 	}
 
+	componentLevels, err := parseComponentLevels(componentLevelStrs)
+	if err != nil {
+		return nil, err
+	}
+
+	// The levels are checked by the level handler, so that they can change
+	// at runtime
+	handlerOptions := &slog.HandlerOptions{Level: slog.LevelDebug}
+
 	var baseHandler slog.Handler
 
 	switch format {
 	case "text":
-		baseHandler = slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-			Level: level,
-		})
+		baseHandler = slog.NewTextHandler(os.Stdout, handlerOptions)
 	case "json":
-		baseHandler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-			Level: level,
-		})
+		baseHandler = slog.NewJSONHandler(os.Stdout, handlerOptions)
 	default:
 		return nil, ErrUnknownLogFormat
 	}
 
-	slogLogger := slog.New(baseHandler)
-	slog.SetDefault(slogLogger)
-
-	logger := &Logger{slog: slogLogger, showCallerInDebug: showCallerInDebug}
-
-	if !Debug && level == slog.LevelDebug {
-		logger.Warn("Debug logging was not enabled at compile time. Messages with level \"debug\" will not be printed.")
+	redactHandler, err := newRedactHandler(baseHandler, redactedKeys)
+	if err != nil {
+		return nil, err
 	}
 
-	return logger, nil
+	// The records are sampled first to spare the work on the dropped ones
+	slogLogger := slog.New(&levelHandler{
+		Handler: newSamplingHandler(&traceHandler{Handler: redactHandler}, samplingRates),
+		levels:  newLevels(level, componentLevels),
+	})
+	slog.SetDefault(slogLogger)
+
+	return &Logger{slog: slogLogger, showCallerInDebug: showCallerInDebug}, nil
 }
 
 func (l *Logger) Debug(msg string, args ...any) {
-	if Debug {
-		logContext(l, context.Background(), slog.LevelDebug, msg, args...)
-	}
+	logContext(l, context.Background(), slog.LevelDebug, msg, args...)
 }
 
 func (l *Logger) Info(msg string, args ...any) {
@@ -75,9 +92,7 @@ func (l *Logger) Fatal(msg string, args ...any) {
 }
 
 func MustDebugContext(ctx context.Context, msg string, args ...any) {
-	if Debug {
-		logContext(MustFromContext(ctx), ctx, slog.LevelDebug, msg, args...)
-	}
+	logContext(MustFromContext(ctx), ctx, slog.LevelDebug, msg, args...)
 }
 
 func MustInfoContext(ctx context.Context, msg string, args ...any) {
@@ -98,9 +113,7 @@ func MustFatalContext(ctx context.Context, msg string, args ...any) {
 }
 
 func (l *Logger) DebugContext(ctx context.Context, msg string, args ...any) {
-	if Debug {
-		logContext(l, ctx, slog.LevelDebug, msg, args...)
-	}
+	logContext(l, ctx, slog.LevelDebug, msg, args...)
 }
 
 func (l *Logger) InfoContext(ctx context.Context, msg string, args ...any) {
@@ -120,28 +133,37 @@ func (l *Logger) FatalContext(ctx context.Context, msg string, args ...any) {
 	os.Exit(1)
 }
 
-func (l *Logger) With(key string, value any) *Logger {
+// Returns a logger whose records are filtered by the level of the component,
+// e.g. "db", and labelled with it
+func (l *Logger) Component(component string) *Logger {
 	return &Logger{
-		slog:              l.slog.With(key, value),
+		slog:              slog.New(l.levelHandler().withComponent(component)),
 		showCallerInDebug: l.showCallerInDebug,
 	}
 }
 
-func parseLevel(levelStr string) (slog.Level, error) {
-	switch strings.ToLower(levelStr) {
-	case "debug":
-		return slog.LevelDebug, nil
-	case "info":
-		return slog.LevelInfo, nil
-	case "warn", "warning":
-		return slog.LevelWarn, nil
-	case "error":
-		return slog.LevelError, nil
-	default:
-		return slog.LevelInfo, ErrUnknownLogLevel
+// Returns the levels shared by all the loggers, e.g. to change them at runtime
+func (l *Logger) Levels() *Levels {
+	return l.levelHandler().levels
+}
+
+func (l *Logger) levelHandler() *levelHandler {
+	return l.slog.Handler().(*levelHandler)
+}
+
+// Whether debug records are printed, e.g. to skip preparing expensive ones
+func (l *Logger) DebugEnabled(ctx context.Context) bool {
+	return l.slog.Enabled(ctx, slog.LevelDebug)
+}
+
+func (l *Logger) With(key string, value any) *Logger {
+	return &Logger{
+		slog:              l.slog.With(key, value),
+		showCallerInDebug: l.showCallerInDebug,
 	}
 }
 
+// The caller is only added in debug builds, see `Debug`
 func logContext(l *Logger, ctx context.Context, level slog.Level, msg string, args ...any) {
 	if Debug && l.showCallerInDebug {
 		l.slog.Log(ctx, level, msg, append(args, getCallerInfo()...)...)
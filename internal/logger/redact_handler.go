@@ -0,0 +1,96 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"path"
+	"strings"
+)
+
+const redactedValue = "[REDACTED]"
+
+// Replaces the values of the attributes whose keys match one of the patterns,
+// e.g. "*token", so that secrets logged by mistake don't end up in the logs.
+// The patterns are matched against the lowercase keys, including the keys of
+// the attributes in groups.
+type redactHandler struct {
+	slog.Handler
+	patterns []string
+}
+
+func newRedactHandler(handler slog.Handler, patterns []string) (*redactHandler, error) {
+	lowercasePatterns := make([]string, len(patterns))
+
+	for i, pattern := range patterns {
+		lowercasePatterns[i] = strings.ToLower(pattern)
+
+		// Fail early on a malformed pattern, Match only reports it on a match
+		if _, err := path.Match(lowercasePatterns[i], ""); err != nil {
+			return nil, err
+		}
+	}
+
+	return &redactHandler{Handler: handler, patterns: lowercasePatterns}, nil
+}
+
+func (h *redactHandler) Handle(ctx context.Context, record slog.Record) error {
+	if len(h.patterns) == 0 {
+		return h.Handler.Handle(ctx, record)
+	}
+
+	redactedRecord := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+
+	record.Attrs(func(attr slog.Attr) bool {
+		redactedRecord.AddAttrs(h.redact(attr))
+
+		return true
+	})
+
+	return h.Handler.Handle(ctx, redactedRecord)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redactedAttrs := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redactedAttrs[i] = h.redact(attr)
+	}
+
+	return &redactHandler{Handler: h.Handler.WithAttrs(redactedAttrs), patterns: h.patterns}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{Handler: h.Handler.WithGroup(name), patterns: h.patterns}
+}
+
+func (h *redactHandler) redact(attr slog.Attr) slog.Attr {
+	if h.matches(attr.Key) {
+		return slog.String(attr.Key, redactedValue)
+	}
+
+	// Values like `Secret` resolve to a group or a redacted value themselves
+	value := attr.Value.Resolve()
+
+	if value.Kind() != slog.KindGroup {
+		return slog.Attr{Key: attr.Key, Value: value}
+	}
+
+	groupAttrs := value.Group()
+	redactedAttrs := make([]any, len(groupAttrs))
+	for i, groupAttr := range groupAttrs {
+		redactedAttrs[i] = h.redact(groupAttr)
+	}
+
+	return slog.Group(attr.Key, redactedAttrs...)
+}
+
+func (h *redactHandler) matches(key string) bool {
+	key = strings.ToLower(key)
+
+	for _, pattern := range h.patterns {
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,146 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+var testRedactedKeys = []string{"*password*", "*token", "authorization"}
+
+// Logs the record through a redact handler and returns the decoded JSON
+func logRedacted(t *testing.T, build func(handler slog.Handler) slog.Handler, args ...any) map[string]any {
+	t.Helper()
+
+	var buffer bytes.Buffer
+
+	handler, err := newRedactHandler(slog.NewJSONHandler(&buffer, nil), testRedactedKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := slog.NewRecord(time.Now(), slog.LevelInfo, "test", 0)
+	record.Add(args...)
+
+	if err := build(handler).Handle(context.Background(), record); err != nil {
+		t.Fatal(err)
+	}
+
+	var output map[string]any
+	if err := json.Unmarshal(buffer.Bytes(), &output); err != nil {
+		t.Fatal(err)
+	}
+
+	return output
+}
+
+func sameHandler(handler slog.Handler) slog.Handler {
+	return handler
+}
+
+type tokenPair struct{}
+
+func (tokenPair) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("refresh_token", "refresh"), slog.String("kind", "pair"))
+}
+
+func TestRedactHandlerMatches(t *testing.T) {
+	output := logRedacted(
+		t,
+		sameHandler,
+		"password", "hunter2",
+		"New_Password", "hunter3",
+		"ACCESS_TOKEN", "token",
+		"Authorization", "Bearer token",
+		"user_id", "user",
+		// Doesn't end with "token"
+		"tokens_used", 3,
+	)
+
+	for _, key := range []string{"password", "New_Password", "ACCESS_TOKEN", "Authorization"} {
+		if output[key] != redactedValue {
+			t.Errorf("expected %q to be redacted, got %v", key, output[key])
+		}
+	}
+
+	if output["user_id"] != "user" || output["tokens_used"] != float64(3) {
+		t.Errorf("expected the other attributes to be kept, got %v", output)
+	}
+}
+
+func TestRedactHandlerGroups(t *testing.T) {
+	output := logRedacted(
+		t,
+		sameHandler,
+		slog.Group("request", "path", "/", slog.Group("headers", "authorization", "Bearer token", "accept", "*/*")),
+		slog.Group("password", "old", "hunter2"),
+		"session", tokenPair{},
+	)
+
+	headers := output["request"].(map[string]any)["headers"].(map[string]any)
+	if headers["authorization"] != redactedValue || headers["accept"] != "*/*" {
+		t.Errorf("expected the nested attribute to be redacted, got %v", headers)
+	}
+
+	if output["password"] != redactedValue {
+		t.Errorf("expected the matching group to be redacted as a whole, got %v", output["password"])
+	}
+
+	session := output["session"].(map[string]any)
+	if session["refresh_token"] != redactedValue || session["kind"] != "pair" {
+		t.Errorf("expected the group of the value to be redacted, got %v", session)
+	}
+}
+
+func TestRedactHandlerWithAttrs(t *testing.T) {
+	output := logRedacted(t, func(handler slog.Handler) slog.Handler {
+		return handler.
+			WithAttrs([]slog.Attr{slog.String("api_token", "token"), slog.String("service", "api")}).
+			WithGroup("user").
+			WithAttrs([]slog.Attr{slog.String("password", "hunter2")})
+	}, "id", "user")
+
+	if output["api_token"] != redactedValue || output["service"] != "api" {
+		t.Errorf("expected the attributes of the handler to be redacted, got %v", output)
+	}
+
+	user := output["user"].(map[string]any)
+	if user["password"] != redactedValue || user["id"] != "user" {
+		t.Errorf("expected the attributes in the group to be redacted, got %v", user)
+	}
+}
+
+func TestRedactHandlerMalformedPattern(t *testing.T) {
+	if _, err := newRedactHandler(slog.NewJSONHandler(&bytes.Buffer{}, nil), []string{"[token"}); err == nil {
+		t.Error("expected the malformed pattern to be rejected")
+	}
+}
+
+func TestSecretLogValue(t *testing.T) {
+	if got := Secret("hunter2").LogValue().String(); got != redactedValue {
+		t.Errorf("expected the secret to be redacted, got %q", got)
+	}
+}
+
+func TestEmailLogValue(t *testing.T) {
+	tests := []struct {
+		email string
+		want  string
+	}{
+		{"john@example.com", "j***@example.com"},
+		{"j@example.com", "j***@example.com"},
+		{"élodie@example.com", "é***@example.com"},
+		{"@example.com", "***@example.com"},
+		{"john.example.com", redactedValue},
+		{"", redactedValue},
+	}
+
+	for _, test := range tests {
+		if got := Email(test.email).LogValue().String(); got != test.want {
+			t.Errorf("Email(%q) = %q, want %q", test.email, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+)
+
+type samplingRate struct {
+	rate    uint64
+	counter atomic.Uint64
+}
+
+// Keeps 1 in N records of the high-volume messages, e.g. "SQL query". Warnings
+// and errors are always kept.
+type samplingHandler struct {
+	slog.Handler
+	// Shared with the derived handlers, so that the counters are global
+	rates map[string]*samplingRate
+}
+
+func newSamplingHandler(handler slog.Handler, rates map[string]int) *samplingHandler {
+	samplingRates := make(map[string]*samplingRate, len(rates))
+	for message, rate := range rates {
+		if rate > 1 {
+			samplingRates[message] = &samplingRate{rate: uint64(rate)}
+		}
+	}
+
+	return &samplingHandler{Handler: handler, rates: samplingRates}
+}
+
+func (h *samplingHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level >= slog.LevelWarn {
+		return h.Handler.Handle(ctx, record)
+	}
+
+	samplingRate, found := h.rates[record.Message]
+	if !found {
+		return h.Handler.Handle(ctx, record)
+	}
+
+	if (samplingRate.counter.Add(1)-1)%samplingRate.rate != 0 {
+		return nil
+	}
+
+	// Tells the readers how many similar records the kept one stands for
+	record.AddAttrs(slog.Uint64("sample_rate", samplingRate.rate))
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *samplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &samplingHandler{Handler: h.Handler.WithAttrs(attrs), rates: h.rates}
+}
+
+func (h *samplingHandler) WithGroup(name string) slog.Handler {
+	return &samplingHandler{Handler: h.Handler.WithGroup(name), rates: h.rates}
+}
@@ -0,0 +1,32 @@
+package logger
+
+import (
+	"log/slog"
+	"strings"
+)
+
+// A value that is never written to the logs, e.g. a token or an OTP
+type Secret string
+
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(redactedValue)
+}
+
+// An email address that is logged with its local part masked, so that the
+// entries of a user can be told apart without exposing the address
+type Email string
+
+func (e Email) LogValue() slog.Value {
+	localPart, domain, found := strings.Cut(string(e), "@")
+	if !found {
+		return slog.StringValue(redactedValue)
+	}
+
+	// Keep the first character, it helps to tell apart the addresses of a
+	// domain without identifying them
+	if runes := []rune(localPart); len(runes) > 1 {
+		localPart = string(runes[:1])
+	}
+
+	return slog.StringValue(localPart + "***@" + domain)
+}
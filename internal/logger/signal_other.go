@@ -0,0 +1,7 @@
+//go:build !unix
+
+package logger
+
+// There is no SIGUSR1 on this platform, the levels can only be changed via the
+// admin API
+func (l *Logger) ToggleDebugOnSignal() {}
@@ -0,0 +1,24 @@
+//go:build unix
+
+package logger
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Toggles the default level between debug and the configured level on
+// SIGUSR1, e.g. `kill -USR1 <pid>`
+func (l *Logger) ToggleDebugOnSignal() {
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, syscall.SIGUSR1)
+
+	go func() {
+		for range signalCh {
+			level := l.Levels().ToggleDebug()
+
+			l.Warn("Log level changed", "level", FormatLevel(level))
+		}
+	}()
+}
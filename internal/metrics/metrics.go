@@ -0,0 +1,273 @@
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "app"
+
+var HTTPRequestsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of handled HTTP requests by method, route pattern and status.",
+	},
+	[]string{"method", "route", "status"},
+)
+
+var HTTPRequestDuration = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of handled HTTP requests by method and route pattern.",
+		Buckets:   prometheus.DefBuckets,
+	},
+	[]string{"method", "route"},
+)
+
+var HTTPRequestsInFlight = promauto.NewGauge(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "Number of HTTP requests being handled.",
+	},
+)
+
+var DBQueryDuration = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Duration of database queries by operation.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+	},
+	[]string{"operation"},
+)
+
+var DBQueryErrorsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_query_errors_total",
+		Help:      "Number of failed database queries by operation, not counting empty results.",
+	},
+	[]string{"operation"},
+)
+
+var AuthenticationLoginsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "authentication_logins_total",
+		Help:      "Number of login attempts by result.",
+	},
+	[]string{"result"},
+)
+
+var AuthenticationRefreshTokenReuseTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "authentication_refresh_token_reuse_total",
+		Help:      "Number of revoked refresh tokens used again by outcome (within the leeway or rejected and the session terminated).",
+	},
+	[]string{"outcome"},
+)
+
+var TransactionalEmailsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transactional_emails_total",
+		Help:      "Number of transactional emails by template and result (sent, failed, suppressed or rejected by a limit).",
+	},
+	[]string{"template", "result"},
+)
+
+var EmailBlocklistRejectionsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "email_blocklist_rejections_total",
+		Help:      "Number of email addresses rejected by the blocklist.",
+	},
+	[]string{"reason"},
+)
+
+var EmailBlocklistDomains = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "email_blocklist_domains",
+		Help:      "Number of domains loaded into the email blocklist and allowlist.",
+	},
+	[]string{"list"},
+)
+
+var EmailBlocklistReloadsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "email_blocklist_reloads_total",
+		Help:      "Number of email blocklist reloads.",
+	},
+	[]string{"result"},
+)
+
+var EmailDomainValidationsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "email_domain_validations_total",
+		Help:      "Number of email domain deliverability checks by result.",
+	},
+	[]string{"result"},
+)
+
+var CaptchaRiskAssessmentsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "captcha_risk_assessments_total",
+		Help:      "Number of risk assessments on captcha protected routes by action and whether a captcha was required.",
+	},
+	[]string{"action", "result"},
+)
+
+var AuthenticationCleanupDeletedTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "authentication_cleanup_deleted_total",
+		Help:      "Number of expired tokens and ended sessions deleted by the periodic cleanup by table.",
+	},
+	[]string{"table"},
+)
+
+var HTTPClientRequestsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_client_requests_total",
+		Help:      "Number of outbound HTTP request attempts by integration, method and status.",
+	},
+	[]string{"integration", "method", "status"},
+)
+
+var HTTPClientRequestDuration = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_client_request_duration_seconds",
+		Help:      "Duration of outbound HTTP request attempts by integration and method.",
+		Buckets:   prometheus.DefBuckets,
+	},
+	[]string{"integration", "method"},
+)
+
+var HTTPClientRetriesTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_client_retries_total",
+		Help:      "Number of retried outbound HTTP requests by integration.",
+	},
+	[]string{"integration"},
+)
+
+var HTTPClientCircuitBreakerState = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_client_circuit_breaker_state",
+		Help:      "State of the outbound HTTP circuit breaker by integration (0 closed, 1 half-open, 2 open).",
+	},
+	[]string{"integration"},
+)
+
+var HTTPClientCircuitBreakerRejectionsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_client_circuit_breaker_rejections_total",
+		Help:      "Number of outbound HTTP requests rejected by an open circuit breaker by integration.",
+	},
+	[]string{"integration"},
+)
+
+var TasksDuplicatesTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tasks_duplicates_total",
+		Help:      "Number of tasks dropped on enqueue because they were already enqueued by task type.",
+	},
+	[]string{"task_type"},
+)
+
+var TasksFailuresTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tasks_failures_total",
+		Help:      "Number of failed task attempts by task type.",
+	},
+	[]string{"task_type"},
+)
+
+var TasksDeadTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tasks_dead_total",
+		Help:      "Number of tasks that exhausted their retries by task type.",
+	},
+	[]string{"task_type"},
+)
+
+var TasksSchedulerLeader = promauto.NewGauge(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "tasks_scheduler_leader",
+		Help:      "Whether this scheduler replica holds the leader lock and enqueues the periodic tasks (1) or not (0).",
+	},
+)
+
+var TasksProcessedTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tasks_processed_total",
+		Help:      "Number of processed task attempts by task type and result (success, skipped or failure).",
+	},
+	[]string{"task_type", "result"},
+)
+
+var TasksProcessingDuration = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "tasks_processing_duration_seconds",
+		Help:      "Duration of task attempts by task type.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 16),
+	},
+	[]string{"task_type"},
+)
+
+// Exports the connection pool stats of the database, e.g. the connections in
+// use and the time spent waiting for one
+func RegisterDBStats(db *sql.DB) error {
+	err := prometheus.Register(collectors.NewDBStatsCollector(db, namespace))
+
+	// A process has one database, the first pool stays registered
+	if errors.As(err, &prometheus.AlreadyRegisteredError{}) {
+		return nil
+	}
+
+	return err
+}
+
+// Serves the metrics on their own listener, so they're not exposed together
+// with the API
+func NewServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", Handler())
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+}
+
+// Serves the metrics of the default registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+type EmailBlocklistSync struct {
+	bun.BaseModel `bun:"table:email_blocklist_syncs,alias:ebs"`
+
+	ID        int       `bun:"id,pk"`
+	Domains   string    `bun:"domains"`
+	SyncedAt  time.Time `bun:"synced_at,default:now()"`
+	CreatedAt time.Time `bun:"created_at,default:now()"`
+	UpdatedAt time.Time `bun:"updated_at,default:now()"`
+}
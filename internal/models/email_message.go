@@ -0,0 +1,59 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+const EmailMessageStatusQueued = "queued"
+const EmailMessageStatusSent = "sent"
+const EmailMessageStatusFailed = "failed"
+const EmailMessageStatusDelivered = "delivered"
+const EmailMessageStatusBounced = "bounced"
+const EmailMessageStatusComplained = "complained"
+
+// Delivery events can arrive out of order or be redelivered, so a status only
+// replaces the statuses with a lower or the same precedence, e.g. a late
+// "delivered" never replaces "bounced"
+var emailMessageStatusPrecedences = map[string]int{
+	EmailMessageStatusQueued:     0,
+	EmailMessageStatusSent:       1,
+	EmailMessageStatusFailed:     1,
+	EmailMessageStatusDelivered:  2,
+	EmailMessageStatusBounced:    3,
+	EmailMessageStatusComplained: 4,
+}
+
+// Returns the statuses the status can replace
+func EmailMessageStatusesReplaceableBy(status string) []string {
+	precedence, found := emailMessageStatusPrecedences[status]
+	if !found {
+		return nil
+	}
+
+	var statuses []string
+	for otherStatus, otherPrecedence := range emailMessageStatusPrecedences {
+		if otherPrecedence <= precedence {
+			statuses = append(statuses, otherStatus)
+		}
+	}
+
+	return statuses
+}
+
+type EmailMessage struct {
+	bun.BaseModel `bun:"table:email_messages,alias:em"`
+
+	ID                string         `bun:"id,pk"`
+	UserID            sql.NullString `bun:"user_id"`
+	Recipient         string         `bun:"recipient"`
+	Template          string         `bun:"template"`
+	ProviderMessageID sql.NullString `bun:"provider_message_id"`
+	Status            string         `bun:"status"`
+	StatusDetails     sql.NullString `bun:"status_details"`
+	StatusUpdatedAt   time.Time      `bun:"status_updated_at,default:now()"`
+	CreatedAt         time.Time      `bun:"created_at,default:now()"`
+	UpdatedAt         time.Time      `bun:"updated_at,default:now()"`
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"database/sql"
 	"time"
 
 	"github.com/uptrace/bun"
@@ -9,6 +10,11 @@ import (
 type EmailSendAttempt struct {
 	bun.BaseModel `bun:"table:email_send_attempts,alias:esa"`
 
-	ID          int       `bun:"id,pk"`
-	AttemptedAt time.Time `bun:"attempted_at,default:now()"`
+	ID              int            `bun:"id,pk,autoincrement"`
+	AttemptedAt     time.Time      `bun:"attempted_at,default:now()"`
+	Budget          string         `bun:"budget"`
+	Recipient       sql.NullString `bun:"recipient"`
+	RecipientDomain sql.NullString `bun:"recipient_domain"`
+	RequesterIP     sql.NullString `bun:"requester_ip"`
+	UserID          sql.NullString `bun:"user_id"`
 }
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+const EmailSuppressionReasonHardBounce = "hard_bounce"
+const EmailSuppressionReasonComplaint = "complaint"
+
+type EmailSuppression struct {
+	bun.BaseModel `bun:"table:email_suppressions,alias:es"`
+
+	ID             string         `bun:"id,pk"`
+	Email          string         `bun:"email"`
+	Reason         string         `bun:"reason"`
+	EmailMessageID sql.NullString `bun:"email_message_id"`
+	CreatedAt      time.Time      `bun:"created_at,default:now()"`
+	UpdatedAt      time.Time      `bun:"updated_at,default:now()"`
+}
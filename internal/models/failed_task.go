@@ -0,0 +1,26 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+type FailedTask struct {
+	bun.BaseModel `bun:"table:failed_tasks,alias:ft"`
+
+	ID         string       `bun:"id,pk"`
+	TaskID     string       `bun:"task_id"`
+	TaskType   string       `bun:"task_type"`
+	Queue      string       `bun:"queue"`
+	Payload    []byte       `bun:"payload"`
+	Error      string       `bun:"error"`
+	ErrorChain []string     `bun:"error_chain,array"`
+	Attempts   int          `bun:"attempts"`
+	MaxRetry   int          `bun:"max_retry"`
+	FailedAt   time.Time    `bun:"failed_at,default:now()"`
+	RetriedAt  sql.NullTime `bun:"retried_at"`
+	CreatedAt  time.Time    `bun:"created_at,default:now()"`
+	UpdatedAt  time.Time    `bun:"updated_at,default:now()"`
+}
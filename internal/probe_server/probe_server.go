@@ -0,0 +1,157 @@
+package probe_server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	loggerpkg "prutya/go-api-template/internal/logger"
+	"prutya/go-api-template/internal/metrics"
+)
+
+const statusOK = "ok"
+const statusUnavailable = "unavailable"
+
+// Reports whether a dependency (e.g. the database) is reachable
+type Check func(ctx context.Context) error
+
+type readinessResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// Serves the liveness and readiness probes of the background processes, and
+// optionally their metrics
+type ProbeServer struct {
+	httpServer   *http.Server
+	mux          *http.ServeMux
+	logger       *loggerpkg.Logger
+	checks       map[string]Check
+	checkTimeout time.Duration
+
+	shuttingDown atomic.Bool
+}
+
+func NewProbeServer(
+	addr string,
+	checkTimeout time.Duration,
+	metricsEnabled bool,
+	checks map[string]Check,
+	logger *loggerpkg.Logger,
+) *ProbeServer {
+	s := &ProbeServer{
+		logger:       logger,
+		checks:       checks,
+		checkTimeout: checkTimeout,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.handleHealthz)
+	mux.HandleFunc("GET /readyz", s.handleReadyz)
+
+	if metricsEnabled {
+		mux.Handle("GET /metrics", metrics.Handler())
+	}
+
+	s.mux = mux
+	s.httpServer = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	return s
+}
+
+// Serves the probes in the background. Readiness fails as soon as the process
+// receives a shutdown signal, liveness keeps passing until it exits.
+func (s *ProbeServer) Start() {
+	shutdownCh := make(chan os.Signal, 1)
+	signal.Notify(shutdownCh, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		<-shutdownCh
+		s.shuttingDown.Store(true)
+	}()
+
+	go func() {
+		s.logger.InfoContext(context.Background(), "Probe server is starting", "addr", s.httpServer.Addr)
+
+		if err := s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.ErrorContext(context.Background(), "Probe server stopped with an error", "error", err)
+		}
+	}()
+}
+
+// Serves an extra endpoint, e.g. for inspection. Must be called before Start.
+func (s *ProbeServer) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+func (s *ProbeServer) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
+}
+
+func (s *ProbeServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, http.StatusOK, map[string]string{"status": statusOK})
+}
+
+func (s *ProbeServer) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.checkTimeout)
+	defer cancel()
+
+	response := &readinessResponse{
+		Status: statusOK,
+		Checks: make(map[string]string, len(s.checks)),
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	for name, check := range s.checks {
+		wg.Go(func() {
+			// The probes are public, so the errors are only logged
+			result := statusOK
+			if err := check(ctx); err != nil {
+				result = statusUnavailable
+
+				s.logger.WarnContext(ctx, "Readiness check failed", "check", name, "error", err)
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			response.Checks[name] = result
+			if result != statusOK {
+				response.Status = statusUnavailable
+			}
+		})
+	}
+
+	wg.Wait()
+
+	if s.shuttingDown.Load() {
+		response.Status = statusUnavailable
+	}
+
+	httpStatus := http.StatusOK
+	if response.Status != statusOK {
+		httpStatus = http.StatusServiceUnavailable
+	}
+
+	writeJson(w, httpStatus, response)
+}
+
+func writeJson(w http.ResponseWriter, httpStatus int, object any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+
+	json.NewEncoder(w).Encode(object)
+}
@@ -0,0 +1,28 @@
+package redis_client
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Shares the Redis instance with the tasks queue. Keys are namespaced by their
+// users (e.g. "captcha:"), so they don't collide with the asynq keys.
+// The password is read for every new connection, so that a rotated one is
+// picked up.
+func New(ctx context.Context, addr string, password func() string) (*redis.Client, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr: addr,
+		CredentialsProvider: func() (string, string) {
+			return "", password()
+		},
+	})
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+
+		return nil, err
+	}
+
+	return client, nil
+}
@@ -18,6 +18,9 @@ type AccessTokenRepo interface {
 		expiresAt time.Time,
 	) error
 	FindById(ctx context.Context, id string) (*models.AccessToken, error)
+	// Deletes up to the limit of tokens that expired before the time, returns
+	// their number
+	DeleteExpiredBatch(ctx context.Context, before time.Time, limit int) (int, error)
 }
 
 type accessTokenRepo struct {
@@ -62,3 +65,23 @@ func (r *accessTokenRepo) FindById(
 
 	return accessToken, nil
 }
+
+func (r *accessTokenRepo) DeleteExpiredBatch(ctx context.Context, before time.Time, limit int) (int, error) {
+	batch := r.db.NewSelect().
+		Model((*models.AccessToken)(nil)).
+		Column("id").
+		Where("expires_at < ?", before).
+		Limit(limit)
+
+	result, err := r.db.NewDelete().
+		Model((*models.AccessToken)(nil)).
+		Where("id IN (?)", batch).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := result.RowsAffected()
+
+	return int(deleted), err
+}
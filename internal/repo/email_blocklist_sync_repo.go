@@ -0,0 +1,77 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"prutya/go-api-template/internal/models"
+)
+
+type EmailBlocklistSyncRepo interface {
+	// Returns nil if the blocklist has never been synced
+	TryFind(ctx context.Context) (*models.EmailBlocklistSync, error)
+	// Returns the zero time if the blocklist has never been synced
+	FindSyncedAt(ctx context.Context) (time.Time, error)
+	// Replaces the synced blocklist
+	Save(ctx context.Context, domains string) error
+}
+
+type emailBlocklistSyncRepo struct {
+	db bun.IDB
+}
+
+func NewEmailBlocklistSyncRepo(db bun.IDB) EmailBlocklistSyncRepo {
+	return &emailBlocklistSyncRepo{db: db}
+}
+
+func (r *emailBlocklistSyncRepo) TryFind(ctx context.Context) (*models.EmailBlocklistSync, error) {
+	emailBlocklistSync := &models.EmailBlocklistSync{}
+
+	err := r.db.NewSelect().
+		Model(emailBlocklistSync).
+		Limit(1).
+		Scan(ctx)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return emailBlocklistSync, nil
+}
+
+func (r *emailBlocklistSyncRepo) FindSyncedAt(ctx context.Context) (time.Time, error) {
+	var syncedAt time.Time
+
+	err := r.db.NewSelect().
+		Model((*models.EmailBlocklistSync)(nil)).
+		Column("synced_at").
+		Limit(1).
+		Scan(ctx, &syncedAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+
+	return syncedAt, err
+}
+
+func (r *emailBlocklistSyncRepo) Save(ctx context.Context, domains string) error {
+	_, err := r.db.NewInsert().
+		Model(&models.EmailBlocklistSync{ID: 1, Domains: domains}).
+		ExcludeColumn("synced_at", "created_at", "updated_at").
+		On("CONFLICT (id) DO UPDATE").
+		Set("domains = EXCLUDED.domains").
+		Set("synced_at = now()").
+		Set("updated_at = now()").
+		Exec(ctx)
+
+	return err
+}
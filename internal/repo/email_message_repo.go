@@ -0,0 +1,123 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/uptrace/bun"
+
+	"prutya/go-api-template/internal/models"
+)
+
+type EmailMessageRepo interface {
+	Create(
+		ctx context.Context,
+		emailMessageID string,
+		userID string,
+		recipient string,
+		template string,
+	) error
+	FindByProviderMessageID(ctx context.Context, providerMessageID string) (*models.EmailMessage, error)
+	MarkSent(ctx context.Context, emailMessageID string, providerMessageID string) error
+	// Reports false if the current status takes precedence over the new one,
+	// see `models.EmailMessageStatusesReplaceableBy`
+	UpdateStatus(ctx context.Context, emailMessageID string, status string, statusDetails string) (bool, error)
+}
+
+type emailMessageRepo struct {
+	db bun.IDB
+}
+
+func NewEmailMessageRepo(db bun.IDB) EmailMessageRepo {
+	return &emailMessageRepo{db: db}
+}
+
+func (r *emailMessageRepo) Create(
+	ctx context.Context,
+	emailMessageID string,
+	userID string,
+	recipient string,
+	template string,
+) error {
+	emailMessage := &models.EmailMessage{
+		ID:        emailMessageID,
+		Recipient: recipient,
+		Template:  template,
+		Status:    models.EmailMessageStatusQueued,
+	}
+
+	if userID != "" {
+		emailMessage.UserID = sql.NullString{String: userID, Valid: true}
+	}
+
+	if _, err := r.db.NewInsert().Model(emailMessage).Exec(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *emailMessageRepo) FindByProviderMessageID(
+	ctx context.Context,
+	providerMessageID string,
+) (*models.EmailMessage, error) {
+	emailMessage := &models.EmailMessage{}
+
+	err := r.db.NewSelect().
+		Model(emailMessage).
+		Where("provider_message_id = ?", providerMessageID).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return emailMessage, nil
+}
+
+func (r *emailMessageRepo) MarkSent(ctx context.Context, emailMessageID string, providerMessageID string) error {
+	query := r.db.NewUpdate().
+		Model((*models.EmailMessage)(nil)).
+		Set("status = ?", models.EmailMessageStatusSent).
+		Set("status_updated_at = now()").
+		Set("updated_at = now()").
+		Where("id = ?", emailMessageID)
+
+	if providerMessageID != "" {
+		query.Set("provider_message_id = ?", providerMessageID)
+	}
+
+	_, err := query.Exec(ctx)
+
+	return err
+}
+
+func (r *emailMessageRepo) UpdateStatus(
+	ctx context.Context,
+	emailMessageID string,
+	status string,
+	statusDetails string,
+) (bool, error) {
+	query := r.db.NewUpdate().
+		Model((*models.EmailMessage)(nil)).
+		Set("status = ?", status).
+		Set("status_updated_at = now()").
+		Set("updated_at = now()").
+		Where("id = ?", emailMessageID).
+		Where("status IN (?)", bun.In(models.EmailMessageStatusesReplaceableBy(status)))
+
+	if statusDetails != "" {
+		query.Set("status_details = ?", statusDetails)
+	}
+
+	result, err := query.Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
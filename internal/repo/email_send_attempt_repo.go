@@ -9,9 +9,31 @@ import (
 	"prutya/go-api-template/internal/models"
 )
 
+type EmailSendAttemptKeyCount struct {
+	Key   string `bun:"key"`
+	Count int    `bun:"count"`
+}
+
 type EmailSendAttemptRepo interface {
-	Create(ctx context.Context) error
-	CountInRange(ctx context.Context, rangeStart time.Time, rangeEnd time.Time) (int, error)
+	Create(ctx context.Context, attempt *models.EmailSendAttempt) error
+	// Takes a transaction-level advisory lock on the key, e.g.
+	// "recipient:user@example.com", that serializes the limit checks of
+	// concurrent senders for it. Must be called within a transaction.
+	Lock(ctx context.Context, key string) error
+	// Counts all attempts since the given time regardless of the budget
+	CountAllSince(ctx context.Context, since time.Time) (int, error)
+	// Counts the attempts of a budget since the given time. An empty column
+	// counts all attempts of the budget, otherwise only the attempts where the
+	// column equals the value.
+	CountSince(ctx context.Context, budget string, column string, value string, since time.Time) (int, error)
+	CountTopKeysSince(
+		ctx context.Context,
+		budget string,
+		column string,
+		since time.Time,
+		limit int,
+	) ([]*EmailSendAttemptKeyCount, error)
+	Delete(ctx context.Context, id int) error
 	DeleteBefore(ctx context.Context, before time.Time) error
 }
 
@@ -23,19 +45,85 @@ func NewEmailSendAttemptRepo(db bun.IDB) EmailSendAttemptRepo {
 	return &emailSendAttemptRepo{db: db}
 }
 
-func (r *emailSendAttemptRepo) Create(ctx context.Context) error {
-	_, err := r.db.ExecContext(ctx, `INSERT INTO email_send_attempts DEFAULT VALUES`)
+func (r *emailSendAttemptRepo) Create(ctx context.Context, attempt *models.EmailSendAttempt) error {
+	_, err := r.db.NewInsert().
+		Model(attempt).
+		ExcludeColumn("id", "attempted_at").
+		Returning("id").
+		Exec(ctx)
+
 	return err
 }
 
-func (r *emailSendAttemptRepo) CountInRange(ctx context.Context, rangeStart time.Time, rangeEnd time.Time) (int, error) {
+func (r *emailSendAttemptRepo) Lock(ctx context.Context, key string) error {
+	_, err := r.db.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtextextended(?, 0))", "email_send_attempts:"+key)
+
+	return err
+}
+
+func (r *emailSendAttemptRepo) CountAllSince(ctx context.Context, since time.Time) (int, error) {
 	return r.db.NewSelect().
 		Model((*models.EmailSendAttempt)(nil)).
-		Where("attempted_at >= ?", rangeStart).
-		Where("attempted_at < ?", rangeEnd).
+		Where("attempted_at >= ?", since).
 		Count(ctx)
 }
 
+func (r *emailSendAttemptRepo) CountSince(
+	ctx context.Context,
+	budget string,
+	column string,
+	value string,
+	since time.Time,
+) (int, error) {
+	query := r.db.NewSelect().
+		Model((*models.EmailSendAttempt)(nil)).
+		Where("budget = ?", budget).
+		Where("attempted_at >= ?", since)
+
+	if column != "" {
+		query.Where("? = ?", bun.Ident(column), value)
+	}
+
+	return query.Count(ctx)
+}
+
+func (r *emailSendAttemptRepo) CountTopKeysSince(
+	ctx context.Context,
+	budget string,
+	column string,
+	since time.Time,
+	limit int,
+) ([]*EmailSendAttemptKeyCount, error) {
+	keyCounts := []*EmailSendAttemptKeyCount{}
+
+	err := r.db.NewSelect().
+		Model((*models.EmailSendAttempt)(nil)).
+		ColumnExpr("?::text AS key", bun.Ident(column)).
+		ColumnExpr("count(*) AS count").
+		Where("budget = ?", budget).
+		Where("attempted_at >= ?", since).
+		Where("? IS NOT NULL", bun.Ident(column)).
+		GroupExpr("?", bun.Ident(column)).
+		OrderExpr("count DESC").
+		Limit(limit).
+		Scan(ctx, &keyCounts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return keyCounts, nil
+}
+
+func (r *emailSendAttemptRepo) Delete(ctx context.Context, id int) error {
+	_, err := r.db.NewDelete().
+		Model((*models.EmailSendAttempt)(nil)).
+		Where("id = ?", id).
+		Exec(ctx)
+
+	return err
+}
+
 func (r *emailSendAttemptRepo) DeleteBefore(ctx context.Context, before time.Time) error {
 	_, err := r.db.NewDelete().
 		Model((*models.EmailSendAttempt)(nil)).
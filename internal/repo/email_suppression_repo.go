@@ -0,0 +1,55 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/uptrace/bun"
+
+	"prutya/go-api-template/internal/models"
+)
+
+type EmailSuppressionRepo interface {
+	Exists(ctx context.Context, email string) (bool, error)
+	Create(ctx context.Context, email string, reason string, emailMessageID string) error
+}
+
+type emailSuppressionRepo struct {
+	db bun.IDB
+}
+
+func NewEmailSuppressionRepo(db bun.IDB) EmailSuppressionRepo {
+	return &emailSuppressionRepo{db: db}
+}
+
+func (r *emailSuppressionRepo) Exists(ctx context.Context, email string) (bool, error) {
+	return r.db.NewSelect().
+		Model((*models.EmailSuppression)(nil)).
+		Where("lower(email) = lower(?)", email).
+		Exists(ctx)
+}
+
+// Suppressing an address that is already suppressed is a no-op
+func (r *emailSuppressionRepo) Create(
+	ctx context.Context,
+	email string,
+	reason string,
+	emailMessageID string,
+) error {
+	emailSuppression := &models.EmailSuppression{
+		Email:  email,
+		Reason: reason,
+	}
+
+	if emailMessageID != "" {
+		emailSuppression.EmailMessageID = sql.NullString{String: emailMessageID, Valid: true}
+	}
+
+	_, err := r.db.NewInsert().
+		Model(emailSuppression).
+		ExcludeColumn("id").
+		On("CONFLICT ((lower(email))) DO NOTHING").
+		Exec(ctx)
+
+	return err
+}
@@ -0,0 +1,136 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"prutya/go-api-template/internal/models"
+)
+
+type FailedTaskRepo interface {
+	Create(ctx context.Context, failedTask *models.FailedTask) error
+	// Returns nil if the failed task does not exist
+	TryFindByID(ctx context.Context, failedTaskID string) (*models.FailedTask, error)
+	// Newest first. The task type filter is ignored when it's empty.
+	ListWithPagination(
+		ctx context.Context,
+		taskType string,
+		pageSize int,
+		beforeFailedTaskID string,
+	) ([]*models.FailedTask, error)
+	MarkRetried(ctx context.Context, failedTaskID string) error
+	Delete(ctx context.Context, failedTaskID string) (bool, error)
+	DeleteBefore(ctx context.Context, before time.Time) ([]*models.FailedTask, error)
+}
+
+type failedTaskRepo struct {
+	db bun.IDB
+}
+
+func NewFailedTaskRepo(db bun.IDB) FailedTaskRepo {
+	return &failedTaskRepo{db: db}
+}
+
+func (r *failedTaskRepo) Create(ctx context.Context, failedTask *models.FailedTask) error {
+	_, err := r.db.NewInsert().Model(failedTask).Exec(ctx)
+
+	return err
+}
+
+func (r *failedTaskRepo) TryFindByID(ctx context.Context, failedTaskID string) (*models.FailedTask, error) {
+	failedTask := &models.FailedTask{}
+
+	err := r.db.NewSelect().
+		Model(failedTask).
+		Where("id = ?", failedTaskID).
+		Scan(ctx)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return failedTask, nil
+}
+
+func (r *failedTaskRepo) ListWithPagination(
+	ctx context.Context,
+	taskType string,
+	pageSize int,
+	beforeFailedTaskID string,
+) ([]*models.FailedTask, error) {
+	query := r.db.NewSelect().
+		Model((*models.FailedTask)(nil)).
+		Order("id DESC").
+		Limit(pageSize)
+
+	if taskType != "" {
+		query.Where("task_type = ?", taskType)
+	}
+
+	if beforeFailedTaskID != "" {
+		query.Where("id < ?", beforeFailedTaskID)
+	}
+
+	var failedTasks []*models.FailedTask
+	err := query.Scan(ctx, &failedTasks)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return []*models.FailedTask{}, nil
+	}
+
+	return failedTasks, err
+}
+
+func (r *failedTaskRepo) MarkRetried(ctx context.Context, failedTaskID string) error {
+	_, err := r.db.NewUpdate().
+		Model((*models.FailedTask)(nil)).
+		Set("retried_at = now()").
+		Set("updated_at = now()").
+		Where("id = ?", failedTaskID).
+		Exec(ctx)
+
+	return err
+}
+
+// Reports whether the failed task existed
+func (r *failedTaskRepo) Delete(ctx context.Context, failedTaskID string) (bool, error) {
+	result, err := r.db.NewDelete().
+		Model((*models.FailedTask)(nil)).
+		Where("id = ?", failedTaskID).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// Returns the deleted failed tasks
+func (r *failedTaskRepo) DeleteBefore(ctx context.Context, before time.Time) ([]*models.FailedTask, error) {
+	var failedTasks []*models.FailedTask
+
+	err := r.db.NewDelete().
+		Model(&failedTasks).
+		Where("failed_at < ?", before).
+		Returning("*").
+		Scan(ctx)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return []*models.FailedTask{}, nil
+	}
+
+	return failedTasks, err
+}
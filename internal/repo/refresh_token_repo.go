@@ -21,6 +21,9 @@ type RefreshTokenRepo interface {
 	) error
 	FindById(ctx context.Context, id string) (*models.RefreshToken, error)
 	Revoke(ctx context.Context, id string, revokedAt time.Time, leewayExpiresAt time.Time) error
+	// Deletes up to the limit of tokens that expired before the time along with
+	// their access tokens, returns their number. Must be called in a transaction.
+	DeleteExpiredBatch(ctx context.Context, before time.Time, limit int) (int, error)
 }
 
 type refreshTokenRepo struct {
@@ -89,3 +92,45 @@ func (r *refreshTokenRepo) Revoke(
 
 	return nil
 }
+
+func (r *refreshTokenRepo) DeleteExpiredBatch(ctx context.Context, before time.Time, limit int) (int, error) {
+	var ids []string
+
+	err := r.db.NewSelect().
+		Model((*models.RefreshToken)(nil)).
+		Column("id").
+		Where("expires_at < ?", before).
+		Limit(limit).
+		For("UPDATE SKIP LOCKED").
+		Scan(ctx, &ids)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	// The parent foreign key cascades, so detach the children first to not
+	// delete the tokens that are still valid
+	_, err = r.db.NewUpdate().
+		Model((*models.RefreshToken)(nil)).
+		Set("parent_id = NULL").
+		Where("parent_id IN (?)", bun.In(ids)).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := r.db.NewDelete().
+		Model((*models.RefreshToken)(nil)).
+		Where("id IN (?)", bun.In(ids)).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := result.RowsAffected()
+
+	return int(deleted), err
+}
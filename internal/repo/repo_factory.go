@@ -4,7 +4,11 @@ import "github.com/uptrace/bun"
 
 type RepoFactory interface {
 	NewAccessTokenRepo(db bun.IDB) AccessTokenRepo
+	NewEmailBlocklistSyncRepo(db bun.IDB) EmailBlocklistSyncRepo
+	NewEmailMessageRepo(db bun.IDB) EmailMessageRepo
 	NewEmailSendAttemptRepo(db bun.IDB) EmailSendAttemptRepo
+	NewEmailSuppressionRepo(db bun.IDB) EmailSuppressionRepo
+	NewFailedTaskRepo(db bun.IDB) FailedTaskRepo
 	NewRefreshTokenRepo(db bun.IDB) RefreshTokenRepo
 	NewSessionRepo(db bun.IDB) SessionRepo
 	NewUserRepo(db bun.IDB) UserRepo
@@ -20,10 +24,26 @@ func (f *repoFactory) NewAccessTokenRepo(db bun.IDB) AccessTokenRepo {
 	return NewAccessTokenRepo(db)
 }
 
+func (f *repoFactory) NewEmailBlocklistSyncRepo(db bun.IDB) EmailBlocklistSyncRepo {
+	return NewEmailBlocklistSyncRepo(db)
+}
+
+func (f *repoFactory) NewEmailMessageRepo(db bun.IDB) EmailMessageRepo {
+	return NewEmailMessageRepo(db)
+}
+
 func (f *repoFactory) NewEmailSendAttemptRepo(db bun.IDB) EmailSendAttemptRepo {
 	return NewEmailSendAttemptRepo(db)
 }
 
+func (f *repoFactory) NewEmailSuppressionRepo(db bun.IDB) EmailSuppressionRepo {
+	return NewEmailSuppressionRepo(db)
+}
+
+func (f *repoFactory) NewFailedTaskRepo(db bun.IDB) FailedTaskRepo {
+	return NewFailedTaskRepo(db)
+}
+
 func (f *repoFactory) NewRefreshTokenRepo(db bun.IDB) RefreshTokenRepo {
 	return NewRefreshTokenRepo(db)
 }
@@ -34,6 +34,9 @@ type SessionRepo interface {
 		pageSize int,
 		beforeSession *models.Session,
 	) ([]*models.Session, error)
+	// Deletes up to the limit of sessions that were terminated or expired before
+	// the time along with their tokens, returns their number
+	DeleteEndedBatch(ctx context.Context, before time.Time, limit int) (int, error)
 }
 
 type sessionRepo struct {
@@ -213,3 +216,24 @@ func (r *sessionRepo) GetActiveForUserWithPagination(
 
 	return sessions, err
 }
+
+func (r *sessionRepo) DeleteEndedBatch(ctx context.Context, before time.Time, limit int) (int, error) {
+	batch := r.db.NewSelect().
+		Model((*models.Session)(nil)).
+		Column("id").
+		WhereOr("terminated_at < ?", before).
+		WhereOr("expires_at < ?", before).
+		Limit(limit)
+
+	result, err := r.db.NewDelete().
+		Model((*models.Session)(nil)).
+		Where("id IN (?)", batch).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := result.RowsAffected()
+
+	return int(deleted), err
+}
@@ -33,14 +33,28 @@ type UserRepo interface {
 	) error
 	IncrementEmailVerificationAttempts(ctx context.Context, userId string) error
 	CompleteEmailVerification(ctx context.Context, userId string) error
-	UpdateEmailVerificationOtpDigest(ctx context.Context, userId string, digest string) error
+	// Reports false if the round has changed, i.e. the expiration time is not
+	// the given one anymore
+	UpdateEmailVerificationOtpDigest(
+		ctx context.Context,
+		userId string,
+		emailVerificationExpiresAt time.Time,
+		digest string,
+	) (bool, error)
 	StartPasswordReset(
 		ctx context.Context,
 		userId string,
 		passwordResetExpiresAt time.Time,
 		passwordResetCooldownResetsAt time.Time,
 	) error
-	UpdatePasswordResetOtpDigest(ctx context.Context, userId string, digest string) error
+	// Reports false if the round has changed, i.e. the expiration time is not
+	// the given one anymore
+	UpdatePasswordResetOtpDigest(
+		ctx context.Context,
+		userId string,
+		passwordResetExpiresAt time.Time,
+		digest string,
+	) (bool, error)
 	IncrementPasswordResetAttempts(ctx context.Context, userId string) error
 	StorePasswordResetTokenKey(
 		ctx context.Context,
@@ -209,16 +223,26 @@ func (r *userRepo) CompleteEmailVerification(
 func (r *userRepo) UpdateEmailVerificationOtpDigest(
 	ctx context.Context,
 	userId string,
+	emailVerificationExpiresAt time.Time,
 	digest string,
-) error {
-	_, err := r.db.NewUpdate().
+) (bool, error) {
+	result, err := r.db.NewUpdate().
 		Model((*models.User)(nil)).
 		Set("email_verification_otp_digest = ?", digest).
 		Set("updated_at = now()").
 		Where("id = ?", userId).
+		Where("email_verification_expires_at = ?", emailVerificationExpiresAt).
 		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
 
-	return err
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
 }
 
 func (r *userRepo) StartPasswordReset(
@@ -244,16 +268,26 @@ func (r *userRepo) StartPasswordReset(
 func (r *userRepo) UpdatePasswordResetOtpDigest(
 	ctx context.Context,
 	userId string,
+	passwordResetExpiresAt time.Time,
 	digest string,
-) error {
-	_, err := r.db.NewUpdate().
+) (bool, error) {
+	result, err := r.db.NewUpdate().
 		Model((*models.User)(nil)).
 		Set("password_reset_otp_digest = ?", digest).
 		Set("updated_at = now()").
 		Where("id = ?", userId).
+		Where("password_reset_expires_at = ?", passwordResetExpiresAt).
 		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
 
-	return err
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
 }
 
 func (r *userRepo) IncrementPasswordResetAttempts(
@@ -0,0 +1,25 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"strings"
+)
+
+type fileProvider struct{}
+
+// Reads the secrets from files, e.g. Docker or Kubernetes secrets mounted in
+// the container. The reference is the path of the file.
+func NewFileProvider() Provider {
+	return &fileProvider{}
+}
+
+func (p *fileProvider) Fetch(ctx context.Context, reference string) (string, error) {
+	content, err := os.ReadFile(reference)
+	if err != nil {
+		return "", err
+	}
+
+	// Files written by hand or with `echo` usually end with a newline
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
@@ -0,0 +1,53 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+var ErrSecretNotFound = errors.New("secret not found")
+var ErrInvalidReference = errors.New("invalid secret reference")
+
+// Where the secrets are read from, e.g. mounted files or a secret store
+type Provider interface {
+	// Reads the current value of the secret identified by the reference, e.g. a
+	// file path
+	Fetch(ctx context.Context, reference string) (string, error)
+}
+
+// A secret that follows the rotations in its provider, see `Store.Refresh`
+type Secret struct {
+	provider  Provider
+	reference string
+	value     atomic.Pointer[string]
+}
+
+// A secret set directly in the config, which never changes
+func NewStaticSecret(value string) *Secret {
+	secret := &Secret{}
+	secret.value.Store(&value)
+
+	return secret
+}
+
+// Returns the current value. Safe for concurrent use.
+func (s *Secret) Value() string {
+	return *s.value.Load()
+}
+
+// Re-reads the secret from its provider and reports whether it changed
+func (s *Secret) refresh(ctx context.Context) (bool, error) {
+	if s.provider == nil {
+		return false, nil
+	}
+
+	value, err := s.provider.Fetch(ctx, s.reference)
+	if err != nil {
+		return false, err
+	}
+
+	previous := s.value.Swap(&value)
+
+	return previous == nil || *previous != value, nil
+}
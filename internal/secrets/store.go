@@ -0,0 +1,103 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"prutya/go-api-template/internal/logger"
+)
+
+// Holds the secrets by name and keeps the ones read from providers up to date
+type Store struct {
+	secrets map[string]*Secret
+}
+
+func NewStore() *Store {
+	return &Store{
+		secrets: map[string]*Secret{},
+	}
+}
+
+// Reads the secret from the provider. It's re-read by `Refresh`.
+func (s *Store) Load(ctx context.Context, name string, provider Provider, reference string) (*Secret, error) {
+	secret := &Secret{
+		provider:  provider,
+		reference: reference,
+	}
+
+	if _, err := secret.refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	s.secrets[name] = secret
+
+	return secret, nil
+}
+
+// Stores a secret that never changes
+func (s *Store) Set(name string, value string) *Secret {
+	secret := NewStaticSecret(value)
+	s.secrets[name] = secret
+
+	return secret
+}
+
+// Returns nil if there's no secret with the name
+func (s *Store) Get(name string) *Secret {
+	return s.secrets[name]
+}
+
+// Re-reads the secrets from their providers. The secrets that fail to be read
+// keep their previous values.
+func (s *Store) Refresh(ctx context.Context) ([]string, error) {
+	var rotated []string
+	var errs []error
+
+	for name, secret := range s.secrets {
+		changed, err := secret.refresh(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+
+			continue
+		}
+
+		if changed {
+			rotated = append(rotated, name)
+		}
+	}
+
+	return rotated, errors.Join(errs...)
+}
+
+// Refreshes the secrets periodically until the context is done. Disabled when
+// the interval is 0.
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	logger := logger.MustFromContext(ctx)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				rotated, err := s.Refresh(ctx)
+				if err != nil {
+					logger.ErrorContext(ctx, "Failed to refresh secrets", "error", err)
+				}
+
+				for _, name := range rotated {
+					logger.InfoContext(ctx, "Secret rotated", "secret_name", name)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,86 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var ErrUnexpectedVaultResponse = errors.New("unexpected Vault response")
+
+type vaultProvider struct {
+	httpClient *http.Client
+	address    string
+	mount      string
+	token      func() string
+}
+
+// Reads the secrets from a KV version 2 secrets engine of Vault or a compatible
+// store, e.g. OpenBao. The reference is the path of the secret in the engine
+// and the key of the value, e.g. "app/database#url". The token is read on
+// every request, so that it can be rotated as well.
+func NewVaultProvider(httpClient *http.Client, address string, mount string, token func() string) Provider {
+	return &vaultProvider{
+		httpClient: httpClient,
+		address:    strings.TrimSuffix(address, "/"),
+		mount:      strings.Trim(mount, "/"),
+		token:      token,
+	}
+}
+
+type vaultKVResponse struct {
+	Data struct {
+		Data map[string]any `json:"data"`
+	} `json:"data"`
+}
+
+func (p *vaultProvider) Fetch(ctx context.Context, reference string) (string, error) {
+	path, key, found := strings.Cut(reference, "#")
+	path = strings.Trim(path, "/")
+
+	if !found || path == "" || key == "" {
+		return "", fmt.Errorf("%w: %s", ErrInvalidReference, reference)
+	}
+
+	endpoint, err := url.JoinPath(p.address, "v1", p.mount, "data", path)
+	if err != nil {
+		return "", err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+
+	request.Header.Set("X-Vault-Token", p.token())
+
+	response, err := p.httpClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, reference)
+	default:
+		return "", fmt.Errorf("%w: status %d", ErrUnexpectedVaultResponse, response.StatusCode)
+	}
+
+	var body vaultKVResponse
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return "", errors.Join(ErrUnexpectedVaultResponse, err)
+	}
+
+	value, ok := body.Data.Data[key].(string)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, reference)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,162 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// Serves the secrets of a KV version 2 engine mounted at "secret"
+type fakeVault struct {
+	mutex   sync.Mutex
+	token   string
+	secrets map[string]map[string]any
+}
+
+func (v *fakeVault) set(path string, data map[string]any) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	v.secrets[path] = data
+}
+
+func (v *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if r.Header.Get("X-Vault-Token") != v.token {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	data, found := v.secrets[r.URL.Path]
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"data": map[string]any{
+			"data":     data,
+			"metadata": map[string]any{"version": 1},
+		},
+	})
+}
+
+func newTestVault(t *testing.T) (*fakeVault, Provider) {
+	vault := &fakeVault{
+		token:   "test-token",
+		secrets: map[string]map[string]any{},
+	}
+
+	server := httptest.NewServer(vault)
+	t.Cleanup(server.Close)
+
+	provider := NewVaultProvider(server.Client(), server.URL, "secret", func() string { return "test-token" })
+
+	return vault, provider
+}
+
+func TestVaultProviderFetch(t *testing.T) {
+	vault, provider := newTestVault(t)
+	vault.set("/v1/secret/data/app/database", map[string]any{"url": "postgres://app:password@db/app"})
+
+	value, err := provider.Fetch(context.Background(), "app/database#url")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value != "postgres://app:password@db/app" {
+		t.Errorf("got %q", value)
+	}
+}
+
+func TestVaultProviderFetchErrors(t *testing.T) {
+	vault, provider := newTestVault(t)
+	vault.set("/v1/secret/data/app/database", map[string]any{"url": "postgres://app:password@db/app", "port": 5432})
+
+	tests := []struct {
+		reference string
+		err       error
+	}{
+		{"app/database", ErrInvalidReference},
+		{"#url", ErrInvalidReference},
+		{"app/missing#url", ErrSecretNotFound},
+		{"app/database#missing", ErrSecretNotFound},
+		{"app/database#port", ErrSecretNotFound},
+	}
+
+	for _, test := range tests {
+		if _, err := provider.Fetch(context.Background(), test.reference); !errors.Is(err, test.err) {
+			t.Errorf("%s: got %v, want %v", test.reference, err, test.err)
+		}
+	}
+
+	provider = NewVaultProvider(http.DefaultClient, "http://127.0.0.1:0", "secret", func() string { return "" })
+
+	if _, err := provider.Fetch(context.Background(), "app/database#url"); err == nil {
+		t.Error("expected an error when Vault is unreachable")
+	}
+}
+
+func TestVaultProviderForbidden(t *testing.T) {
+	vault, provider := newTestVault(t)
+	vault.set("/v1/secret/data/app/database", map[string]any{"url": "postgres://app:password@db/app"})
+
+	// The token was rotated in Vault, but not in the app
+	vault.mutex.Lock()
+	vault.token = "rotated-token"
+	vault.mutex.Unlock()
+
+	if _, err := provider.Fetch(context.Background(), "app/database#url"); !errors.Is(err, ErrUnexpectedVaultResponse) {
+		t.Errorf("got %v, want %v", err, ErrUnexpectedVaultResponse)
+	}
+}
+
+func TestStoreRefreshPicksUpRotatedSecrets(t *testing.T) {
+	vault, provider := newTestVault(t)
+	vault.set("/v1/secret/data/app/redis", map[string]any{"password": "first"})
+
+	store := NewStore()
+	store.Set("admin_api_token", "static")
+
+	secret, err := store.Load(context.Background(), "tasks_redis_password", provider, "app/redis#password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if secret.Value() != "first" {
+		t.Fatalf("got %q", secret.Value())
+	}
+
+	vault.set("/v1/secret/data/app/redis", map[string]any{"password": "second"})
+
+	rotated, err := store.Refresh(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(rotated, []string{"tasks_redis_password"}) {
+		t.Errorf("got rotated %v", rotated)
+	}
+
+	if secret.Value() != "second" {
+		t.Errorf("got %q", secret.Value())
+	}
+
+	// A failed refresh keeps the previous value
+	vault.set("/v1/secret/data/app/redis", map[string]any{})
+
+	if _, err := store.Refresh(context.Background()); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("got %v, want %v", err, ErrSecretNotFound)
+	}
+
+	if secret.Value() != "second" {
+		t.Errorf("got %q", secret.Value())
+	}
+}
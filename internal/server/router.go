@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
@@ -11,13 +12,21 @@ import (
 	"prutya/go-api-template/internal/config"
 	"prutya/go-api-template/internal/handlers/account"
 	"prutya/go-api-template/internal/handlers/account/sessions"
+	"prutya/go-api-template/internal/handlers/admin"
+	"prutya/go-api-template/internal/handlers/captcha"
+	"prutya/go-api-template/internal/handlers/health"
 	"prutya/go-api-template/internal/handlers/users"
 	"prutya/go-api-template/internal/handlers/utils"
+	"prutya/go-api-template/internal/handlers/webhooks"
+	healthpkg "prutya/go-api-template/internal/health"
 	loggerpkg "prutya/go-api-template/internal/logger"
 	"prutya/go-api-template/internal/services/authentication_service"
 	"prutya/go-api-template/internal/services/captcha_service"
+	"prutya/go-api-template/internal/services/failed_task_service"
+	"prutya/go-api-template/internal/services/risk_service"
 	"prutya/go-api-template/internal/services/transactional_email_service"
 	"prutya/go-api-template/internal/services/user_service"
+	"prutya/go-api-template/internal/tracing"
 )
 
 type Router struct {
@@ -26,28 +35,36 @@ type Router struct {
 
 func NewRouter(
 	config *config.Config,
+	configWatcher *config.Watcher,
 	logger *loggerpkg.Logger,
 	authenticationService authentication_service.AuthenticationService,
 	userService user_service.UserService,
 	transactionalEmailService transactional_email_service.TransactionalEmailService,
 	captchaService captcha_service.CaptchaService,
+	riskService risk_service.RiskService,
+	failedTaskService failed_task_service.FailedTaskService,
+	readiness *healthpkg.Readiness,
 ) *Router {
 	mux := chi.NewRouter()
 
+	accessLog, err := utils.NewAccessLog(
+		config.AccessLogFields,
+		config.AccessLogSlowThreshold,
+		config.AccessLogFormat,
+		config.AccessLogPath,
+	)
+	if err != nil {
+		logger.Fatal("Failed to set up the access log", "error", err)
+	}
+
 	// Middleware
 	mux.Use(utils.NewRequestIDMiddleware(generateRequestID))
 	mux.Use(middleware.RealIP)
-	mux.Use(utils.NewLoggerMiddleware(logger))
+	mux.Use(tracing.NewMiddleware())
+	mux.Use(utils.NewLoggerMiddleware(logger.Component("http"), accessLog))
 	mux.Use(utils.NewRecoverMiddleware())
 	mux.Use(utils.NewTimeoutMiddleware(config.RequestTimeout))
-	mux.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   config.CorsAllowedOrigins,
-		AllowedMethods:   config.CorsAllowedMethods,
-		AllowedHeaders:   config.CorsAllowedHeaders,
-		ExposedHeaders:   config.CorsExposedHeaders,
-		AllowCredentials: config.CorsAllowCredentials,
-		MaxAge:           int(config.CorsMaxAge.Seconds()),
-	}))
+	mux.Use(newCorsMiddleware(config, configWatcher).Handler)
 
 	// Handle 404 Not Found and render a custom response
 	mux.NotFound(func(w http.ResponseWriter, r *http.Request) {
@@ -59,12 +76,19 @@ func NewRouter(
 		utils.RenderError(w, r, utils.ErrMethodNotAllowed)
 	})
 
-	captchaCheckMiddleware := utils.NewCaptchaCheckMiddleware(captchaService)
+	captchaCheckMiddleware := func(action string) func(next http.Handler) http.Handler {
+		return utils.NewCaptchaCheckMiddleware(captchaService, riskService, config.CaptchaRiskASNHeader, action)
+	}
 	authenticationMiddleware := utils.NewAuthenticationMiddleware(authenticationService)
 
 	// NOTE: Use this in the routes that require email verification
 	// emailVerificationCheckMiddleware := utils.NewEmailVerificationCheckMiddleware(authenticationService)
 
+	// Probes
+
+	mux.Get("/healthz", health.NewHandler())
+	mux.Get("/readyz", health.NewReadinessHandler(readiness))
+
 	// API routes
 
 	// /account
@@ -74,15 +98,16 @@ func NewRouter(
 		r.Post("/verify-email", account.NewVerifyEmailHandler(config, authenticationService))
 		r.Post("/reset-password", account.NewResetPasswordHandler(config, authenticationService))
 
-		r.Group(func(r chi.Router) {
-			r.Use(captchaCheckMiddleware)
-
-			r.Post("/login", account.NewLoginHandler(config, authenticationService))
-			r.Post("/register", account.NewRegisterHandler(authenticationService))
-			r.Post("/request-email-verification", account.NewRequestEmailVerificationHandler(authenticationService))
-			r.Post("/request-password-reset", account.NewRequestPasswordResetHandler(authenticationService))
-			r.Post("/verify-password-reset-otp", account.NewVerifyPasswordResetOTPHandler(config, authenticationService))
-		})
+		r.With(captchaCheckMiddleware(captcha_service.ActionLogin)).
+			Post("/login", account.NewLoginHandler(config, authenticationService))
+		r.With(captchaCheckMiddleware(captcha_service.ActionRegister)).
+			Post("/register", account.NewRegisterHandler(authenticationService))
+		r.With(captchaCheckMiddleware(captcha_service.ActionRequestEmailVerification)).
+			Post("/request-email-verification", account.NewRequestEmailVerificationHandler(authenticationService))
+		r.With(captchaCheckMiddleware(captcha_service.ActionRequestPasswordReset)).
+			Post("/request-password-reset", account.NewRequestPasswordResetHandler(authenticationService))
+		r.With(captchaCheckMiddleware(captcha_service.ActionVerifyPasswordResetOTP)).
+			Post("/verify-password-reset-otp", account.NewVerifyPasswordResetOTPHandler(config, authenticationService))
 
 		r.Group(func(r chi.Router) {
 			r.Use(authenticationMiddleware)
@@ -98,6 +123,13 @@ func NewRouter(
 		})
 	})
 
+	// /captcha
+
+	mux.Route("/captcha", func(r chi.Router) {
+		r.Post("/challenge", captcha.NewChallengeHandler(captchaService))
+		r.Post("/verify", captcha.NewVerifyHandler(captchaService))
+	})
+
 	// /users
 
 	mux.Route("/users", func(r chi.Router) {
@@ -106,9 +138,56 @@ func NewRouter(
 		r.Get("/current", users.NewCurrentHandler(userService))
 	})
 
+	// /webhooks
+
+	mux.Route("/webhooks", func(r chi.Router) {
+		r.Post("/transactional-emails", webhooks.NewTransactionalEmailsHandler(transactionalEmailService))
+	})
+
+	// /admin
+
+	mux.Route("/admin", func(r chi.Router) {
+		r.Use(utils.NewAdminAuthenticationMiddleware(config.Secret("admin_api_token").Value))
+
+		r.Get("/email-rate-limits", admin.NewEmailRateLimitsHandler(transactionalEmailService))
+
+		r.Get("/log-levels", admin.NewLogLevelsGetHandler(logger.Levels()))
+		r.Put("/log-levels", admin.NewLogLevelsUpdateHandler(logger.Levels()))
+
+		r.Route("/failed-tasks", func(r chi.Router) {
+			r.Get("/", admin.NewFailedTasksListHandler(failedTaskService))
+			r.Delete("/", admin.NewFailedTasksPurgeBeforeHandler(failedTaskService))
+			r.Get("/{failedTaskID}", admin.NewFailedTasksGetHandler(failedTaskService))
+			r.Delete("/{failedTaskID}", admin.NewFailedTasksPurgeHandler(failedTaskService))
+			r.Post("/{failedTaskID}/retry", admin.NewFailedTasksRetryHandler(failedTaskService))
+		})
+	})
+
 	return &Router{mux: mux}
 }
 
+// Applies the CORS settings of the reloaded config without a restart
+func newCorsMiddleware(cfg *config.Config, configWatcher *config.Watcher) *utils.CorsMiddleware {
+	corsMiddleware := utils.NewCorsMiddleware(corsOptions(cfg))
+
+	configWatcher.Subscribe(func(ctx context.Context, previous *config.Config, current *config.Config) {
+		corsMiddleware.SetOptions(corsOptions(current))
+	})
+
+	return corsMiddleware
+}
+
+func corsOptions(cfg *config.Config) cors.Options {
+	return cors.Options{
+		AllowedOrigins:   cfg.CorsAllowedOrigins,
+		AllowedMethods:   cfg.CorsAllowedMethods,
+		AllowedHeaders:   cfg.CorsAllowedHeaders,
+		ExposedHeaders:   cfg.CorsExposedHeaders,
+		AllowCredentials: cfg.CorsAllowCredentials,
+		MaxAge:           int(cfg.CorsMaxAge.Seconds()),
+	}
+}
+
 func generateRequestID(r *http.Request) (string, error) {
 	val, err := uuid.NewV7()
 	if err != nil {
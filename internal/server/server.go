@@ -7,18 +7,28 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"prutya/go-api-template/internal/config"
+	"prutya/go-api-template/internal/health"
 	loggerpkg "prutya/go-api-template/internal/logger"
+	"prutya/go-api-template/internal/metrics"
 )
 
 type Server struct {
-	httpServer *http.Server
-	config     *config.Config
-	logger     *loggerpkg.Logger
+	httpServer    *http.Server
+	metricsServer *http.Server
+	readiness     *health.Readiness
+	config        *config.Config
+	logger        *loggerpkg.Logger
 }
 
-func NewServer(config *config.Config, router *Router, logger *loggerpkg.Logger) *Server {
+func NewServer(
+	config *config.Config,
+	router *Router,
+	readiness *health.Readiness,
+	logger *loggerpkg.Logger,
+) *Server {
 	httpServer := &http.Server{
 		Addr:              config.ListenAddr,
 		Handler:           router.mux,
@@ -28,10 +38,17 @@ func NewServer(config *config.Config, router *Router, logger *loggerpkg.Logger)
 		IdleTimeout:       config.IdleTimeout,
 	}
 
+	var metricsServer *http.Server
+	if config.MetricsListenAddr != "" {
+		metricsServer = metrics.NewServer(config.MetricsListenAddr)
+	}
+
 	return &Server{
-		httpServer: httpServer,
-		config:     config,
-		logger:     logger,
+		httpServer:    httpServer,
+		metricsServer: metricsServer,
+		readiness:     readiness,
+		config:        config,
+		logger:        logger,
 	}
 }
 
@@ -47,6 +64,13 @@ func (s *Server) Start() error {
 
 		s.logger.InfoContext(context.Background(), "Server is shutting down")
 
+		// Fail the readiness probe first and keep serving for a while, so that
+		// the load balancers stop routing requests before the listener closes
+		s.readiness.Drain()
+
+		s.logger.InfoContext(context.Background(), "Server is draining", "delay", s.config.ShutdownDrainDelay)
+		time.Sleep(s.config.ShutdownDrainDelay)
+
 		// Prepare a shutdown context
 		shutdownCtx, shutdownRelease := context.WithTimeout(
 			context.Background(),
@@ -62,8 +86,25 @@ func (s *Server) Start() error {
 		} else {
 			s.logger.InfoContext(context.Background(), "Server stopped")
 		}
+
+		// Stop serving the metrics last so that the shutdown can be observed
+		if s.metricsServer != nil {
+			if err := s.metricsServer.Shutdown(shutdownCtx); err != nil {
+				s.logger.ErrorContext(context.Background(), "Metrics server stopped with an error", "error", err)
+			}
+		}
 	}()
 
+	if s.metricsServer != nil {
+		go func() {
+			s.logger.InfoContext(context.Background(), "Metrics server is starting", "addr", s.config.MetricsListenAddr)
+
+			if err := s.metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.logger.ErrorContext(context.Background(), "Metrics server stopped with an error", "error", err)
+			}
+		}()
+	}
+
 	s.logger.InfoContext(context.Background(), "Server is starting", "addr", s.config.ListenAddr)
 
 	// This blocks until the server is stopped
@@ -6,7 +6,7 @@ import (
 	"crypto/x509"
 	"database/sql"
 	"errors"
-	"prutya/go-api-template/internal/logger"
+	loggerpkg "prutya/go-api-template/internal/logger"
 
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -15,7 +15,7 @@ func (s *authenticationService) Authenticate(
 	ctx context.Context,
 	accessToken string,
 ) (*AccessTokenClaims, error) {
-	logger := logger.MustFromContext(ctx)
+	logger := loggerpkg.MustFromContext(ctx)
 	accessTokenRepo := s.repoFactory.NewAccessTokenRepo(s.db)
 
 	// Prepare the validation key function
@@ -73,7 +73,7 @@ func (s *authenticationService) Authenticate(
 	)
 	if err != nil {
 		logger.WarnContext(ctx, "Access token verification failed", "error", err.Error())
-		logger.DebugContext(ctx, "Access token verification failed", "access_token", accessToken)
+		logger.DebugContext(ctx, "Access token verification failed", "access_token", loggerpkg.Secret(accessToken))
 
 		return nil, ErrInvalidAccessToken
 	}
@@ -3,6 +3,7 @@ package authentication_service
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/uptrace/bun"
@@ -10,6 +11,8 @@ import (
 	"prutya/go-api-template/internal/config"
 	"prutya/go-api-template/internal/models"
 	"prutya/go-api-template/internal/repo"
+	"prutya/go-api-template/internal/services/email_blocklist_service"
+	"prutya/go-api-template/internal/services/email_domain_validation_service"
 	"prutya/go-api-template/internal/services/transactional_email_service"
 	"prutya/go-api-template/internal/tasks_client"
 )
@@ -19,6 +22,7 @@ var ErrEmailAlreadyVerified = errors.New("email already verified")
 var ErrEmailVerificationCooldown = errors.New("email verification cooldown")
 var ErrEmailVerificationExpired = errors.New("email verification expired")
 var ErrEmailVerificationNotRequested = errors.New("email verification not requested")
+var ErrEmailVerificationSuperseded = errors.New("email verification superseded")
 var ErrTooManyOTPAttempts = errors.New("too many OTP attempts")
 var ErrInvalidOTP = errors.New("invalid OTP")
 var ErrUserAlreadyExists = errors.New("user already exists")
@@ -37,6 +41,7 @@ var ErrSessionExpired = errors.New("session expired")
 var ErrPasswordResetCooldown = errors.New("password reset cooldown")
 var ErrPasswordResetExpired = errors.New("password reset expired")
 var ErrPasswordResetNotRequested = errors.New("password reset not requested")
+var ErrPasswordResetSuperseded = errors.New("password reset superseded")
 var ErrInvalidPasswordResetTokenClaims = errors.New("invalid password reset token claims")
 var ErrInvalidPasswordResetToken = errors.New("invalid password reset token")
 
@@ -56,9 +61,11 @@ type PasswordResetTokenClaims struct {
 }
 
 type AuthenticationService interface {
-	Register(ctx context.Context, email string, password string) error
-	RequestNewVerificationEmail(ctx context.Context, email string) error
-	SendVerificationEmail(ctx context.Context, userID string) error
+	Register(ctx context.Context, email string, password string, requesterIP string) error
+	RequestNewVerificationEmail(ctx context.Context, email string, requesterIP string) error
+	// The expiration time identifies the verification round, the email is not
+	// sent if another round has started since. Zero skips the check.
+	SendVerificationEmail(ctx context.Context, userID string, requesterIP string, expiresAt time.Time) error
 	// VerifyEmail verifies the email address of a user using the provided token.
 	// If successful, logs the user in directly.
 	VerifyEmail(
@@ -86,8 +93,10 @@ type AuthenticationService interface {
 		newPassword string,
 		terminateOtherSessions bool,
 	) error
-	RequestPasswordReset(ctx context.Context, email string) error
-	SendPasswordResetEmail(ctx context.Context, userID string) error
+	RequestPasswordReset(ctx context.Context, email string, requesterIP string) error
+	// The expiration time identifies the password reset round, the email is not
+	// sent if another round has started since. Zero skips the check.
+	SendPasswordResetEmail(ctx context.Context, userID string, requesterIP string, expiresAt time.Time) error
 	VerifyPasswordResetOTP(ctx context.Context, email string, otp string) (string, error)
 	ResetPassword(
 		ctx context.Context,
@@ -108,6 +117,9 @@ type AuthenticationService interface {
 		accessTokenClaims *AccessTokenClaims,
 		sessionID string,
 	) (hasTerminatedCurrentSession bool, err error)
+	// Deletes the expired access and refresh tokens and the sessions that ended
+	// before the retention window
+	CleanupExpiredTokens(ctx context.Context) error
 }
 
 type authenticationService struct {
@@ -116,6 +128,9 @@ type authenticationService struct {
 	repoFactory               repo.RepoFactory
 	tasksClient               tasks_client.Client
 	transactionalEmailService transactional_email_service.TransactionalEmailService
+	emailBlocklistService     email_blocklist_service.EmailBlocklistService
+
+	emailDomainValidationService email_domain_validation_service.EmailDomainValidationService
 }
 
 func NewAuthenticationService(
@@ -124,6 +139,8 @@ func NewAuthenticationService(
 	repoFactory repo.RepoFactory,
 	tasksClient tasks_client.Client,
 	transactionalEmailService transactional_email_service.TransactionalEmailService,
+	emailBlocklistService email_blocklist_service.EmailBlocklistService,
+	emailDomainValidationService email_domain_validation_service.EmailDomainValidationService,
 ) AuthenticationService {
 	return &authenticationService{
 		config:                    config,
@@ -131,5 +148,8 @@ func NewAuthenticationService(
 		repoFactory:               repoFactory,
 		tasksClient:               tasksClient,
 		transactionalEmailService: transactionalEmailService,
+		emailBlocklistService:     emailBlocklistService,
+
+		emailDomainValidationService: emailDomainValidationService,
 	}
 }
@@ -2,7 +2,6 @@ package authentication_service
 
 import (
 	"context"
-	"prutya/go-api-template/internal/argon2_utils"
 )
 
 func (s *authenticationService) ChangePassword(
@@ -30,7 +29,7 @@ func (s *authenticationService) ChangePassword(
 	}
 
 	// Check if the password is correct
-	passwordMatch, err := argon2_utils.Compare(oldPassword, user.PasswordDigest)
+	passwordMatch, err := argon2Compare(ctx, oldPassword, user.PasswordDigest)
 	if err != nil {
 		return err
 	}
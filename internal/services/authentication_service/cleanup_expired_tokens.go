@@ -0,0 +1,94 @@
+package authentication_service
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	loggerpkg "prutya/go-api-template/internal/logger"
+	"prutya/go-api-template/internal/metrics"
+)
+
+func (s *authenticationService) CleanupExpiredTokens(ctx context.Context) error {
+	logger := loggerpkg.MustFromContext(ctx)
+
+	now := time.Now().UTC()
+	tokensExpiredBefore := now.Add(-s.config.AuthenticationCleanupTokenLeeway)
+	sessionsEndedBefore := now.Add(-s.config.AuthenticationCleanupSessionRetention)
+
+	accessTokenRepo := s.repoFactory.NewAccessTokenRepo(s.db)
+	sessionRepo := s.repoFactory.NewSessionRepo(s.db)
+
+	// Access tokens expire long before their refresh tokens, so most of them are
+	// deleted here rather than by the cascade
+	deletedAccessTokens, err := s.deleteInBatches(ctx, "access_tokens", func(ctx context.Context, limit int) (int, error) {
+		return accessTokenRepo.DeleteExpiredBatch(ctx, tokensExpiredBefore, limit)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Revoked refresh tokens are kept until they expire to detect their reuse
+	deletedRefreshTokens, err := s.deleteInBatches(ctx, "refresh_tokens", func(ctx context.Context, limit int) (int, error) {
+		deleted := 0
+
+		err := s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			var err error
+			deleted, err = s.repoFactory.NewRefreshTokenRepo(tx).DeleteExpiredBatch(ctx, tokensExpiredBefore, limit)
+
+			return err
+		})
+
+		return deleted, err
+	})
+	if err != nil {
+		return err
+	}
+
+	deletedSessions, err := s.deleteInBatches(ctx, "sessions", func(ctx context.Context, limit int) (int, error) {
+		return sessionRepo.DeleteEndedBatch(ctx, sessionsEndedBefore, limit)
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.InfoContext(
+		ctx,
+		"Expired tokens cleaned up",
+		"access_tokens", deletedAccessTokens,
+		"refresh_tokens", deletedRefreshTokens,
+		"sessions", deletedSessions,
+	)
+
+	return nil
+}
+
+// Runs the batch until it deletes less than the batch size. Every batch is a
+// separate statement, so the locks are released in between.
+func (s *authenticationService) deleteInBatches(
+	ctx context.Context,
+	table string,
+	deleteBatch func(ctx context.Context, limit int) (int, error),
+) (int, error) {
+	total := 0
+	batchSize := s.config.AuthenticationCleanupBatchSize
+
+	for {
+		deleted, err := deleteBatch(ctx, batchSize)
+		if err != nil {
+			return total, err
+		}
+
+		total += deleted
+		metrics.AuthenticationCleanupDeletedTotal.WithLabelValues(table).Add(float64(deleted))
+
+		if deleted < batchSize {
+			return total, nil
+		}
+
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+	}
+}
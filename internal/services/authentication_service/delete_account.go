@@ -2,7 +2,6 @@ package authentication_service
 
 import (
 	"context"
-	"prutya/go-api-template/internal/argon2_utils"
 )
 
 func (s *authenticationService) DeleteAccount(
@@ -21,7 +20,7 @@ func (s *authenticationService) DeleteAccount(
 	}
 
 	// Check if the password is correct
-	passwordMatch, err := argon2_utils.Compare(password, user.PasswordDigest)
+	passwordMatch, err := argon2Compare(ctx, password, user.PasswordDigest)
 	if err != nil {
 		return err
 	}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"prutya/go-api-template/internal/argon2_utils"
+	"prutya/go-api-template/internal/metrics"
 
 	"github.com/uptrace/bun"
 )
@@ -15,6 +15,27 @@ func (s *authenticationService) Login(
 	password string,
 	userAgent string,
 	ipAddress string,
+) (*CreateTokensResult, error) {
+	result, err := s.login(ctx, email, password, userAgent, ipAddress)
+
+	switch {
+	case err == nil:
+		metrics.AuthenticationLoginsTotal.WithLabelValues("succeeded").Inc()
+	case errors.Is(err, ErrInvalidCredentials):
+		metrics.AuthenticationLoginsTotal.WithLabelValues("invalid_credentials").Inc()
+	default:
+		metrics.AuthenticationLoginsTotal.WithLabelValues("failed").Inc()
+	}
+
+	return result, err
+}
+
+func (s *authenticationService) login(
+	ctx context.Context,
+	email string,
+	password string,
+	userAgent string,
+	ipAddress string,
 ) (*CreateTokensResult, error) {
 	defer withMinimumAllowedFunctionDuration(ctx, s.config.AuthenticationTimingAttackDelay)()
 
@@ -31,7 +52,7 @@ func (s *authenticationService) Login(
 	}
 
 	// Check if the password is correct
-	passwordMatch, err := argon2_utils.Compare(password, user.PasswordDigest)
+	passwordMatch, err := argon2Compare(ctx, password, user.PasswordDigest)
 	if err != nil {
 		return nil, err
 	}
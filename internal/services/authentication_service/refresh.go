@@ -12,6 +12,7 @@ import (
 	"github.com/uptrace/bun"
 
 	loggerpkg "prutya/go-api-template/internal/logger"
+	"prutya/go-api-template/internal/metrics"
 	"prutya/go-api-template/internal/models"
 )
 
@@ -65,7 +66,7 @@ func (s *authenticationService) Refresh(ctx context.Context, refreshToken string
 	)
 	if err != nil {
 		logger.WarnContext(ctx, "Refresh token verification failed", "error", err.Error())
-		logger.DebugContext(ctx, "Password reset token verification failed", "refresh_token", refreshToken)
+		logger.DebugContext(ctx, "Password reset token verification failed", "refresh_token", loggerpkg.Secret(refreshToken))
 
 		return nil, ErrInvalidRefreshToken
 	}
@@ -80,6 +81,7 @@ func (s *authenticationService) Refresh(ctx context.Context, refreshToken string
 
 		if time.Now().UTC().After(dbRefreshToken.LeewayExpiresAt.Time) {
 			logger.WarnContext(ctx, "RefreshToken reuse detected", "refresh_token_id", dbRefreshToken.ID)
+			metrics.AuthenticationRefreshTokenReuseTotal.WithLabelValues("rejected").Inc()
 
 			// The session is compromised, so we need to terminate it
 			if err := sessionRepo.TerminateByID(ctx, dbRefreshToken.SessionID, time.Now().UTC()); err != nil {
@@ -95,6 +97,7 @@ func (s *authenticationService) Refresh(ctx context.Context, refreshToken string
 				"RefreshToken reuse detected but within the leeway period",
 				"refresh_token_id", dbRefreshToken.ID,
 			)
+			metrics.AuthenticationRefreshTokenReuseTotal.WithLabelValues("within_leeway").Inc()
 		}
 	}
 
@@ -9,23 +9,46 @@ import (
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/uptrace/bun"
 
-	"prutya/go-api-template/internal/logger"
+	loggerpkg "prutya/go-api-template/internal/logger"
 )
 
 var ErrEmailDomainNotAllowed = errors.New("email domain not allowed")
+var ErrEmailDomainUndeliverable = errors.New("email domain undeliverable")
 
-func (s *authenticationService) Register(ctx context.Context, email string, password string) error {
+// Returned when the domain of the email can't receive emails, e.g. because of
+// a typo. Matches ErrEmailDomainUndeliverable with errors.Is.
+type EmailDomainUndeliverableError struct {
+	// A similar well-known domain, may be empty
+	Suggestion string
+}
+
+func (e *EmailDomainUndeliverableError) Error() string {
+	return ErrEmailDomainUndeliverable.Error()
+}
+
+func (e *EmailDomainUndeliverableError) Unwrap() error {
+	return ErrEmailDomainUndeliverable
+}
+
+func (s *authenticationService) Register(ctx context.Context, email string, password string, requesterIP string) error {
 	defer withMinimumAllowedFunctionDuration(ctx, s.config.AuthenticationTimingAttackDelay)()
 
 	// Check if the email domain is allowed
-	if !s.isEmailDomainAllowed(email) {
+	if !s.emailBlocklistService.IsAllowed(ctx, email) {
 		return ErrEmailDomainNotAllowed
 	}
 
-	logger := logger.MustFromContext(ctx)
+	// Check if the email domain can receive emails
+	if result := s.emailDomainValidationService.Validate(ctx, email); !result.Deliverable {
+		return &EmailDomainUndeliverableError{Suggestion: result.Suggestion}
+	}
+
+	logger := loggerpkg.MustFromContext(ctx)
 
 	var userID string
 
+	verificationExpiresAt := newRoundExpiresAt(s.config.AuthenticationEmailVerificationCodeTTL)
+
 	if err := s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
 		userRepo := s.repoFactory.NewUserRepo(tx)
 
@@ -33,20 +56,19 @@ func (s *authenticationService) Register(ctx context.Context, email string, pass
 		if err != nil {
 			// Handle postgres lock error
 			if pgErr, isPgErr := err.(*pgconn.PgError); isPgErr && pgErr.Code == "55P03" {
-				logger.DebugContext(ctx, pgErr.Error(), "email", email)
+				logger.DebugContext(ctx, pgErr.Error(), "email", loggerpkg.Email(email))
 
 				return ErrUserRecordLocked
 			}
 
 			if errors.Is(err, sql.ErrNoRows) {
-				logger.DebugContext(ctx, "user not found", "email", email)
+				logger.DebugContext(ctx, "user not found", "email", loggerpkg.Email(email))
 				// User not found, continue
 			} else {
 				return err
 			}
 		}
 
-		verificationExpiresAt := time.Now().UTC().Add(s.config.AuthenticationEmailVerificationCodeTTL)
 		veriticationCooldownResetsAt := time.Now().UTC().Add(s.config.AuthenticationEmailVerificationCooldown)
 
 		if user == nil {
@@ -72,7 +94,7 @@ func (s *authenticationService) Register(ctx context.Context, email string, pass
 			); err != nil {
 				// Handle unique constraint error
 				if pgErr, isPgErr := err.(*pgconn.PgError); isPgErr && pgErr.Code == "23505" {
-					logger.DebugContext(ctx, ErrUserAlreadyExists.Error(), "user_id", userID, "email", email)
+					logger.DebugContext(ctx, ErrUserAlreadyExists.Error(), "user_id", userID, "email", loggerpkg.Email(email))
 
 					return ErrUserAlreadyExists
 				}
@@ -84,14 +106,14 @@ func (s *authenticationService) Register(ctx context.Context, email string, pass
 
 			// If the email is already registered, and is verified, do nothing
 			if user.EmailVerifiedAt.Valid {
-				logger.DebugContext(ctx, ErrEmailAlreadyVerified.Error(), "user_id", userID, "email", email)
+				logger.DebugContext(ctx, ErrEmailAlreadyVerified.Error(), "user_id", userID, "email", loggerpkg.Email(email))
 
 				return ErrEmailAlreadyVerified
 			}
 
 			// Check cooldown
 			if user.EmailVerificationCooldownResetsAt.Valid && user.EmailVerificationCooldownResetsAt.Time.After(time.Now().UTC()) {
-				logger.DebugContext(ctx, ErrEmailVerificationCooldown.Error(), "user_id", userID, "email", email)
+				logger.DebugContext(ctx, ErrEmailVerificationCooldown.Error(), "user_id", userID, "email", loggerpkg.Email(email))
 
 				return ErrEmailVerificationCooldown
 			}
@@ -114,7 +136,7 @@ func (s *authenticationService) Register(ctx context.Context, email string, pass
 
 	// Schedule a verification email
 
-	if err := s.scheduleEmailVerification(ctx, userID); err != nil {
+	if err := s.scheduleEmailVerification(ctx, userID, requesterIP, verificationExpiresAt); err != nil {
 		return err
 	}
 
@@ -10,11 +10,13 @@ import (
 	"github.com/uptrace/bun"
 )
 
-func (s *authenticationService) RequestNewVerificationEmail(ctx context.Context, email string) error {
+func (s *authenticationService) RequestNewVerificationEmail(ctx context.Context, email string, requesterIP string) error {
 	defer withMinimumAllowedFunctionDuration(ctx, s.config.AuthenticationTimingAttackDelay)()
 
 	var userID string
 
+	verificationExpiresAt := newRoundExpiresAt(s.config.AuthenticationEmailVerificationCodeTTL)
+
 	if err := s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
 		userRepo := s.repoFactory.NewUserRepo(tx)
 
@@ -48,7 +50,7 @@ func (s *authenticationService) RequestNewVerificationEmail(ctx context.Context,
 		if err := userRepo.StartEmailVerification(
 			ctx,
 			userID,
-			time.Now().UTC().Add(s.config.AuthenticationEmailVerificationCodeTTL),
+			verificationExpiresAt,
 			time.Now().UTC().Add(s.config.AuthenticationEmailVerificationCooldown),
 		); err != nil {
 			return err
@@ -61,7 +63,7 @@ func (s *authenticationService) RequestNewVerificationEmail(ctx context.Context,
 
 	// Schedule a verification email
 
-	if err := s.scheduleEmailVerification(ctx, userID); err != nil {
+	if err := s.scheduleEmailVerification(ctx, userID, requesterIP, verificationExpiresAt); err != nil {
 		return err
 	}
 
@@ -9,17 +9,19 @@ import (
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/uptrace/bun"
 
-	"prutya/go-api-template/internal/logger"
+	loggerpkg "prutya/go-api-template/internal/logger"
 	"prutya/go-api-template/internal/tasks"
 )
 
-func (s *authenticationService) RequestPasswordReset(ctx context.Context, email string) error {
+func (s *authenticationService) RequestPasswordReset(ctx context.Context, email string, requesterIP string) error {
 	defer withMinimumAllowedFunctionDuration(ctx, s.config.AuthenticationTimingAttackDelay)()
 
-	logger := logger.MustFromContext(ctx)
+	logger := loggerpkg.MustFromContext(ctx)
 
 	var userID string
 
+	passwordResetExpiresAt := newRoundExpiresAt(s.config.AuthenticationPasswordResetCodeTTL)
+
 	if err := s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
 		userRepo := s.repoFactory.NewUserRepo(tx)
 
@@ -27,13 +29,13 @@ func (s *authenticationService) RequestPasswordReset(ctx context.Context, email
 		if err != nil {
 			// Handle postgres lock error
 			if pgErr, isPgErr := err.(*pgconn.PgError); isPgErr && pgErr.Code == "55P03" {
-				logger.DebugContext(ctx, pgErr.Error(), "email", email)
+				logger.DebugContext(ctx, pgErr.Error(), "email", loggerpkg.Email(email))
 
 				return ErrUserRecordLocked
 			}
 
 			if errors.Is(err, sql.ErrNoRows) {
-				logger.DebugContext(ctx, ErrUserNotFound.Error(), "email", email)
+				logger.DebugContext(ctx, ErrUserNotFound.Error(), "email", loggerpkg.Email(email))
 
 				return ErrUserNotFound
 			}
@@ -45,7 +47,7 @@ func (s *authenticationService) RequestPasswordReset(ctx context.Context, email
 
 		// Check cooldown
 		if user.PasswordResetCooldownResetsAt.Valid && user.PasswordResetCooldownResetsAt.Time.After(time.Now().UTC()) {
-			logger.DebugContext(ctx, ErrPasswordResetCooldown.Error(), "user_id", userID, "email", email)
+			logger.DebugContext(ctx, ErrPasswordResetCooldown.Error(), "user_id", userID, "email", loggerpkg.Email(email))
 
 			return ErrPasswordResetCooldown
 		}
@@ -56,7 +58,7 @@ func (s *authenticationService) RequestPasswordReset(ctx context.Context, email
 		if err := userRepo.StartPasswordReset(
 			ctx,
 			userID,
-			currentTime.Add(s.config.AuthenticationPasswordResetCodeTTL),
+			passwordResetExpiresAt,
 			currentTime.Add(s.config.AuthenticationPasswordResetCooldown),
 		); err != nil {
 			return err
@@ -68,13 +70,15 @@ func (s *authenticationService) RequestPasswordReset(ctx context.Context, email
 	}
 
 	// Schedule a password reset email
-	task, err := tasks.NewSendPasswordResetEmailTask(userID)
+	task, err := tasks.NewSendPasswordResetEmailTask(userID, requesterIP, passwordResetExpiresAt)
 	if err != nil {
 		return err
 	}
 
-	_, err = s.tasksClient.Enqueue(ctx, task)
-	if err != nil {
+	_, err = s.tasksClient.Enqueue(tasks.NewContextWithUserID(ctx, userID), task)
+
+	// The email for this round is already on its way
+	if err != nil && !errors.Is(err, tasks.ErrDuplicateTask) {
 		return err
 	}
 
@@ -5,6 +5,8 @@ import (
 	"context"
 	text_template "text/template"
 	"time"
+
+	"prutya/go-api-template/internal/services/transactional_email_service"
 )
 
 var PasswordResetEmailTemplateText = text_template.Must(text_template.New("password_reset_email").Parse(
@@ -37,7 +39,7 @@ var PasswordResetEmailTemplateHTML = text_template.Must(text_template.New("passw
 	`,
 ))
 
-func (s *authenticationService) SendPasswordResetEmail(ctx context.Context, userID string) error {
+func (s *authenticationService) SendPasswordResetEmail(ctx context.Context, userID string, requesterIP string, expiresAt time.Time) error {
 	userRepo := s.repoFactory.NewUserRepo(s.db)
 
 	user, err := findUserByID(ctx, userRepo, userID)
@@ -55,6 +57,12 @@ func (s *authenticationService) SendPasswordResetEmail(ctx context.Context, user
 		return ErrPasswordResetExpired
 	}
 
+	// An old failed job is retrying but a new round has started, sending would
+	// invalidate the code of the new round
+	if !expiresAt.IsZero() && !sameRound(user.PasswordResetExpiresAt.Time, expiresAt) {
+		return ErrPasswordResetSuperseded
+	}
+
 	otp, err := generateOtp()
 	if err != nil {
 		return err
@@ -65,10 +73,17 @@ func (s *authenticationService) SendPasswordResetEmail(ctx context.Context, user
 		return err
 	}
 
-	if err := userRepo.UpdatePasswordResetOtpDigest(ctx, userID, optHash); err != nil {
+	// The round is checked again by the update, in case a new one started since
+	// the user was read
+	updated, err := userRepo.UpdatePasswordResetOtpDigest(ctx, userID, user.PasswordResetExpiresAt.Time, optHash)
+	if err != nil {
 		return err
 	}
 
+	if !updated {
+		return ErrPasswordResetSuperseded
+	}
+
 	// Render the email template
 
 	displayCodeExpiresAt := user.PasswordResetExpiresAt.Time.Format(time.RFC3339)
@@ -94,6 +109,9 @@ func (s *authenticationService) SendPasswordResetEmail(ctx context.Context, user
 		ctx,
 		user.Email,
 		user.ID,
+		requesterIP,
+		transactional_email_service.BudgetCritical,
+		PasswordResetEmailTemplateText.Name(),
 		"Password reset",
 		textContentBuf.String(),
 		htmlContentBuf.String(),
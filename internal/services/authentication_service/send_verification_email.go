@@ -6,6 +6,8 @@ import (
 	html_template "html/template"
 	text_template "text/template"
 	"time"
+
+	"prutya/go-api-template/internal/services/transactional_email_service"
 )
 
 var VerificationEmailTemplateText = text_template.Must(text_template.New("verification_email").Parse(
@@ -38,7 +40,7 @@ var VerificationEmailTemplateHTML = html_template.Must(html_template.New("verifi
 	`,
 ))
 
-func (s *authenticationService) SendVerificationEmail(ctx context.Context, userID string) error {
+func (s *authenticationService) SendVerificationEmail(ctx context.Context, userID string, requesterIP string, expiresAt time.Time) error {
 	userRepo := s.repoFactory.NewUserRepo(s.db)
 
 	user, err := findUserByID(ctx, userRepo, userID)
@@ -61,6 +63,12 @@ func (s *authenticationService) SendVerificationEmail(ctx context.Context, userI
 		return ErrEmailVerificationExpired
 	}
 
+	// An old failed job is retrying but a new round has started, sending would
+	// invalidate the code of the new round
+	if !expiresAt.IsZero() && !sameRound(user.EmailVerificationExpiresAt.Time, expiresAt) {
+		return ErrEmailVerificationSuperseded
+	}
+
 	otp, err := generateOtp()
 	if err != nil {
 		return err
@@ -71,10 +79,17 @@ func (s *authenticationService) SendVerificationEmail(ctx context.Context, userI
 		return err
 	}
 
-	if err := userRepo.UpdateEmailVerificationOtpDigest(ctx, userID, optHash); err != nil {
+	// The round is checked again by the update, in case a new one started since
+	// the user was read
+	updated, err := userRepo.UpdateEmailVerificationOtpDigest(ctx, userID, user.EmailVerificationExpiresAt.Time, optHash)
+	if err != nil {
 		return err
 	}
 
+	if !updated {
+		return ErrEmailVerificationSuperseded
+	}
+
 	// Render the verification email templates
 
 	var textContentBuf bytes.Buffer
@@ -98,6 +113,9 @@ func (s *authenticationService) SendVerificationEmail(ctx context.Context, userI
 		ctx,
 		user.Email,
 		user.ID,
+		requesterIP,
+		transactional_email_service.BudgetNonCritical,
+		VerificationEmailTemplateText.Name(),
 		"Verify your email address",
 		textContentBuf.String(),
 		htmlContentBuf.String(),
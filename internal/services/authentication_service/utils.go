@@ -7,7 +7,6 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
-	"strings"
 	"time"
 
 	"github.com/gofrs/uuid/v5"
@@ -17,31 +16,35 @@ import (
 	"prutya/go-api-template/internal/models"
 	"prutya/go-api-template/internal/repo"
 	"prutya/go-api-template/internal/tasks"
+	"prutya/go-api-template/internal/tracing"
 )
 
-func (s *authenticationService) scheduleEmailVerification(ctx context.Context, userID string) error {
-	task, err := tasks.NewSendVerificationEmailTask(userID)
+func (s *authenticationService) scheduleEmailVerification(ctx context.Context, userID string, requesterIP string, expiresAt time.Time) error {
+	task, err := tasks.NewSendVerificationEmailTask(userID, requesterIP, expiresAt)
 
 	if err != nil {
 		return err
 	}
 
-	_, err = s.tasksClient.Enqueue(ctx, task)
+	_, err = s.tasksClient.Enqueue(tasks.NewContextWithUserID(ctx, userID), task)
 
-	if err != nil {
+	// The email for this round is already on its way
+	if err != nil && !errors.Is(err, tasks.ErrDuplicateTask) {
 		return err
 	}
 
 	return nil
 }
 
-func (s *authenticationService) isEmailDomainAllowed(email string) bool {
-	domain := strings.Split(email, "@")[1]
-	domain = strings.ToLower(domain)
-
-	_, blocked := s.config.AuthenticationEmailBlocklist[domain]
+// Returns the expiration time of a new email verification or password reset
+// round. It identifies the round, so it's truncated to the precision of the
+// database.
+func newRoundExpiresAt(ttl time.Duration) time.Time {
+	return time.Now().UTC().Add(ttl).Truncate(time.Microsecond)
+}
 
-	return !blocked
+func sameRound(storedExpiresAt time.Time, expiresAt time.Time) bool {
+	return storedExpiresAt.Truncate(time.Microsecond).Equal(expiresAt.Truncate(time.Microsecond))
 }
 
 func findUserByID(ctx context.Context, userRepo repo.UserRepo, userID string) (*models.User, error) {
@@ -72,11 +75,25 @@ func withMinimumAllowedFunctionDuration(ctx context.Context, minimumAllowedFunct
 		logger.MustDebugContext(ctx, "Function has returned", "real_duration", duration)
 
 		if timeLeft > 0 {
+			_, span := tracing.Start(ctx, "timing_attack_delay")
 			time.Sleep(timeLeft)
+			span.End()
 		}
 	}
 }
 
+// Compares the plaintext (a password or an OTP) with its digest in a span, the
+// hashing is deliberately slow
+func argon2Compare(ctx context.Context, plaintext string, encodedHash string) (bool, error) {
+	_, span := tracing.Start(ctx, "argon2_compare")
+	defer span.End()
+
+	match, err := argon2_utils.Compare(plaintext, encodedHash)
+	tracing.RecordError(span, err)
+
+	return match, err
+}
+
 func generateUUID() (string, error) {
 	uuid, err := uuid.NewV7()
 	if err != nil {
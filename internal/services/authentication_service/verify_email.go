@@ -4,8 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"prutya/go-api-template/internal/argon2_utils"
-	"prutya/go-api-template/internal/logger"
+	loggerpkg "prutya/go-api-template/internal/logger"
 	"time"
 )
 
@@ -18,13 +17,13 @@ func (s *authenticationService) VerifyEmail(
 ) (*CreateTokensResult, error) {
 	defer withMinimumAllowedFunctionDuration(ctx, s.config.AuthenticationTimingAttackDelay)()
 
-	logger := logger.MustFromContext(ctx)
+	logger := loggerpkg.MustFromContext(ctx)
 	userRepo := s.repoFactory.NewUserRepo(s.db)
 
 	user, err := userRepo.FindByEmail(ctx, email)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			logger.DebugContext(ctx, ErrUserNotFound.Error(), "email", email)
+			logger.DebugContext(ctx, ErrUserNotFound.Error(), "email", loggerpkg.Email(email))
 
 			return nil, ErrUserNotFound
 		}
@@ -53,13 +52,13 @@ func (s *authenticationService) VerifyEmail(
 		return nil, ErrEmailVerificationExpired
 	}
 
-	otpOk, err := argon2_utils.Compare(otp, user.EmailVerificationOtpDigest)
+	otpOk, err := argon2Compare(ctx, otp, user.EmailVerificationOtpDigest)
 	if err != nil {
 		return nil, err
 	}
 
 	if !otpOk {
-		logger.DebugContext(ctx, ErrInvalidOTP.Error(), "user_id", user.ID, "otp", otp)
+		logger.DebugContext(ctx, ErrInvalidOTP.Error(), "user_id", user.ID, "otp", loggerpkg.Secret(otp))
 
 		if err := userRepo.IncrementEmailVerificationAttempts(ctx, user.ID); err != nil {
 			return nil, err
@@ -11,9 +11,7 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-
-	"prutya/go-api-template/internal/argon2_utils"
-	"prutya/go-api-template/internal/logger"
+	loggerpkg "prutya/go-api-template/internal/logger"
 )
 
 func (s *authenticationService) VerifyPasswordResetOTP(
@@ -23,13 +21,13 @@ func (s *authenticationService) VerifyPasswordResetOTP(
 ) (string, error) {
 	defer withMinimumAllowedFunctionDuration(ctx, s.config.AuthenticationTimingAttackDelay)()
 
-	logger := logger.MustFromContext(ctx)
+	logger := loggerpkg.MustFromContext(ctx)
 	userRepo := s.repoFactory.NewUserRepo(s.db)
 
 	user, err := userRepo.FindByEmail(ctx, email)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			logger.DebugContext(ctx, ErrUserNotFound.Error(), "email", email)
+			logger.DebugContext(ctx, ErrUserNotFound.Error(), "email", loggerpkg.Email(email))
 
 			return "", ErrUserNotFound
 		}
@@ -57,13 +55,13 @@ func (s *authenticationService) VerifyPasswordResetOTP(
 		return "", ErrPasswordResetExpired
 	}
 
-	otpOk, err := argon2_utils.Compare(otp, user.PasswordResetOtpDigest)
+	otpOk, err := argon2Compare(ctx, otp, user.PasswordResetOtpDigest)
 	if err != nil {
 		return "", err
 	}
 
 	if !otpOk {
-		logger.DebugContext(ctx, "Invalid OTP", "user_id", user.ID, "otp", otp)
+		logger.DebugContext(ctx, "Invalid OTP", "user_id", user.ID, "otp", loggerpkg.Secret(otp))
 
 		if err := userRepo.IncrementPasswordResetAttempts(ctx, user.ID); err != nil {
 			return "", err
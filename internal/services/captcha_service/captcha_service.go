@@ -1,115 +1,260 @@
 package captcha_service
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/redis/go-redis/v9"
+
 	"prutya/go-api-template/internal/logger"
 )
 
+const ProviderTurnstile = "turnstile"
+const ProviderHCaptcha = "hcaptcha"
+const ProviderRecaptcha = "recaptcha"
+const ProviderProofOfWork = "pow"
+
+// The actions of the protected routes
+const ActionLogin = "login"
+const ActionRegister = "register"
+const ActionRequestEmailVerification = "request_email_verification"
+const ActionRequestPasswordReset = "request_password_reset"
+const ActionVerifyPasswordResetOTP = "verify_password_reset_otp"
+
+const tokenRedisKeyPrefix = "captcha:token:"
+
+// Error codes of the checks made on top of the provider verification
+const errorCodeHostnameMismatch = "hostname-mismatch"
+const errorCodeActionMismatch = "action-mismatch"
+const errorCodeTokenReused = "token-reused"
+
+var ErrUnknownProvider = errors.New("unknown captcha provider")
+var ErrChallengesNotSupported = errors.New("captcha provider does not issue challenges")
+
 type CaptchaService interface {
-	Verify(ctx context.Context, captchaResponse string, ip string) (bool, error)
+	// Verifies the captcha response for the action of the protected route (e.g.
+	// "login")
+	Verify(ctx context.Context, captchaResponse string, ip string, action string) (bool, error)
+	// Issues a proof-of-work challenge. Only supported by the "pow" provider.
+	IssueChallenge(ctx context.Context, action string) (*Challenge, error)
+	// Exchanges a solved proof-of-work challenge for a single-use captcha
+	// response. Only supported by the "pow" provider.
+	RedeemChallenge(ctx context.Context, challenge string, solution string) (*ChallengePass, error)
+}
+
+// The outcome of a verification as reported by the provider
+type Verification struct {
+	Success  bool
+	Hostname string
+	Action   string
+	// Custom data attached to the widget (Turnstile only)
+	CData string
+	// Only reported by score-based providers (reCAPTCHA v3)
+	Score      *float64
+	ErrorCodes []string
+}
+
+type Provider interface {
+	Verify(ctx context.Context, captchaResponse string, ip string, action string) (*Verification, error)
+}
+
+// Implemented by the providers that are able to issue their own challenges
+type challengeProvider interface {
+	IssueChallenge(ctx context.Context, action string) (*Challenge, error)
+	RedeemChallenge(ctx context.Context, challenge string, solution string) (*ChallengePass, error)
+}
+
+// The secrets are read on every use, so that rotated ones are picked up
+type ProviderConfig struct {
+	TurnstileBaseURL   string
+	TurnstileSecretKey func() string
+
+	HCaptchaBaseURL   string
+	HCaptchaSecretKey func() string
+	HCaptchaSiteKey   string
+
+	RecaptchaBaseURL         string
+	RecaptchaSecretKey       func() string
+	RecaptchaScoreThreshold  float64
+	RecaptchaScoreThresholds map[string]float64
+
+	ProofOfWorkSecret       func() string
+	ProofOfWorkDifficulty   int
+	ProofOfWorkChallengeTTL time.Duration
+	ProofOfWorkPassTTL      time.Duration
+}
+
+type providerFactory func(providerConfig *ProviderConfig, httpClient *http.Client, redisClient *redis.Client) (Provider, error)
+
+type providerRegistration struct {
+	factory providerFactory
+	// Whether the provider reports the action the token was issued for. Tokens
+	// of such providers must be bound to the action of the route.
+	reportsAction bool
+	// Whether the provider reports the hostname the token was issued on
+	reportsHostname bool
+}
+
+var providers = map[string]*providerRegistration{
+	ProviderTurnstile: {
+		factory: func(providerConfig *ProviderConfig, httpClient *http.Client, _ *redis.Client) (Provider, error) {
+			return newTurnstileProvider(httpClient, providerConfig.TurnstileBaseURL, providerConfig.TurnstileSecretKey), nil
+		},
+		reportsAction:   true,
+		reportsHostname: true,
+	},
+	ProviderHCaptcha: {
+		factory: func(providerConfig *ProviderConfig, httpClient *http.Client, _ *redis.Client) (Provider, error) {
+			return newHCaptchaProvider(
+				httpClient,
+				providerConfig.HCaptchaBaseURL,
+				providerConfig.HCaptchaSecretKey,
+				providerConfig.HCaptchaSiteKey,
+			), nil
+		},
+		reportsAction:   false,
+		reportsHostname: true,
+	},
+	ProviderRecaptcha: {
+		factory: func(providerConfig *ProviderConfig, httpClient *http.Client, _ *redis.Client) (Provider, error) {
+			return newRecaptchaProvider(
+				httpClient,
+				providerConfig.RecaptchaBaseURL,
+				providerConfig.RecaptchaSecretKey,
+				providerConfig.RecaptchaScoreThreshold,
+				providerConfig.RecaptchaScoreThresholds,
+			), nil
+		},
+		reportsAction:   true,
+		reportsHostname: true,
+	},
+	ProviderProofOfWork: {
+		factory: func(providerConfig *ProviderConfig, _ *http.Client, redisClient *redis.Client) (Provider, error) {
+			return newProofOfWorkProvider(
+				redisClient,
+				providerConfig.ProofOfWorkSecret,
+				providerConfig.ProofOfWorkDifficulty,
+				providerConfig.ProofOfWorkChallengeTTL,
+				providerConfig.ProofOfWorkPassTTL,
+			)
+		},
+		reportsAction:   true,
+		reportsHostname: false,
+	},
 }
 
 type captchaService struct {
-	httpClient *http.Client
-	baseURL    string
-	secretKey  string
+	provider         Provider
+	registration     *providerRegistration
+	allowedHostnames []string
+	tokenReplayTTL   time.Duration
+	redisClient      *redis.Client
 }
 
 func NewCaptchaService(
 	ctx context.Context,
 	enabled bool,
-	baseURL string,
-	secretKey string,
-) CaptchaService {
+	providerName string,
+	providerConfig *ProviderConfig,
+	allowedHostnames []string,
+	tokenReplayTTL time.Duration,
+	httpClient *http.Client,
+	redisClient *redis.Client,
+) (CaptchaService, error) {
 	if !enabled {
-		return newNoopCaptchaService(ctx)
-	}
-
-	// TODO: Make configurable
-	transport := http.DefaultTransport.(*http.Transport).Clone()
-	transport.MaxConnsPerHost = 100
-	transport.MaxIdleConns = 100
-	transport.MaxIdleConnsPerHost = 100
-	transport.IdleConnTimeout = 90 * time.Second
-	transport.TLSHandshakeTimeout = 10 * time.Second
-
-	// TODO: Make configurable
-	httpClient := &http.Client{
-		Transport: transport,
-		Timeout:   10 * time.Second,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			// Maximum redirects
-			if len(via) >= 5 {
-				return http.ErrUseLastResponse
-			}
-
-			return nil
-		},
+		return newNoopCaptchaService(ctx), nil
 	}
 
-	return &captchaService{
-		httpClient: httpClient,
-		baseURL:    baseURL,
-		secretKey:  secretKey,
+	registration, found := providers[providerName]
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, providerName)
 	}
-}
 
-type turnstileRequest struct {
-	Secret   string `json:"secret"`
-	Response string `json:"response"`
-	RemoteIP string `json:"remoteip"`
-}
+	if len(allowedHostnames) == 0 && registration.reportsHostname {
+		logger.MustWarnContext(ctx, "Captcha allowed hostnames are not set, tokens issued on any hostname will be accepted")
+	}
+
+	provider, err := registration.factory(providerConfig, httpClient, redisClient)
+	if err != nil {
+		return nil, err
+	}
 
-// NOTE: The actual response from the Turnstile API has more fields
-type turnstilePartialResponse struct {
-	Success    bool     `json:"success"`
-	ErrorCodes []string `json:"error-codes,omitempty"`
+	logger.MustInfoContext(ctx, "Captcha provider configured", "provider", providerName)
+
+	normalizedAllowedHostnames := make([]string, len(allowedHostnames))
+	for i, hostname := range allowedHostnames {
+		normalizedAllowedHostnames[i] = strings.ToLower(hostname)
+	}
+
+	return &captchaService{
+		provider:         provider,
+		registration:     registration,
+		allowedHostnames: normalizedAllowedHostnames,
+		tokenReplayTTL:   tokenReplayTTL,
+		redisClient:      redisClient,
+	}, nil
 }
 
-func (s *captchaService) Verify(ctx context.Context, captchaResponse string, ip string) (bool, error) {
+func (s *captchaService) Verify(ctx context.Context, captchaResponse string, ip string, action string) (bool, error) {
 	logger := logger.MustFromContext(ctx)
 
-	requestBody := &turnstileRequest{
-		Secret:   s.secretKey,
-		Response: captchaResponse,
-		RemoteIP: ip,
-	}
+	logger.DebugContext(ctx, "verifying captcha", "action", action)
 
-	jsonRequestBody, err := json.Marshal(requestBody)
+	// A token that has already passed must not be accepted again, e.g. on
+	// another protected route. This also saves a call to the provider.
+	tokenKey := tokenRedisKey(captchaResponse)
+
+	used, err := s.redisClient.Exists(ctx, tokenKey).Result()
 	if err != nil {
 		return false, err
 	}
 
-	logger.DebugContext(ctx, "sending captcha verification request")
+	if used > 0 {
+		logger.WarnContext(ctx, "captcha verification failed", "error_codes", []string{errorCodeTokenReused})
+
+		return false, nil
+	}
 
 	startTime := time.Now()
 
-	response, err := s.httpClient.Post(
-		s.baseURL+"/siteverify",
-		"application/json",
-		bytes.NewBuffer(jsonRequestBody),
-	)
+	verification, err := s.provider.Verify(ctx, captchaResponse, ip, action)
 	if err != nil {
 		return false, err
 	}
-	defer response.Body.Close()
 
-	requestDuration := time.Since(startTime)
+	logger.DebugContext(ctx, "captcha verification response", "duration", time.Since(startTime))
 
-	logger.DebugContext(ctx, "captcha verification response", "status_code", response.StatusCode, "duration", requestDuration)
+	s.checkBinding(verification, action)
 
-	responseBody := &turnstilePartialResponse{}
-	if err := json.NewDecoder(response.Body).Decode(responseBody); err != nil {
+	if !verification.Success {
+		logger.WarnContext(
+			ctx,
+			"captcha verification failed",
+			"error_codes", verification.ErrorCodes,
+			"hostname", verification.Hostname,
+			"action", verification.Action,
+			"cdata", verification.CData,
+			"score", verification.Score,
+		)
+
+		return false, nil
+	}
+
+	// Concurrent requests with the same token race here, only one of them wins
+	stored, err := s.redisClient.SetNX(ctx, tokenKey, 1, s.tokenReplayTTL).Result()
+	if err != nil {
 		return false, err
 	}
 
-	if !responseBody.Success {
-		logger.WarnContext(ctx, "captcha verification failed", "error_codes", responseBody.ErrorCodes)
+	if !stored {
+		logger.WarnContext(ctx, "captcha verification failed", "error_codes", []string{errorCodeTokenReused})
 
 		return false, nil
 	}
@@ -118,3 +263,50 @@ func (s *captchaService) Verify(ctx context.Context, captchaResponse string, ip
 
 	return true, nil
 }
+
+// Checks that the token was issued for this site and for this route
+func (s *captchaService) checkBinding(verification *Verification, action string) {
+	if !verification.Success {
+		return
+	}
+
+	if s.registration.reportsHostname &&
+		len(s.allowedHostnames) > 0 &&
+		!slices.Contains(s.allowedHostnames, strings.ToLower(verification.Hostname)) {
+
+		verification.Success = false
+		verification.ErrorCodes = append(verification.ErrorCodes, errorCodeHostnameMismatch)
+
+		return
+	}
+
+	if s.registration.reportsAction && verification.Action != action {
+		verification.Success = false
+		verification.ErrorCodes = append(verification.ErrorCodes, errorCodeActionMismatch)
+	}
+}
+
+// Tokens are stored hashed, they are credentials until they expire
+func tokenRedisKey(captchaResponse string) string {
+	hash := sha256.Sum256([]byte(captchaResponse))
+
+	return tokenRedisKeyPrefix + hex.EncodeToString(hash[:])
+}
+
+func (s *captchaService) IssueChallenge(ctx context.Context, action string) (*Challenge, error) {
+	provider, ok := s.provider.(challengeProvider)
+	if !ok {
+		return nil, ErrChallengesNotSupported
+	}
+
+	return provider.IssueChallenge(ctx, action)
+}
+
+func (s *captchaService) RedeemChallenge(ctx context.Context, challenge string, solution string) (*ChallengePass, error) {
+	provider, ok := s.provider.(challengeProvider)
+	if !ok {
+		return nil, ErrChallengesNotSupported
+	}
+
+	return provider.RedeemChallenge(ctx, challenge, solution)
+}
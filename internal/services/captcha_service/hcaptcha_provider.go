@@ -0,0 +1,55 @@
+package captcha_service
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+type hCaptchaProvider struct {
+	httpClient *http.Client
+	baseURL    string
+	secretKey  func() string
+	siteKey    string
+}
+
+func newHCaptchaProvider(httpClient *http.Client, baseURL string, secretKey func() string, siteKey string) *hCaptchaProvider {
+	return &hCaptchaProvider{
+		httpClient: httpClient,
+		baseURL:    baseURL,
+		secretKey:  secretKey,
+		siteKey:    siteKey,
+	}
+}
+
+// See https://docs.hcaptcha.com/#verify-the-user-response-server-side
+type hCaptchaResponse struct {
+	Success     bool     `json:"success"`
+	ChallengeTS string   `json:"challenge_ts"`
+	Hostname    string   `json:"hostname"`
+	ErrorCodes  []string `json:"error-codes,omitempty"`
+}
+
+// hCaptcha has no actions, the action is ignored
+func (p *hCaptchaProvider) Verify(ctx context.Context, captchaResponse string, ip string, _ string) (*Verification, error) {
+	form := url.Values{}
+	form.Set("secret", p.secretKey())
+	form.Set("response", captchaResponse)
+	form.Set("remoteip", ip)
+
+	// Makes hCaptcha reject tokens issued for other site keys
+	if p.siteKey != "" {
+		form.Set("sitekey", p.siteKey)
+	}
+
+	responseBody := &hCaptchaResponse{}
+	if err := postSiteverifyForm(ctx, p.httpClient, p.baseURL+"/siteverify", form, responseBody); err != nil {
+		return nil, err
+	}
+
+	return &Verification{
+		Success:    responseBody.Success,
+		Hostname:   responseBody.Hostname,
+		ErrorCodes: responseBody.ErrorCodes,
+	}, nil
+}
@@ -13,8 +13,16 @@ func newNoopCaptchaService(ctx context.Context) CaptchaService {
 	return &noopCaptchaService{}
 }
 
-func (s *noopCaptchaService) Verify(ctx context.Context, captchaResponse string, ip string) (bool, error) {
+func (s *noopCaptchaService) Verify(ctx context.Context, captchaResponse string, ip string, action string) (bool, error) {
 	logger.MustWarnContext(ctx, "Fake captcha verification returns true")
 
 	return true, nil
 }
+
+func (s *noopCaptchaService) IssueChallenge(ctx context.Context, action string) (*Challenge, error) {
+	return nil, ErrChallengesNotSupported
+}
+
+func (s *noopCaptchaService) RedeemChallenge(ctx context.Context, challenge string, solution string) (*ChallengePass, error) {
+	return nil, ErrChallengesNotSupported
+}
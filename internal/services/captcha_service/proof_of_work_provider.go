@@ -0,0 +1,288 @@
+package captcha_service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/bits"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const proofOfWorkKindChallenge = "challenge"
+const proofOfWorkKindPass = "pass"
+
+const proofOfWorkMaxDifficulty = 32
+const proofOfWorkMaxSolutionLength = 64
+const proofOfWorkRedisKeyPrefix = "captcha:pow:"
+
+var ErrInvalidProofOfWorkConfig = errors.New("invalid proof-of-work captcha configuration")
+var ErrInvalidChallenge = errors.New("invalid captcha challenge")
+var ErrChallengeExpired = errors.New("captcha challenge expired")
+var ErrInvalidSolution = errors.New("invalid captcha challenge solution")
+var ErrChallengeAlreadyUsed = errors.New("captcha challenge already used")
+
+// A client must find a solution such that SHA-256("<challenge>:<solution>")
+// starts with at least Difficulty zero bits
+type Challenge struct {
+	Challenge  string
+	Difficulty int
+	ExpiresAt  time.Time
+}
+
+// A single-use captcha response, sent like the responses of other providers
+type ChallengePass struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+// The signed payload of challenges and passes
+type proofOfWorkClaims struct {
+	Kind       string `json:"k"`
+	Nonce      string `json:"n"`
+	Action     string `json:"a,omitempty"`
+	Difficulty int    `json:"d,omitempty"`
+	ExpiresAt  int64  `json:"e"`
+}
+
+// Self-hosted captcha for deployments that can't call third parties. The
+// challenges are stateless (HMAC-signed), Redis is only used to make every
+// challenge and pass usable once.
+type proofOfWorkProvider struct {
+	redisClient  *redis.Client
+	secret       func() string
+	difficulty   int
+	challengeTTL time.Duration
+	passTTL      time.Duration
+}
+
+func newProofOfWorkProvider(
+	redisClient *redis.Client,
+	secret func() string,
+	difficulty int,
+	challengeTTL time.Duration,
+	passTTL time.Duration,
+) (*proofOfWorkProvider, error) {
+	if redisClient == nil {
+		return nil, errors.Join(ErrInvalidProofOfWorkConfig, errors.New("redis is required"))
+	}
+
+	if len(secret()) < 32 {
+		return nil, errors.Join(ErrInvalidProofOfWorkConfig, errors.New("secret must be at least 32 characters"))
+	}
+
+	if difficulty < 1 || difficulty > proofOfWorkMaxDifficulty {
+		return nil, errors.Join(ErrInvalidProofOfWorkConfig, errors.New("difficulty must be between 1 and 32"))
+	}
+
+	return &proofOfWorkProvider{
+		redisClient:  redisClient,
+		secret:       secret,
+		difficulty:   difficulty,
+		challengeTTL: challengeTTL,
+		passTTL:      passTTL,
+	}, nil
+}
+
+func (p *proofOfWorkProvider) IssueChallenge(ctx context.Context, action string) (*Challenge, error) {
+	nonce, err := generateNonce()
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(p.challengeTTL)
+
+	challenge, err := p.sign(&proofOfWorkClaims{
+		Kind:       proofOfWorkKindChallenge,
+		Nonce:      nonce,
+		Action:     action,
+		Difficulty: p.difficulty,
+		ExpiresAt:  expiresAt.Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Challenge{
+		Challenge:  challenge,
+		Difficulty: p.difficulty,
+		ExpiresAt:  expiresAt,
+	}, nil
+}
+
+func (p *proofOfWorkProvider) RedeemChallenge(ctx context.Context, challenge string, solution string) (*ChallengePass, error) {
+	claims, err := p.parse(challenge, proofOfWorkKindChallenge)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(solution) == 0 || len(solution) > proofOfWorkMaxSolutionLength {
+		return nil, ErrInvalidSolution
+	}
+
+	if leadingZeroBits(sha256.Sum256([]byte(challenge+":"+solution))) < claims.Difficulty {
+		return nil, ErrInvalidSolution
+	}
+
+	if err := p.consume(ctx, claims); err != nil {
+		return nil, err
+	}
+
+	nonce, err := generateNonce()
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(p.passTTL)
+
+	token, err := p.sign(&proofOfWorkClaims{
+		Kind:      proofOfWorkKindPass,
+		Nonce:     nonce,
+		Action:    claims.Action,
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChallengePass{
+		Token:     token,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// Error codes follow the ones of the third-party providers. Passes are only
+// valid for the action of their challenge, which is checked by the service.
+func (p *proofOfWorkProvider) Verify(ctx context.Context, captchaResponse string, _ string, _ string) (*Verification, error) {
+	claims, err := p.parse(captchaResponse, proofOfWorkKindPass)
+	if err != nil {
+		if errors.Is(err, ErrChallengeExpired) {
+			return &Verification{Success: false, ErrorCodes: []string{"timeout-or-duplicate"}}, nil
+		}
+
+		return &Verification{Success: false, ErrorCodes: []string{"invalid-input-response"}}, nil
+	}
+
+	if err := p.consume(ctx, claims); err != nil {
+		if errors.Is(err, ErrChallengeAlreadyUsed) {
+			return &Verification{
+				Success:    false,
+				Action:     claims.Action,
+				ErrorCodes: []string{"timeout-or-duplicate"},
+			}, nil
+		}
+
+		return nil, err
+	}
+
+	return &Verification{Success: true, Action: claims.Action}, nil
+}
+
+func (p *proofOfWorkProvider) sign(claims *proofOfWorkClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	encodedPayload := base64.RawURLEncoding.EncodeToString(payload)
+
+	return encodedPayload + "." + base64.RawURLEncoding.EncodeToString(p.mac(encodedPayload)), nil
+}
+
+func (p *proofOfWorkProvider) parse(token string, kind string) (*proofOfWorkClaims, error) {
+	encodedPayload, encodedSignature, found := strings.Cut(token, ".")
+	if !found {
+		return nil, ErrInvalidChallenge
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return nil, ErrInvalidChallenge
+	}
+
+	if !hmac.Equal(signature, p.mac(encodedPayload)) {
+		return nil, ErrInvalidChallenge
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, ErrInvalidChallenge
+	}
+
+	claims := &proofOfWorkClaims{}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, ErrInvalidChallenge
+	}
+
+	if claims.Kind != kind {
+		return nil, ErrInvalidChallenge
+	}
+
+	if time.Now().After(time.Unix(claims.ExpiresAt, 0)) {
+		return nil, ErrChallengeExpired
+	}
+
+	return claims, nil
+}
+
+func (p *proofOfWorkProvider) mac(encodedPayload string) []byte {
+	// A rotated secret invalidates the challenges and passes issued before
+	mac := hmac.New(sha256.New, []byte(p.secret()))
+	mac.Write([]byte(encodedPayload))
+
+	return mac.Sum(nil)
+}
+
+// Marks the nonce as used until the token expires. Afterwards the token is
+// rejected by the expiration check, so the key is no longer needed.
+func (p *proofOfWorkProvider) consume(ctx context.Context, claims *proofOfWorkClaims) error {
+	ttl := time.Until(time.Unix(claims.ExpiresAt, 0)) + time.Second
+
+	stored, err := p.redisClient.SetNX(
+		ctx,
+		proofOfWorkRedisKeyPrefix+claims.Kind+":"+claims.Nonce,
+		1,
+		ttl,
+	).Result()
+	if err != nil {
+		return err
+	}
+
+	if !stored {
+		return ErrChallengeAlreadyUsed
+	}
+
+	return nil
+}
+
+func generateNonce() (string, error) {
+	nonce := make([]byte, 16)
+
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(nonce), nil
+}
+
+func leadingZeroBits(hash [sha256.Size]byte) int {
+	count := 0
+
+	for _, b := range hash {
+		if b == 0 {
+			count += 8
+			continue
+		}
+
+		return count + bits.LeadingZeros8(b)
+	}
+
+	return count
+}
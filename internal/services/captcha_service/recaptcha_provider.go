@@ -0,0 +1,82 @@
+package captcha_service
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+const recaptchaErrorCodeScoreTooLow = "score-too-low"
+
+type recaptchaProvider struct {
+	httpClient *http.Client
+	baseURL    string
+	secretKey  func() string
+	// The minimum score (0.0 - 1.0) to pass, can be overridden per action
+	scoreThreshold  float64
+	scoreThresholds map[string]float64
+}
+
+func newRecaptchaProvider(
+	httpClient *http.Client,
+	baseURL string,
+	secretKey func() string,
+	scoreThreshold float64,
+	scoreThresholds map[string]float64,
+) *recaptchaProvider {
+	return &recaptchaProvider{
+		httpClient:      httpClient,
+		baseURL:         baseURL,
+		secretKey:       secretKey,
+		scoreThreshold:  scoreThreshold,
+		scoreThresholds: scoreThresholds,
+	}
+}
+
+// See https://developers.google.com/recaptcha/docs/v3#site_verify_response
+type recaptchaResponse struct {
+	Success     bool     `json:"success"`
+	Score       float64  `json:"score"`
+	Action      string   `json:"action"`
+	ChallengeTS string   `json:"challenge_ts"`
+	Hostname    string   `json:"hostname"`
+	ErrorCodes  []string `json:"error-codes,omitempty"`
+}
+
+func (p *recaptchaProvider) Verify(ctx context.Context, captchaResponse string, ip string, action string) (*Verification, error) {
+	form := url.Values{}
+	form.Set("secret", p.secretKey())
+	form.Set("response", captchaResponse)
+	form.Set("remoteip", ip)
+
+	responseBody := &recaptchaResponse{}
+	if err := postSiteverifyForm(ctx, p.httpClient, p.baseURL+"/siteverify", form, responseBody); err != nil {
+		return nil, err
+	}
+
+	verification := &Verification{
+		Success:    responseBody.Success,
+		Hostname:   responseBody.Hostname,
+		Action:     responseBody.Action,
+		Score:      &responseBody.Score,
+		ErrorCodes: responseBody.ErrorCodes,
+	}
+
+	if !verification.Success {
+		return verification, nil
+	}
+
+	// reCAPTCHA v3 always "succeeds", the decision is based on the score. The
+	// action is checked by the service.
+	scoreThreshold := p.scoreThreshold
+	if actionScoreThreshold, found := p.scoreThresholds[action]; found {
+		scoreThreshold = actionScoreThreshold
+	}
+
+	if responseBody.Score < scoreThreshold {
+		verification.Success = false
+		verification.ErrorCodes = append(verification.ErrorCodes, recaptchaErrorCodeScoreTooLow)
+	}
+
+	return verification, nil
+}
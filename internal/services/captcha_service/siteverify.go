@@ -0,0 +1,38 @@
+package captcha_service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// hCaptcha and reCAPTCHA share the same form-encoded "siteverify" protocol
+func postSiteverifyForm(
+	ctx context.Context,
+	httpClient *http.Client,
+	verifyURL string,
+	form url.Values,
+	responseBody any,
+) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, verifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("captcha verification failed with status %d", response.StatusCode)
+	}
+
+	return json.NewDecoder(response.Body).Decode(responseBody)
+}
@@ -0,0 +1,99 @@
+package captcha_service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gofrs/uuid/v5"
+
+	"prutya/go-api-template/internal/httpclient"
+)
+
+type turnstileProvider struct {
+	httpClient *http.Client
+	baseURL    string
+	secretKey  func() string
+}
+
+func newTurnstileProvider(httpClient *http.Client, baseURL string, secretKey func() string) *turnstileProvider {
+	return &turnstileProvider{
+		httpClient: httpClient,
+		baseURL:    baseURL,
+		secretKey:  secretKey,
+	}
+}
+
+type turnstileRequest struct {
+	Secret   string `json:"secret"`
+	Response string `json:"response"`
+	RemoteIP string `json:"remoteip"`
+	// Allows retrying the verification of the same token, otherwise Turnstile
+	// reports the retry as a duplicate
+	IdempotencyKey string `json:"idempotency_key"`
+}
+
+// See https://developers.cloudflare.com/turnstile/get-started/server-side-validation/
+type turnstileResponse struct {
+	Success     bool     `json:"success"`
+	ChallengeTS string   `json:"challenge_ts"`
+	Hostname    string   `json:"hostname"`
+	ErrorCodes  []string `json:"error-codes,omitempty"`
+	Action      string   `json:"action"`
+	CData       string   `json:"cdata"`
+}
+
+func (p *turnstileProvider) Verify(ctx context.Context, captchaResponse string, ip string, _ string) (*Verification, error) {
+	idempotencyKey, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+
+	jsonRequestBody, err := json.Marshal(&turnstileRequest{
+		Secret:         p.secretKey(),
+		Response:       captchaResponse,
+		RemoteIP:       ip,
+		IdempotencyKey: idempotencyKey.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The idempotency key makes it safe to retry the verification
+	request, err := http.NewRequestWithContext(
+		httpclient.WithIdempotent(ctx),
+		http.MethodPost,
+		p.baseURL+"/siteverify",
+		bytes.NewReader(jsonRequestBody),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := p.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("captcha verification failed with status %d", response.StatusCode)
+	}
+
+	responseBody := &turnstileResponse{}
+	if err := json.NewDecoder(response.Body).Decode(responseBody); err != nil {
+		return nil, err
+	}
+
+	return &Verification{
+		Success:    responseBody.Success,
+		Hostname:   responseBody.Hostname,
+		Action:     responseBody.Action,
+		CData:      responseBody.CData,
+		ErrorCodes: responseBody.ErrorCodes,
+	}, nil
+}
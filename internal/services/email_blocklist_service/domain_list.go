@@ -0,0 +1,79 @@
+package email_blocklist_service
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// A set of lowercase domains. A domain matches the list if it or any of its
+// parent domains is in the list, e.g. "mx.mailinator.com" matches
+// "mailinator.com".
+type domainList map[string]struct{}
+
+func (l domainList) matches(domain string) bool {
+	for {
+		if _, found := l[domain]; found {
+			return true
+		}
+
+		_, parent, found := strings.Cut(domain, ".")
+		if !found {
+			return false
+		}
+
+		domain = parent
+	}
+}
+
+// Reads a list with one domain per line. Empty lines and lines starting with
+// "#" are ignored. A missing file results in an empty list.
+func loadDomainList(path string) (domainList, bool, error) {
+	if path == "" {
+		return domainList{}, false, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return domainList{}, false, nil
+		}
+
+		return nil, false, err
+	}
+	defer f.Close()
+
+	list, err := parseDomainList(f)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return list, true, nil
+}
+
+func parseDomainList(r io.Reader) (domainList, error) {
+	list := domainList{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		list[normalizeDomain(line)] = struct{}{}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func normalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
@@ -0,0 +1,405 @@
+package email_blocklist_service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/uptrace/bun"
+
+	"prutya/go-api-template/internal/logger"
+	"prutya/go-api-template/internal/metrics"
+	"prutya/go-api-template/internal/repo"
+)
+
+// The blocklist is a few hundred kilobytes, anything much larger is not a list
+const maxSyncResponseSize = 16 * 1024 * 1024
+
+// How often Watch checks the database for a blocklist synced by another process
+const syncPollInterval = time.Minute
+
+var ErrSyncURLNotConfigured = errors.New("email blocklist sync URL is not configured")
+var ErrEmptySyncResponse = errors.New("email blocklist sync response has no domains")
+
+type EmailBlocklistService interface {
+	// Reports whether the domain of the email is allowed. The allowlist takes
+	// precedence over the blocklist.
+	IsAllowed(ctx context.Context, email string) bool
+	// Reads the lists from disk and the synced blocklist from the database again
+	Reload(ctx context.Context) error
+	// Downloads the blocklist from the sync URL and stores it in the database.
+	// It's blocked in addition to the blocklist file. The other processes pick
+	// it up via Watch.
+	Sync(ctx context.Context) error
+	// Reloads the lists on SIGHUP, whenever the files change and whenever the
+	// blocklist is synced, until the context is done
+	Watch(ctx context.Context) error
+	// Switches to other files, e.g. after the config is reloaded. The current
+	// lists are kept if the new files can't be loaded.
+	SetPaths(ctx context.Context, blocklistPath string, allowlistPath string) error
+}
+
+type lists struct {
+	blocked domainList
+	allowed domainList
+	// Where the paths pointed to when the lists were loaded, see
+	// targetsChanged
+	targets  listPaths
+	syncedAt time.Time
+}
+
+type listPaths struct {
+	blocklist string
+	allowlist string
+}
+
+type emailBlocklistService struct {
+	paths       atomic.Pointer[listPaths]
+	syncURL     string
+	httpClient  *http.Client
+	db          bun.IDB
+	repoFactory repo.RepoFactory
+
+	lists atomic.Pointer[lists]
+	// Set while Watch runs, so that the directories of new paths are watched
+	// as well
+	watcher atomic.Pointer[fsnotify.Watcher]
+}
+
+func NewEmailBlocklistService(
+	ctx context.Context,
+	blocklistPath string,
+	allowlistPath string,
+	syncURL string,
+	httpClient *http.Client,
+	db bun.IDB,
+	repoFactory repo.RepoFactory,
+) (EmailBlocklistService, error) {
+	s := &emailBlocklistService{
+		syncURL:     syncURL,
+		httpClient:  httpClient,
+		db:          db,
+		repoFactory: repoFactory,
+	}
+
+	s.paths.Store(&listPaths{blocklist: blocklistPath, allowlist: allowlistPath})
+
+	if err := s.Reload(ctx); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *emailBlocklistService) IsAllowed(ctx context.Context, email string) bool {
+	_, domain, found := strings.Cut(email, "@")
+	if !found {
+		return false
+	}
+
+	domain = normalizeDomain(domain)
+	lists := s.lists.Load()
+
+	if lists.allowed.matches(domain) {
+		return true
+	}
+
+	if lists.blocked.matches(domain) {
+		logger.MustFromContext(ctx).InfoContext(ctx, "Email domain is blocklisted", "domain", domain)
+		metrics.EmailBlocklistRejectionsTotal.WithLabelValues("blocklist").Inc()
+
+		return false
+	}
+
+	return true
+}
+
+func (s *emailBlocklistService) Reload(ctx context.Context) error {
+	logger := logger.MustFromContext(ctx)
+	paths := s.paths.Load()
+
+	blocked, blocklistFound, err := loadDomainList(paths.blocklist)
+	if err != nil {
+		metrics.EmailBlocklistReloadsTotal.WithLabelValues("error").Inc()
+
+		return fmt.Errorf("failed to load email blocklist: %w", err)
+	}
+
+	allowed, _, err := loadDomainList(paths.allowlist)
+	if err != nil {
+		metrics.EmailBlocklistReloadsTotal.WithLabelValues("error").Inc()
+
+		return fmt.Errorf("failed to load email allowlist: %w", err)
+	}
+
+	emailBlocklistSync, err := s.repoFactory.NewEmailBlocklistSyncRepo(s.db).TryFind(ctx)
+	if err != nil {
+		metrics.EmailBlocklistReloadsTotal.WithLabelValues("error").Inc()
+
+		return fmt.Errorf("failed to load synced email blocklist: %w", err)
+	}
+
+	var syncedAt time.Time
+
+	if emailBlocklistSync != nil {
+		synced, err := parseDomainList(strings.NewReader(emailBlocklistSync.Domains))
+		if err != nil {
+			metrics.EmailBlocklistReloadsTotal.WithLabelValues("error").Inc()
+
+			return fmt.Errorf("failed to parse synced email blocklist: %w", err)
+		}
+
+		for domain := range synced {
+			blocked[domain] = struct{}{}
+		}
+
+		syncedAt = emailBlocklistSync.SyncedAt
+	}
+
+	if !blocklistFound && emailBlocklistSync == nil {
+		logger.WarnContext(ctx, "Email blocklist file not found and the blocklist was never synced, no domains are blocked", "path", paths.blocklist)
+	}
+
+	s.lists.Store(&lists{
+		blocked:  blocked,
+		allowed:  allowed,
+		targets:  resolvePaths(paths),
+		syncedAt: syncedAt,
+	})
+
+	metrics.EmailBlocklistReloadsTotal.WithLabelValues("success").Inc()
+	metrics.EmailBlocklistDomains.WithLabelValues("blocklist").Set(float64(len(blocked)))
+	metrics.EmailBlocklistDomains.WithLabelValues("allowlist").Set(float64(len(allowed)))
+
+	logger.InfoContext(ctx, "Email blocklist loaded", "blocked_domains", len(blocked), "allowed_domains", len(allowed))
+
+	return nil
+}
+
+func (s *emailBlocklistService) Sync(ctx context.Context) error {
+	if s.syncURL == "" {
+		return ErrSyncURLNotConfigured
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, s.syncURL, nil)
+	if err != nil {
+		return err
+	}
+
+	response, err := s.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("email blocklist sync failed with status %d", response.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxSyncResponseSize))
+	if err != nil {
+		return err
+	}
+
+	// Never replace a working list with garbage
+	list, err := parseDomainList(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	if len(list) == 0 {
+		return ErrEmptySyncResponse
+	}
+
+	domains := make([]string, 0, len(list))
+	for domain := range list {
+		domains = append(domains, domain)
+	}
+
+	slices.Sort(domains)
+
+	if err := s.repoFactory.NewEmailBlocklistSyncRepo(s.db).Save(ctx, strings.Join(domains, "\n")); err != nil {
+		return err
+	}
+
+	logger.MustFromContext(ctx).InfoContext(ctx, "Email blocklist synced", "domains", len(list))
+
+	return s.Reload(ctx)
+}
+
+func (s *emailBlocklistService) Watch(ctx context.Context) error {
+	logger := logger.MustFromContext(ctx)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	if err := s.watchDirectories(ctx, watcher); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	s.watcher.Store(watcher)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	ticker := time.NewTicker(syncPollInterval)
+
+	go func() {
+		defer watcher.Close()
+		defer s.watcher.Store(nil)
+		defer signal.Stop(signals)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+				logger.InfoContext(ctx, "SIGHUP received, reloading the email blocklist")
+
+				if err := s.Reload(ctx); err != nil {
+					logger.ErrorContext(ctx, "Failed to reload the email blocklist", "error", err)
+				}
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				if event.Op == fsnotify.Chmod || (!s.isListFile(event.Name) && !s.targetsChanged()) {
+					continue
+				}
+
+				logger.InfoContext(ctx, "Email blocklist file changed, reloading", "path", event.Name, "op", event.Op.String())
+
+				if err := s.Reload(ctx); err != nil {
+					logger.ErrorContext(ctx, "Failed to reload the email blocklist", "error", err)
+				}
+			case <-ticker.C:
+				syncedAt, err := s.repoFactory.NewEmailBlocklistSyncRepo(s.db).FindSyncedAt(ctx)
+				if err != nil {
+					logger.ErrorContext(ctx, "Failed to check the synced email blocklist", "error", err)
+
+					continue
+				}
+
+				if syncedAt.Equal(s.lists.Load().syncedAt) {
+					continue
+				}
+
+				logger.InfoContext(ctx, "Email blocklist synced by another process, reloading", "synced_at", syncedAt)
+
+				if err := s.Reload(ctx); err != nil {
+					logger.ErrorContext(ctx, "Failed to reload the email blocklist", "error", err)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+
+				logger.ErrorContext(ctx, "Email blocklist watcher error", "error", err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (s *emailBlocklistService) SetPaths(ctx context.Context, blocklistPath string, allowlistPath string) error {
+	previousPaths := s.paths.Swap(&listPaths{blocklist: blocklistPath, allowlist: allowlistPath})
+
+	if err := s.Reload(ctx); err != nil {
+		s.paths.Store(previousPaths)
+
+		return err
+	}
+
+	if watcher := s.watcher.Load(); watcher != nil {
+		return s.watchDirectories(ctx, watcher)
+	}
+
+	return nil
+}
+
+// The directories are watched instead of the files, so that files replaced by
+// a rename are still tracked. A ConfigMap volume updates the files by swapping
+// the "..data" symlink their symlinks point through, see targetsChanged.
+func (s *emailBlocklistService) watchDirectories(ctx context.Context, watcher *fsnotify.Watcher) error {
+	paths := s.paths.Load()
+
+	for _, path := range []string{paths.blocklist, paths.allowlist} {
+		if path == "" {
+			continue
+		}
+
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+
+		if err := watcher.Add(filepath.Dir(absPath)); err != nil {
+			logger.MustWarnContext(ctx, "Failed to watch email blocklist directory", "path", path, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// Reports whether the absolute path is one of the current list files
+func (s *emailBlocklistService) isListFile(absPath string) bool {
+	paths := s.paths.Load()
+
+	for _, path := range []string{paths.blocklist, paths.allowlist} {
+		if path == "" {
+			continue
+		}
+
+		if listAbsPath, err := filepath.Abs(path); err == nil && listAbsPath == absPath {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reports whether a list path resolves to another file than when the lists
+// were loaded, e.g. because a symlink in the path was swapped
+func (s *emailBlocklistService) targetsChanged() bool {
+	return resolvePaths(s.paths.Load()) != s.lists.Load().targets
+}
+
+// Resolves the symlinks in the paths. The paths that can't be resolved, e.g.
+// because the files don't exist, are left empty.
+func resolvePaths(paths *listPaths) listPaths {
+	resolve := func(path string) string {
+		if path == "" {
+			return ""
+		}
+
+		resolvedPath, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return ""
+		}
+
+		return resolvedPath
+	}
+
+	return listPaths{
+		blocklist: resolve(paths.blocklist),
+		allowlist: resolve(paths.allowlist),
+	}
+}
@@ -0,0 +1,222 @@
+package email_domain_validation_service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"prutya/go-api-template/internal/logger"
+	"prutya/go-api-template/internal/metrics"
+)
+
+// Resolver is the subset of `net.Resolver` used by the validator. It is an
+// interface so that tests can use a fake.
+type Resolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+type Result struct {
+	Deliverable bool
+	// A similar well-known domain, e.g. "gmail.com" for "gmial.com". Empty if
+	// there is no close match.
+	Suggestion string
+}
+
+type EmailDomainValidationService interface {
+	Validate(ctx context.Context, email string) *Result
+}
+
+// The cache is swept for expired entries once it grows past this size, and
+// then past twice the size left after the last sweep
+const minCacheSweepSize = 1024
+
+type cacheEntry struct {
+	deliverable bool
+	expiresAt   time.Time
+}
+
+type emailDomainValidationService struct {
+	resolver          Resolver
+	timeout           time.Duration
+	cacheTTL          time.Duration
+	suggestionDomains []string
+
+	cacheMutex     sync.Mutex
+	cache          map[string]*cacheEntry
+	cacheSweepSize int
+}
+
+func NewEmailDomainValidationService(
+	ctx context.Context,
+	enabled bool,
+	resolver Resolver,
+	timeout time.Duration,
+	cacheTTL time.Duration,
+	suggestionDomains []string,
+) EmailDomainValidationService {
+	if !enabled {
+		return newNoopEmailDomainValidationService(ctx)
+	}
+
+	normalizedSuggestionDomains := make([]string, len(suggestionDomains))
+	for i, domain := range suggestionDomains {
+		normalizedSuggestionDomains[i] = normalizeDomain(domain)
+	}
+
+	return &emailDomainValidationService{
+		resolver:          resolver,
+		timeout:           timeout,
+		cacheTTL:          cacheTTL,
+		suggestionDomains: normalizedSuggestionDomains,
+		cache:             map[string]*cacheEntry{},
+		cacheSweepSize:    minCacheSweepSize,
+	}
+}
+
+func (s *emailDomainValidationService) Validate(ctx context.Context, email string) *Result {
+	_, domain, found := strings.Cut(email, "@")
+	if !found {
+		return &Result{Deliverable: false}
+	}
+
+	domain = normalizeDomain(domain)
+
+	if s.isDeliverable(ctx, domain) {
+		return &Result{Deliverable: true}
+	}
+
+	return &Result{
+		Deliverable: false,
+		Suggestion:  suggestDomain(domain, s.suggestionDomains),
+	}
+}
+
+func (s *emailDomainValidationService) isDeliverable(ctx context.Context, domain string) bool {
+	logger := logger.MustFromContext(ctx)
+
+	if deliverable, found := s.getCached(domain); found {
+		countValidation(deliverable)
+
+		return deliverable
+	}
+
+	deliverable, err := s.lookup(ctx, domain)
+	if err != nil {
+		// DNS outages must not block registrations, the result is not cached so
+		// the next attempt checks again
+		logger.WarnContext(ctx, "Email domain lookup failed, assuming deliverable", "domain", domain, "error", err)
+		metrics.EmailDomainValidationsTotal.WithLabelValues("error").Inc()
+
+		return true
+	}
+
+	s.setCached(domain, deliverable)
+	countValidation(deliverable)
+
+	if !deliverable {
+		logger.InfoContext(ctx, "Email domain is not deliverable", "domain", domain)
+	}
+
+	return deliverable
+}
+
+// Returns an error only when the answer is inconclusive (timeouts, server
+// failures). A domain that doesn't exist or has no mail hosts is reported as
+// not deliverable.
+func (s *emailDomainValidationService) lookup(ctx context.Context, domain string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	mxRecords, err := s.resolver.LookupMX(ctx, domain)
+	if err != nil && !isNotFound(err) {
+		return false, err
+	}
+
+	if len(mxRecords) > 0 {
+		// A "null MX" explicitly states that the domain doesn't accept email, see
+		// RFC 7505
+		if len(mxRecords) == 1 && (mxRecords[0].Host == "." || mxRecords[0].Host == "") {
+			return false, nil
+		}
+
+		return true, nil
+	}
+
+	// Without MX records mail is delivered to the A/AAAA records of the domain,
+	// see RFC 5321 section 5.1
+	hosts, err := s.resolver.LookupHost(ctx, domain)
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return len(hosts) > 0, nil
+}
+
+func (s *emailDomainValidationService) getCached(domain string) (bool, bool) {
+	s.cacheMutex.Lock()
+	defer s.cacheMutex.Unlock()
+
+	entry, found := s.cache[domain]
+	if !found {
+		return false, false
+	}
+
+	if time.Now().After(entry.expiresAt) {
+		delete(s.cache, domain)
+
+		return false, false
+	}
+
+	return entry.deliverable, true
+}
+
+func (s *emailDomainValidationService) setCached(domain string, deliverable bool) {
+	s.cacheMutex.Lock()
+	defer s.cacheMutex.Unlock()
+
+	currentTime := time.Now()
+
+	// Drop the expired entries now and then to keep the cache bounded by the
+	// number of domains seen within the TTL. Sweeping at doubling sizes keeps
+	// the inserts cheap on average.
+	if len(s.cache) >= s.cacheSweepSize {
+		for cachedDomain, entry := range s.cache {
+			if currentTime.After(entry.expiresAt) {
+				delete(s.cache, cachedDomain)
+			}
+		}
+
+		s.cacheSweepSize = max(minCacheSweepSize, 2*len(s.cache))
+	}
+
+	s.cache[domain] = &cacheEntry{
+		deliverable: deliverable,
+		expiresAt:   currentTime.Add(s.cacheTTL),
+	}
+}
+
+func countValidation(deliverable bool) {
+	if deliverable {
+		metrics.EmailDomainValidationsTotal.WithLabelValues("deliverable").Inc()
+	} else {
+		metrics.EmailDomainValidationsTotal.WithLabelValues("undeliverable").Inc()
+	}
+}
+
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+func normalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
@@ -0,0 +1,199 @@
+package email_domain_validation_service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"prutya/go-api-template/internal/logger"
+)
+
+type fakeResolver struct {
+	mx      map[string][]*net.MX
+	hosts   map[string][]string
+	err     error
+	lookups int
+}
+
+func (r *fakeResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	r.lookups++
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if records, found := r.mx[name]; found {
+		return records, nil
+	}
+
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if addresses, found := r.hosts[host]; found {
+		return addresses, nil
+	}
+
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func newTestContext(t *testing.T) context.Context {
+	l, err := logger.New("error", "json", false, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return logger.NewContext(context.Background(), l)
+}
+
+func newTestService(ctx context.Context, resolver Resolver) EmailDomainValidationService {
+	return NewEmailDomainValidationService(
+		ctx,
+		true,
+		resolver,
+		time.Second,
+		time.Hour,
+		[]string{"gmail.com", "outlook.com"},
+	)
+}
+
+func TestValidate(t *testing.T) {
+	ctx := newTestContext(t)
+
+	resolver := &fakeResolver{
+		mx: map[string][]*net.MX{
+			"gmail.com":      {{Host: "gmail-smtp-in.l.google.com.", Pref: 5}},
+			"nullmx.example": {{Host: ".", Pref: 0}},
+		},
+		hosts: map[string][]string{
+			"a-only.example": {"192.0.2.1"},
+		},
+	}
+
+	service := newTestService(ctx, resolver)
+
+	tests := []struct {
+		email       string
+		deliverable bool
+		suggestion  string
+	}{
+		{"user@gmail.com", true, ""},
+		{"user@GMAIL.com.", true, ""},
+		{"user@a-only.example", true, ""},
+		{"user@nullmx.example", false, ""},
+		{"user@gmial.con", false, "gmail.com"},
+		{"user@nowhere.example", false, ""},
+		{"not-an-email", false, ""},
+	}
+
+	for _, test := range tests {
+		result := service.Validate(ctx, test.email)
+
+		if result.Deliverable != test.deliverable {
+			t.Errorf("%s: got deliverable %v, want %v", test.email, result.Deliverable, test.deliverable)
+		}
+
+		if result.Suggestion != test.suggestion {
+			t.Errorf("%s: got suggestion %q, want %q", test.email, result.Suggestion, test.suggestion)
+		}
+	}
+}
+
+func TestValidateCachesResults(t *testing.T) {
+	ctx := newTestContext(t)
+
+	resolver := &fakeResolver{
+		mx: map[string][]*net.MX{
+			"gmail.com": {{Host: "gmail-smtp-in.l.google.com.", Pref: 5}},
+		},
+	}
+
+	service := newTestService(ctx, resolver)
+
+	service.Validate(ctx, "a@gmail.com")
+	service.Validate(ctx, "b@gmail.com")
+
+	if resolver.lookups != 1 {
+		t.Errorf("got %d lookups, want 1", resolver.lookups)
+	}
+}
+
+func TestValidateFailsOpenOnLookupErrors(t *testing.T) {
+	ctx := newTestContext(t)
+
+	resolver := &fakeResolver{err: errors.New("i/o timeout")}
+
+	service := newTestService(ctx, resolver)
+
+	if result := service.Validate(ctx, "user@example.com"); !result.Deliverable {
+		t.Error("got not deliverable, want deliverable")
+	}
+
+	// Inconclusive results are not cached
+	service.Validate(ctx, "user@example.com")
+
+	if resolver.lookups != 2 {
+		t.Errorf("got %d lookups, want 2", resolver.lookups)
+	}
+}
+
+func TestSetCachedSweepsExpiredEntries(t *testing.T) {
+	tests := []struct {
+		name          string
+		cacheTTL      time.Duration
+		wantSize      int
+		wantSweepSize int
+	}{
+		{"expired", -time.Second, 1, minCacheSweepSize},
+		{"live", time.Hour, minCacheSweepSize + 1, 2 * minCacheSweepSize},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := &emailDomainValidationService{
+				cacheTTL:       test.cacheTTL,
+				cache:          map[string]*cacheEntry{},
+				cacheSweepSize: minCacheSweepSize,
+			}
+
+			for i := range minCacheSweepSize + 1 {
+				service.setCached(fmt.Sprintf("example%d.com", i), true)
+			}
+
+			if len(service.cache) != test.wantSize {
+				t.Errorf("got %d entries, want %d", len(service.cache), test.wantSize)
+			}
+
+			if service.cacheSweepSize != test.wantSweepSize {
+				t.Errorf("got sweep size %d, want %d", service.cacheSweepSize, test.wantSweepSize)
+			}
+		})
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"gmail.com", "gmail.com", 0},
+		{"gmial.com", "gmail.com", 1},
+		{"gmial.con", "gmail.com", 2},
+		{"gmail.con", "gmail.com", 1},
+		{"kitten", "sitting", 3},
+	}
+
+	for _, test := range tests {
+		if distance := editDistance(test.a, test.b); distance != test.distance {
+			t.Errorf("%q -> %q: got %d, want %d", test.a, test.b, distance, test.distance)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package email_domain_validation_service
+
+import (
+	"context"
+
+	"prutya/go-api-template/internal/logger"
+)
+
+type noopEmailDomainValidationService struct{}
+
+func newNoopEmailDomainValidationService(ctx context.Context) EmailDomainValidationService {
+	logger.MustWarnContext(ctx, "Email domain validation is disabled. All domains are considered deliverable.")
+
+	return &noopEmailDomainValidationService{}
+}
+
+func (s *noopEmailDomainValidationService) Validate(ctx context.Context, email string) *Result {
+	return &Result{Deliverable: true}
+}
@@ -0,0 +1,62 @@
+package email_domain_validation_service
+
+// Domains further away than this are not considered typos
+const maxSuggestionDistance = 2
+
+// Returns the closest of the well-known domains if it is within the maximum
+// distance, e.g. "gmail.com" for "gmial.con"
+func suggestDomain(domain string, suggestionDomains []string) string {
+	suggestion := ""
+	bestDistance := maxSuggestionDistance + 1
+
+	for _, suggestionDomain := range suggestionDomains {
+		if suggestionDomain == domain {
+			return ""
+		}
+
+		distance := editDistance(domain, suggestionDomain)
+		if distance < bestDistance {
+			suggestion = suggestionDomain
+			bestDistance = distance
+		}
+	}
+
+	return suggestion
+}
+
+// Optimal string alignment distance: a Levenshtein distance where swapping two
+// adjacent characters (the most common typo, e.g. "gmial") counts as one edit
+func editDistance(a string, b string) int {
+	ra := []rune(a)
+	rb := []rune(b)
+
+	// Only the last two rows are needed
+	beforePrevious := make([]int, len(rb)+1)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				current[j] = min(current[j], beforePrevious[j-2]+1)
+			}
+		}
+
+		beforePrevious, previous, current = previous, current, beforePrevious
+	}
+
+	return previous[len(rb)]
+}
@@ -0,0 +1,276 @@
+package failed_task_service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/uptrace/bun"
+
+	"prutya/go-api-template/internal/logger"
+	"prutya/go-api-template/internal/metrics"
+	"prutya/go-api-template/internal/models"
+	"prutya/go-api-template/internal/repo"
+	"prutya/go-api-template/internal/tasks"
+	"prutya/go-api-template/internal/tasks_client"
+)
+
+var (
+	ErrFailedTaskNotFound       = errors.New("failed task not found")
+	ErrFailedTaskAlreadyRetried = errors.New("failed task already retried")
+)
+
+// The error chain of deeply wrapped errors is cut at this depth
+const maxErrorChainLength = 16
+
+type FailedTaskService interface {
+	// Records a task that exhausted its retries and sends an alert if
+	// configured
+	Record(ctx context.Context, failure *Failure) error
+	List(ctx context.Context, taskType string, pageSize int, beforeCursor string) ([]*models.FailedTask, bool, error)
+	Get(ctx context.Context, failedTaskID string) (*models.FailedTask, error)
+	// Runs the archived task again, or enqueues a copy of it if it's no longer
+	// archived. A failed task is only retried once.
+	Retry(ctx context.Context, failedTaskID string) error
+	// Deletes the failed task and its archived copy
+	Purge(ctx context.Context, failedTaskID string) error
+	// Deletes the tasks that failed before the time, returns their number
+	PurgeBefore(ctx context.Context, before time.Time) (int, error)
+}
+
+type Failure struct {
+	TaskID   string
+	TaskType string
+	Queue    string
+	Payload  []byte
+	Err      error
+	Attempts int
+	MaxRetry int
+}
+
+type failedTaskService struct {
+	alertWebhookURL func() string
+	httpClient      *http.Client
+	tasksClient     tasks_client.Client
+	db              bun.IDB
+	repoFactory     repo.RepoFactory
+}
+
+func NewFailedTaskService(
+	alertWebhookURL func() string,
+	httpClient *http.Client,
+	tasksClient tasks_client.Client,
+	db bun.IDB,
+	repoFactory repo.RepoFactory,
+) FailedTaskService {
+	return &failedTaskService{
+		alertWebhookURL: alertWebhookURL,
+		httpClient:      httpClient,
+		tasksClient:     tasksClient,
+		db:              db,
+		repoFactory:     repoFactory,
+	}
+}
+
+func (s *failedTaskService) Record(ctx context.Context, failure *Failure) error {
+	metrics.TasksDeadTotal.WithLabelValues(failure.TaskType).Inc()
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return err
+	}
+
+	failedTask := &models.FailedTask{
+		ID:         id.String(),
+		TaskID:     failure.TaskID,
+		TaskType:   failure.TaskType,
+		Queue:      failure.Queue,
+		Payload:    failure.Payload,
+		Error:      failure.Err.Error(),
+		ErrorChain: errorChain(failure.Err),
+		Attempts:   failure.Attempts,
+		MaxRetry:   failure.MaxRetry,
+	}
+
+	if err := s.repoFactory.NewFailedTaskRepo(s.db).Create(ctx, failedTask); err != nil {
+		return err
+	}
+
+	if alertWebhookURL := s.alertWebhookURL(); alertWebhookURL != "" {
+		// The task is already recorded, a failed alert is only logged
+		if err := s.sendAlert(ctx, alertWebhookURL, failedTask); err != nil {
+			logger.MustWarnContext(ctx, "Failed to send failed task alert", "error", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *failedTaskService) List(
+	ctx context.Context,
+	taskType string,
+	pageSize int,
+	beforeCursor string,
+) ([]*models.FailedTask, bool, error) {
+	// Get one more item than the page size to determine if there are more items
+	failedTasks, err := s.repoFactory.NewFailedTaskRepo(s.db).ListWithPagination(ctx, taskType, pageSize+1, beforeCursor)
+	if err != nil {
+		return nil, false, err
+	}
+
+	hasMore := false
+
+	if len(failedTasks) > pageSize {
+		hasMore = true
+		failedTasks = failedTasks[:pageSize]
+	}
+
+	return failedTasks, hasMore, nil
+}
+
+func (s *failedTaskService) Get(ctx context.Context, failedTaskID string) (*models.FailedTask, error) {
+	failedTask, err := s.repoFactory.NewFailedTaskRepo(s.db).TryFindByID(ctx, failedTaskID)
+	if err != nil {
+		return nil, err
+	}
+
+	if failedTask == nil {
+		return nil, ErrFailedTaskNotFound
+	}
+
+	return failedTask, nil
+}
+
+func (s *failedTaskService) Retry(ctx context.Context, failedTaskID string) error {
+	failedTask, err := s.Get(ctx, failedTaskID)
+	if err != nil {
+		return err
+	}
+
+	// The archived task is gone after a retry, enqueueing a copy would run the
+	// task twice
+	if failedTask.RetriedAt.Valid {
+		return ErrFailedTaskAlreadyRetried
+	}
+
+	err = s.tasksClient.RunArchived(failedTask.Queue, failedTask.TaskID)
+
+	// The archived task is gone, e.g. deleted in asynqmon or trimmed by asynq
+	if errors.Is(err, tasks.ErrTaskNotFound) {
+		_, err = s.tasksClient.Enqueue(ctx, tasks.NewTaskFromPayload(failedTask.TaskType, failedTask.Payload))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return s.repoFactory.NewFailedTaskRepo(s.db).MarkRetried(ctx, failedTaskID)
+}
+
+func (s *failedTaskService) Purge(ctx context.Context, failedTaskID string) error {
+	failedTask, err := s.Get(ctx, failedTaskID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.deleteArchived(failedTask); err != nil {
+		return err
+	}
+
+	if _, err := s.repoFactory.NewFailedTaskRepo(s.db).Delete(ctx, failedTaskID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *failedTaskService) PurgeBefore(ctx context.Context, before time.Time) (int, error) {
+	failedTasks, err := s.repoFactory.NewFailedTaskRepo(s.db).DeleteBefore(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, failedTask := range failedTasks {
+		if err := s.deleteArchived(failedTask); err != nil {
+			logger.MustWarnContext(ctx, "Failed to delete archived task", "task_id", failedTask.TaskID, "error", err)
+		}
+	}
+
+	return len(failedTasks), nil
+}
+
+// A retried task is not archived anymore, neither is one deleted elsewhere
+func (s *failedTaskService) deleteArchived(failedTask *models.FailedTask) error {
+	err := s.tasksClient.DeleteArchived(failedTask.Queue, failedTask.TaskID)
+	if err != nil && !errors.Is(err, tasks.ErrTaskNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+// Posts a Slack-compatible message to the webhook
+func (s *failedTaskService) sendAlert(ctx context.Context, alertWebhookURL string, failedTask *models.FailedTask) error {
+	body, err := json.Marshal(map[string]string{
+		"text": fmt.Sprintf(
+			"Task %s (%s) failed after %d attempts: %s",
+			failedTask.TaskType,
+			failedTask.TaskID,
+			failedTask.Attempts,
+			failedTask.Error,
+		),
+	})
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, alertWebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := s.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("alert webhook responded with status %d", response.StatusCode)
+	}
+
+	return nil
+}
+
+// Returns the messages of the error and the errors it wraps, outermost first
+func errorChain(err error) []string {
+	chain := []string{}
+	queue := []error{err}
+
+	for len(queue) > 0 && len(chain) < maxErrorChainLength {
+		current := queue[0]
+		queue = queue[1:]
+
+		if current == nil {
+			continue
+		}
+
+		chain = append(chain, current.Error())
+
+		switch wrapper := current.(type) {
+		case interface{ Unwrap() error }:
+			queue = append(queue, wrapper.Unwrap())
+		case interface{ Unwrap() []error }:
+			queue = append(queue, wrapper.Unwrap()...)
+		}
+	}
+
+	return chain
+}
@@ -0,0 +1,138 @@
+package failed_task_service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"prutya/go-api-template/internal/models"
+	"prutya/go-api-template/internal/repo"
+	"prutya/go-api-template/internal/tasks"
+	"prutya/go-api-template/internal/tasks_client"
+)
+
+type fakeRepoFactory struct {
+	repo.RepoFactory
+	failedTaskRepo *fakeFailedTaskRepo
+}
+
+func (f *fakeRepoFactory) NewFailedTaskRepo(db bun.IDB) repo.FailedTaskRepo {
+	return f.failedTaskRepo
+}
+
+type fakeFailedTaskRepo struct {
+	repo.FailedTaskRepo
+	failedTasks map[string]*models.FailedTask
+}
+
+func (r *fakeFailedTaskRepo) TryFindByID(ctx context.Context, failedTaskID string) (*models.FailedTask, error) {
+	return r.failedTasks[failedTaskID], nil
+}
+
+func (r *fakeFailedTaskRepo) MarkRetried(ctx context.Context, failedTaskID string) error {
+	r.failedTasks[failedTaskID].RetriedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+	return nil
+}
+
+type fakeTasksClient struct {
+	tasks_client.Client
+	archived    map[string]bool
+	runArchived int
+	enqueued    int
+}
+
+// A run archived task is moved back to the queue, it's not archived anymore
+func (c *fakeTasksClient) RunArchived(queue string, taskID string) error {
+	if !c.archived[taskID] {
+		return tasks.ErrTaskNotFound
+	}
+
+	c.runArchived++
+	delete(c.archived, taskID)
+
+	return nil
+}
+
+func (c *fakeTasksClient) Enqueue(ctx context.Context, task *tasks.Task) (*tasks.TaskInfo, error) {
+	c.enqueued++
+
+	return &tasks.TaskInfo{}, nil
+}
+
+func newTestService() (*failedTaskService, *fakeTasksClient) {
+	tasksClient := &fakeTasksClient{archived: map[string]bool{"task-1": true}}
+	repoFactory := &fakeRepoFactory{
+		failedTaskRepo: &fakeFailedTaskRepo{
+			failedTasks: map[string]*models.FailedTask{
+				"failed-1": {ID: "failed-1", TaskID: "task-1", TaskType: "test", Queue: "default"},
+			},
+		},
+	}
+
+	service := NewFailedTaskService(
+		func() string { return "" },
+		http.DefaultClient,
+		tasksClient,
+		nil,
+		repoFactory,
+	).(*failedTaskService)
+
+	return service, tasksClient
+}
+
+func TestRetry(t *testing.T) {
+	service, tasksClient := newTestService()
+
+	if err := service.Retry(context.Background(), "failed-1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if tasksClient.runArchived != 1 || tasksClient.enqueued != 0 {
+		t.Errorf("expected the archived task to run once, got %d runs and %d enqueues", tasksClient.runArchived, tasksClient.enqueued)
+	}
+}
+
+func TestRetryMissingArchivedTask(t *testing.T) {
+	service, tasksClient := newTestService()
+	delete(tasksClient.archived, "task-1")
+
+	if err := service.Retry(context.Background(), "failed-1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if tasksClient.enqueued != 1 {
+		t.Errorf("expected a copy of the task to be enqueued, got %d enqueues", tasksClient.enqueued)
+	}
+}
+
+func TestRetryTwice(t *testing.T) {
+	service, tasksClient := newTestService()
+
+	if err := service.Retry(context.Background(), "failed-1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := service.Retry(context.Background(), "failed-1")
+	if !errors.Is(err, ErrFailedTaskAlreadyRetried) {
+		t.Fatalf("expected ErrFailedTaskAlreadyRetried, got %v", err)
+	}
+
+	if tasksClient.runArchived != 1 || tasksClient.enqueued != 0 {
+		t.Errorf("expected the task to run once, got %d runs and %d enqueues", tasksClient.runArchived, tasksClient.enqueued)
+	}
+}
+
+func TestRetryNotFound(t *testing.T) {
+	service, _ := newTestService()
+
+	err := service.Retry(context.Background(), "missing")
+	if !errors.Is(err, ErrFailedTaskNotFound) {
+		t.Fatalf("expected ErrFailedTaskNotFound, got %v", err)
+	}
+}
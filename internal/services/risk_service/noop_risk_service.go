@@ -0,0 +1,23 @@
+package risk_service
+
+import (
+	"context"
+
+	"prutya/go-api-template/internal/logger"
+)
+
+type noopRiskService struct{}
+
+func newNoopRiskService(ctx context.Context) RiskService {
+	logger.MustWarnContext(ctx, "Risk scoring is disabled. A captcha will be required on every protected request.")
+
+	return &noopRiskService{}
+}
+
+func (s *noopRiskService) Assess(ctx context.Context, signals *Signals) *Assessment {
+	return &Assessment{RequiresCaptcha: true}
+}
+
+func (s *noopRiskService) RecordFailure(ctx context.Context, signals *Signals) {}
+
+func (s *noopRiskService) RecordSuccess(ctx context.Context, signals *Signals) {}
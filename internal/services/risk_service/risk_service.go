@@ -0,0 +1,307 @@
+package risk_service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"prutya/go-api-template/internal/logger"
+)
+
+const failuresRedisKeyPrefix = "risk:failures:"
+const devicesRedisKeyPrefix = "risk:devices:"
+
+// The reasons that contributed to the score
+const ReasonIPFailures = "ip_failures"
+const ReasonAccountFailures = "account_failures"
+const ReasonNewDevice = "new_device"
+const ReasonBlockedNetwork = "blocked_network"
+const ReasonUnavailable = "unavailable"
+
+type RiskService interface {
+	// Scores the request. Errors are logged and treated as elevated risk.
+	Assess(ctx context.Context, signals *Signals) *Assessment
+	// Counts a failed attempt (e.g. wrong password) against the IP address and
+	// the account
+	RecordFailure(ctx context.Context, signals *Signals)
+	// Remembers the device for the account and forgets its failed attempts
+	RecordSuccess(ctx context.Context, signals *Signals)
+}
+
+type Signals struct {
+	IP string
+	// Autonomous system number of the IP address as reported by a trusted
+	// proxy. Empty if unknown.
+	ASN string
+	// Email address the request is made for. Empty if unknown.
+	Account string
+	// See `DeviceFingerprint`
+	Device string
+}
+
+type Assessment struct {
+	Score           int
+	RequiresCaptcha bool
+	Reasons         []string
+}
+
+type Weights struct {
+	// Added for every failed attempt from the IP address within the window
+	IPFailure int
+	// Added for every failed attempt on the account within the window
+	AccountFailure int
+	// Added when the device has never logged in to the account. Accounts that
+	// don't exist have no known devices, so that the score doesn't reveal
+	// whether the account exists.
+	NewDevice int
+	// Added when the IP address or its ASN is blocked
+	BlockedNetwork int
+}
+
+type riskService struct {
+	threshold       int
+	failureWindow   time.Duration
+	knownDeviceTTL  time.Duration
+	weights         *Weights
+	blockedNetworks []netip.Prefix
+	blockedASNs     []string
+	redisClient     *redis.Client
+}
+
+func NewRiskService(
+	ctx context.Context,
+	enabled bool,
+	threshold int,
+	failureWindow time.Duration,
+	knownDeviceTTL time.Duration,
+	weights *Weights,
+	blockedNetworks []string,
+	blockedASNs []string,
+	redisClient *redis.Client,
+) (RiskService, error) {
+	if !enabled {
+		return newNoopRiskService(ctx), nil
+	}
+
+	parsedBlockedNetworks := make([]netip.Prefix, len(blockedNetworks))
+	for i, network := range blockedNetworks {
+		prefix, err := parseNetwork(network)
+		if err != nil {
+			return nil, err
+		}
+
+		parsedBlockedNetworks[i] = prefix
+	}
+
+	normalizedBlockedASNs := make([]string, len(blockedASNs))
+	for i, asn := range blockedASNs {
+		normalizedBlockedASNs[i] = normalizeASN(asn)
+	}
+
+	return &riskService{
+		threshold:       threshold,
+		failureWindow:   failureWindow,
+		knownDeviceTTL:  knownDeviceTTL,
+		weights:         weights,
+		blockedNetworks: parsedBlockedNetworks,
+		blockedASNs:     normalizedBlockedASNs,
+		redisClient:     redisClient,
+	}, nil
+}
+
+func (s *riskService) Assess(ctx context.Context, signals *Signals) *Assessment {
+	assessment := &Assessment{}
+
+	add := func(points int, reason string) {
+		if points <= 0 {
+			return
+		}
+
+		assessment.Score += points
+		assessment.Reasons = append(assessment.Reasons, reason)
+	}
+
+	if s.isBlockedNetwork(signals) {
+		add(s.weights.BlockedNetwork, ReasonBlockedNetwork)
+	}
+
+	pipe := s.redisClient.Pipeline()
+	ipFailuresCmd := pipe.Get(ctx, ipFailuresKey(signals.IP))
+
+	var accountFailuresCmd *redis.StringCmd
+	var isKnownDeviceCmd *redis.BoolCmd
+
+	if signals.Account != "" {
+		accountFailuresCmd = pipe.Get(ctx, accountFailuresKey(signals.Account))
+		isKnownDeviceCmd = pipe.SIsMember(ctx, devicesKey(signals.Account), signals.Device)
+	}
+
+	if err := execIgnoringNil(ctx, pipe); err != nil {
+		logger.MustWarnContext(ctx, "Failed to assess the risk", "error", err)
+
+		// Fail closed, a captcha is cheaper than an account takeover
+		assessment.Score = max(assessment.Score, s.threshold+1)
+		assessment.Reasons = append(assessment.Reasons, ReasonUnavailable)
+		assessment.RequiresCaptcha = true
+
+		return assessment
+	}
+
+	add(s.weights.IPFailure*counterValue(ipFailuresCmd), ReasonIPFailures)
+
+	if signals.Account != "" {
+		add(s.weights.AccountFailure*counterValue(accountFailuresCmd), ReasonAccountFailures)
+
+		if !isKnownDeviceCmd.Val() {
+			add(s.weights.NewDevice, ReasonNewDevice)
+		}
+	}
+
+	assessment.RequiresCaptcha = assessment.Score > s.threshold
+
+	return assessment
+}
+
+func (s *riskService) RecordFailure(ctx context.Context, signals *Signals) {
+	pipe := s.redisClient.TxPipeline()
+
+	incrementFailures(ctx, pipe, ipFailuresKey(signals.IP), s.failureWindow)
+
+	if signals.Account != "" {
+		incrementFailures(ctx, pipe, accountFailuresKey(signals.Account), s.failureWindow)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		logger.MustWarnContext(ctx, "Failed to record a failed attempt", "error", err)
+	}
+}
+
+func (s *riskService) RecordSuccess(ctx context.Context, signals *Signals) {
+	if signals.Account == "" {
+		return
+	}
+
+	pipe := s.redisClient.TxPipeline()
+
+	pipe.Del(ctx, accountFailuresKey(signals.Account))
+
+	if signals.Device != "" {
+		pipe.SAdd(ctx, devicesKey(signals.Account), signals.Device)
+		pipe.Expire(ctx, devicesKey(signals.Account), s.knownDeviceTTL)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		logger.MustWarnContext(ctx, "Failed to record a successful attempt", "error", err)
+	}
+}
+
+// Identifies a device well enough to tell a returning user from a new one. It
+// is not meant to be unique across users.
+func DeviceFingerprint(userAgent string, acceptLanguage string) string {
+	sum := sha256.Sum256([]byte(userAgent + "\n" + acceptLanguage))
+
+	return hex.EncodeToString(sum[:16])
+}
+
+func (s *riskService) isBlockedNetwork(signals *Signals) bool {
+	if signals.ASN != "" && slices.Contains(s.blockedASNs, normalizeASN(signals.ASN)) {
+		return true
+	}
+
+	addr, err := netip.ParseAddr(signals.IP)
+	if err != nil {
+		return false
+	}
+
+	addr = addr.Unmap()
+
+	for _, network := range s.blockedNetworks {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Missing counters are not an error, they are zero
+func execIgnoringNil(ctx context.Context, pipe redis.Pipeliner) error {
+	cmds, _ := pipe.Exec(ctx)
+
+	for _, cmd := range cmds {
+		if err := cmd.Err(); err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Starts the window on the first failure, so that the counter expires in
+// `window` after it
+func incrementFailures(ctx context.Context, pipe redis.Pipeliner, key string, window time.Duration) {
+	pipe.Incr(ctx, key)
+	pipe.ExpireNX(ctx, key, window)
+}
+
+func counterValue(cmd *redis.StringCmd) int {
+	value, err := cmd.Int()
+	if err != nil {
+		return 0
+	}
+
+	return value
+}
+
+func ipFailuresKey(ip string) string {
+	return failuresRedisKeyPrefix + "ip:" + ip
+}
+
+func accountFailuresKey(account string) string {
+	return failuresRedisKeyPrefix + "account:" + hashAccount(account)
+}
+
+func devicesKey(account string) string {
+	return devicesRedisKeyPrefix + hashAccount(account)
+}
+
+// Keeps email addresses out of Redis
+func hashAccount(account string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(account))))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Accepts both CIDR ranges and single IP addresses
+func parseNetwork(network string) (netip.Prefix, error) {
+	if strings.Contains(network, "/") {
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid blocked network %q: %w", network, err)
+		}
+
+		return prefix.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(network)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid blocked network %q: %w", network, err)
+	}
+
+	addr = addr.Unmap()
+
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// "AS13335" and "13335" are the same ASN
+func normalizeASN(asn string) string {
+	return strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(asn)), "AS")
+}
@@ -2,59 +2,210 @@ package transactional_email_service
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
 	"errors"
+	"strconv"
 	"time"
 
+	"github.com/gofrs/uuid/v5"
+	"github.com/uptrace/bun"
+
 	"prutya/go-api-template/internal/logger"
+	"prutya/go-api-template/internal/models"
 	"prutya/go-api-template/internal/repo"
 )
 
-var ErrGlobalLimitReached = errors.New("global limit reached")
+var ErrRecipientSuppressed = errors.New("recipient suppressed")
+var ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+var ErrEmailMessageNotFound = errors.New("email message not found")
+var ErrUnknownDeliveryEventType = errors.New("unknown delivery event type")
+
+const DeliveryEventTypeDelivered = "delivered"
+const DeliveryEventTypeBounced = "bounced"
+const DeliveryEventTypeComplained = "complained"
 
-func checkGlobalLimit(
+const BounceTypeHard = "hard"
+const BounceTypeSoft = "soft"
+
+// DeliveryEvent is a provider-agnostic delivery status update received via the
+// webhook
+type DeliveryEvent struct {
+	ProviderMessageID string
+	Type              string
+	BounceType        string
+	Details           string
+}
+
+func checkSuppression(
 	ctx context.Context,
-	dailyGlobalLimit int,
-	emailSendAttemptRepo repo.EmailSendAttemptRepo,
-	currentTime time.Time,
+	emailSuppressionRepo repo.EmailSuppressionRepo,
+	email string,
+	userID string,
 ) error {
-	logger := logger.MustFromContext(ctx)
+	suppressed, err := emailSuppressionRepo.Exists(ctx, email)
+	if err != nil {
+		return err
+	}
 
-	if dailyGlobalLimit <= 0 {
-		logger.WarnContext(ctx, "Daily global email limit is <= 0, no emails will be sent")
+	if suppressed {
+		logger.MustWarnContext(ctx, "Recipient is suppressed, the email will not be sent", "user_id", userID)
 
-		return ErrGlobalLimitReached
+		return ErrRecipientSuppressed
 	}
 
-	rangeStart := currentTime.Truncate(24 * time.Hour)
-	rangeEnd := rangeStart.Add(24 * time.Hour)
+	return nil
+}
 
-	currentCount, err := emailSendAttemptRepo.CountInRange(ctx, rangeStart, rangeEnd)
+// Records a new outgoing message in the "queued" state and returns its ID
+func createEmailMessage(
+	ctx context.Context,
+	emailMessageRepo repo.EmailMessageRepo,
+	email string,
+	userID string,
+	template string,
+) (string, error) {
+	emailMessageID, err := uuid.NewV7()
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	if currentCount >= dailyGlobalLimit {
-		logger.WarnContext(
-			ctx,
-			"Daily global email limit reached, no emails will be sent",
-			"current_count", currentCount,
-			"daily_global_limit", dailyGlobalLimit,
-		)
+	if err := emailMessageRepo.Create(ctx, emailMessageID.String(), userID, email, template); err != nil {
+		return "", err
+	}
+
+	return emailMessageID.String(), nil
+}
+
+// The signature is a hex-encoded HMAC-SHA256 of "<timestamp>.<body>". The
+// timestamp is a unix time in seconds and must be within the tolerance to
+// prevent replays of old events.
+func verifyWebhookSignature(
+	secret string,
+	tolerance time.Duration,
+	timestamp string,
+	signature string,
+	body []byte,
+) error {
+	if secret == "" || timestamp == "" || signature == "" {
+		return ErrInvalidWebhookSignature
+	}
+
+	unixTimestamp, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidWebhookSignature
+	}
 
-		return ErrGlobalLimitReached
+	age := time.Since(time.Unix(unixTimestamp, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrInvalidWebhookSignature
+	}
+
+	expectedSignature, err := hex.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidWebhookSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	if !hmac.Equal(mac.Sum(nil), expectedSignature) {
+		return ErrInvalidWebhookSignature
 	}
 
 	return nil
 }
 
-func resetDailyGlobalLimit(ctx context.Context, emailSendAttemptRepo repo.EmailSendAttemptRepo) error {
-	startOfDay := time.Now().UTC().Truncate(24 * time.Hour)
+func handleDeliveryEvent(
+	ctx context.Context,
+	db bun.IDB,
+	repoFactory repo.RepoFactory,
+	event *DeliveryEvent,
+) error {
+	logger := logger.MustFromContext(ctx)
 
-	if err := emailSendAttemptRepo.DeleteBefore(ctx, startOfDay); err != nil {
-		return err
+	var status string
+	var suppressionReason string
+
+	switch event.Type {
+	case DeliveryEventTypeDelivered:
+		status = models.EmailMessageStatusDelivered
+	case DeliveryEventTypeBounced:
+		status = models.EmailMessageStatusBounced
+
+		// Soft bounces (full mailbox, greylisting, etc.) are temporary
+		if event.BounceType == BounceTypeHard {
+			suppressionReason = models.EmailSuppressionReasonHardBounce
+		}
+	case DeliveryEventTypeComplained:
+		status = models.EmailMessageStatusComplained
+		suppressionReason = models.EmailSuppressionReasonComplaint
+	default:
+		return ErrUnknownDeliveryEventType
 	}
 
-	logger.MustFromContext(ctx).InfoContext(ctx, "Daily global email limit reset")
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		emailMessageRepo := repoFactory.NewEmailMessageRepo(tx)
+		emailSuppressionRepo := repoFactory.NewEmailSuppressionRepo(tx)
 
-	return nil
+		emailMessage, err := emailMessageRepo.FindByProviderMessageID(ctx, event.ProviderMessageID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrEmailMessageNotFound
+			}
+
+			return err
+		}
+
+		updated, err := emailMessageRepo.UpdateStatus(ctx, emailMessage.ID, status, event.Details)
+		if err != nil {
+			return err
+		}
+
+		if updated {
+			logger.InfoContext(
+				ctx,
+				"Email delivery status updated",
+				"email_message_id", emailMessage.ID,
+				"status", status,
+				"bounce_type", event.BounceType,
+			)
+		} else {
+			// The recipient is still suppressed below, e.g. on a hard bounce
+			// after a complaint
+			logger.InfoContext(
+				ctx,
+				"Email delivery status kept, it takes precedence over the event",
+				"email_message_id", emailMessage.ID,
+				"status", emailMessage.Status,
+				"event_status", status,
+			)
+		}
+
+		if suppressionReason == "" {
+			return nil
+		}
+
+		if err := emailSuppressionRepo.Create(
+			ctx,
+			emailMessage.Recipient,
+			suppressionReason,
+			emailMessage.ID,
+		); err != nil {
+			return err
+		}
+
+		logger.WarnContext(
+			ctx,
+			"Recipient added to the suppression list",
+			"email_message_id", emailMessage.ID,
+			"reason", suppressionReason,
+		)
+
+		return nil
+	})
 }
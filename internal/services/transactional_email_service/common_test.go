@@ -0,0 +1,93 @@
+package transactional_email_service
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/gofrs/uuid/v5"
+
+	"prutya/go-api-template/internal/db"
+	"prutya/go-api-template/internal/logger"
+	"prutya/go-api-template/internal/models"
+	"prutya/go-api-template/internal/repo"
+)
+
+// Needs a migrated database, see the "test" service in docker-compose.yml
+func TestHandleDeliveryEventOutOfOrder(t *testing.T) {
+	databaseURL := os.Getenv("APP_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("APP_DATABASE_URL is not set")
+	}
+
+	testLogger, err := logger.New("info", "text", false, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := logger.NewContext(context.Background(), testLogger)
+
+	testDB, err := db.New(func() string { return databaseURL }, 2, 2, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { testDB.Close() })
+
+	repoFactory := repo.NewRepoFactory()
+	emailMessageRepo := repoFactory.NewEmailMessageRepo(testDB)
+
+	emailMessageID := uuid.Must(uuid.NewV7()).String()
+	providerMessageID := uuid.Must(uuid.NewV4()).String()
+	recipient := providerMessageID + "@example.com"
+
+	if err := emailMessageRepo.Create(ctx, emailMessageID, "", recipient, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		testDB.NewDelete().Model((*models.EmailSuppression)(nil)).Where("email = ?", recipient).Exec(ctx)
+		testDB.NewDelete().Model((*models.EmailMessage)(nil)).Where("id = ?", emailMessageID).Exec(ctx)
+	})
+
+	if err := emailMessageRepo.MarkSent(ctx, emailMessageID, providerMessageID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		event      *DeliveryEvent
+		wantStatus string
+	}{
+		{&DeliveryEvent{Type: DeliveryEventTypeBounced, BounceType: BounceTypeHard}, models.EmailMessageStatusBounced},
+		// Arrives after the bounce, e.g. redelivered by the provider
+		{&DeliveryEvent{Type: DeliveryEventTypeDelivered}, models.EmailMessageStatusBounced},
+		{&DeliveryEvent{Type: DeliveryEventTypeComplained}, models.EmailMessageStatusComplained},
+		{&DeliveryEvent{Type: DeliveryEventTypeBounced, BounceType: BounceTypeHard}, models.EmailMessageStatusComplained},
+		{&DeliveryEvent{Type: DeliveryEventTypeDelivered}, models.EmailMessageStatusComplained},
+	}
+
+	for _, test := range tests {
+		test.event.ProviderMessageID = providerMessageID
+
+		if err := handleDeliveryEvent(ctx, testDB, repoFactory, test.event); err != nil {
+			t.Fatalf("%s: %v", test.event.Type, err)
+		}
+
+		emailMessage, err := emailMessageRepo.FindByProviderMessageID(ctx, providerMessageID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if emailMessage.Status != test.wantStatus {
+			t.Errorf("after %s: got status %q, want %q", test.event.Type, emailMessage.Status, test.wantStatus)
+		}
+	}
+
+	suppressed, err := repoFactory.NewEmailSuppressionRepo(testDB).Exists(ctx, recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !suppressed {
+		t.Error("expected the recipient to be suppressed")
+	}
+}
@@ -2,39 +2,53 @@ package transactional_email_service
 
 import (
 	"context"
+	"sync/atomic"
 	"time"
 
 	"github.com/uptrace/bun"
 
+	"prutya/go-api-template/internal/config"
 	"prutya/go-api-template/internal/logger"
 	"prutya/go-api-template/internal/repo"
 )
 
 type noopTransactionalEmailService struct {
-	dailyGlobalLimit int
+	limiter          atomic.Pointer[limiter]
+	webhookSecret    func() string
+	webhookTolerance time.Duration
 	db               bun.IDB
 	repoFactory      repo.RepoFactory
 }
 
 func newNoopTransactionalEmailService(
 	ctx context.Context,
-	dailyGlobalLimit int,
+	limiter *limiter,
+	webhookSecret func() string,
+	webhookTolerance time.Duration,
 	db bun.IDB,
 	repoFactory repo.RepoFactory,
 ) (TransactionalEmailService, error) {
 	logger.MustWarnContext(ctx, "Transactional emails delivery is disabled. Email text versions will be printed to stdout.")
 
-	return &noopTransactionalEmailService{
-		dailyGlobalLimit: dailyGlobalLimit,
+	s := &noopTransactionalEmailService{
+		webhookSecret:    webhookSecret,
+		webhookTolerance: webhookTolerance,
 		db:               db,
 		repoFactory:      repoFactory,
-	}, nil
+	}
+
+	s.limiter.Store(limiter)
+
+	return s, nil
 }
 
 func (s *noopTransactionalEmailService) SendEmail(
 	ctx context.Context,
 	email string,
 	userID string,
+	requesterIP string,
+	budget string,
+	template string,
 	subject string,
 	textBody string,
 	_ string,
@@ -42,8 +56,27 @@ func (s *noopTransactionalEmailService) SendEmail(
 	logger := logger.MustFromContext(ctx)
 
 	emailSendAttemptRepo := s.repoFactory.NewEmailSendAttemptRepo(s.db)
+	emailSuppressionRepo := s.repoFactory.NewEmailSuppressionRepo(s.db)
+	emailMessageRepo := s.repoFactory.NewEmailMessageRepo(s.db)
+
+	if err := checkSuppression(ctx, emailSuppressionRepo, email, userID); err != nil {
+		return err
+	}
+
+	emailSendAttempt := newEmailSendAttempt(email, userID, requesterIP, budget)
+
+	// A reservation is released by the limiter that made it, even if the limits
+	// are replaced in between
+	limiter := s.limiter.Load()
+
+	if err := limiter.reserve(ctx, s.db, s.repoFactory, emailSendAttempt); err != nil {
+		return err
+	}
+
+	emailMessageID, err := createEmailMessage(ctx, emailMessageRepo, email, userID, template)
+	if err != nil {
+		limiter.release(ctx, emailSendAttemptRepo, emailSendAttempt)
 
-	if err := checkGlobalLimit(ctx, s.dailyGlobalLimit, emailSendAttemptRepo, time.Now().UTC()); err != nil {
 		return err
 	}
 
@@ -52,18 +85,48 @@ func (s *noopTransactionalEmailService) SendEmail(
 		"Fake transactional email",
 		"subject", subject,
 		"user_id", userID,
+		"email_message_id", emailMessageID,
 	)
 	logger.DebugContext(ctx, "Fake transactional email body", "text_body", textBody)
 
-	if err := emailSendAttemptRepo.Create(ctx); err != nil {
+	// There is no provider message ID, so delivery events can't be matched
+	if err := emailMessageRepo.MarkSent(ctx, emailMessageID, ""); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (s *noopTransactionalEmailService) ResetDailyGlobalLimit(ctx context.Context) error {
+func (s *noopTransactionalEmailService) CleanupEmailSendAttempts(ctx context.Context) error {
+	emailSendAttemptRepo := s.repoFactory.NewEmailSendAttemptRepo(s.db)
+
+	return s.limiter.Load().cleanup(ctx, emailSendAttemptRepo)
+}
+
+func (s *noopTransactionalEmailService) GetRateLimitsUsage(ctx context.Context) ([]*RateLimitUsage, error) {
 	emailSendAttemptRepo := s.repoFactory.NewEmailSendAttemptRepo(s.db)
 
-	return resetDailyGlobalLimit(ctx, emailSendAttemptRepo)
+	return s.limiter.Load().usage(ctx, emailSendAttemptRepo)
+}
+
+func (s *noopTransactionalEmailService) VerifyWebhookSignature(timestamp string, signature string, body []byte) error {
+	return verifyWebhookSignature(s.webhookSecret(), s.webhookTolerance, timestamp, signature, body)
+}
+
+func (s *noopTransactionalEmailService) HandleDeliveryEvent(ctx context.Context, event *DeliveryEvent) error {
+	return handleDeliveryEvent(ctx, s.db, s.repoFactory, event)
+}
+
+func (s *noopTransactionalEmailService) SetLimits(
+	globalLimits []config.TransactionalEmailsGlobalLimit,
+	rateLimits []config.TransactionalEmailsRateLimit,
+) error {
+	limiter, err := newLimiter(globalLimits, rateLimits)
+	if err != nil {
+		return err
+	}
+
+	s.limiter.Store(limiter)
+
+	return nil
 }
@@ -0,0 +1,390 @@
+package transactional_email_service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"prutya/go-api-template/internal/config"
+	"prutya/go-api-template/internal/logger"
+	"prutya/go-api-template/internal/models"
+	"prutya/go-api-template/internal/repo"
+)
+
+const BudgetCritical = "critical"
+const BudgetNonCritical = "non_critical"
+
+const RateLimitScopeGlobal = "global"
+const RateLimitScopeRecipient = "recipient"
+const RateLimitScopeRecipientDomain = "recipient_domain"
+const RateLimitScopeRequesterIP = "requester_ip"
+const RateLimitScopeUser = "user"
+
+// How many of the heaviest consumers are reported per rate limit
+const rateLimitUsageTopSize = 20
+
+var ErrGlobalLimitReached = errors.New("global limit reached")
+var ErrRateLimitReached = errors.New("rate limit reached")
+var ErrInvalidRateLimit = errors.New("invalid rate limit")
+
+// Maps every scope to the email_send_attempts column it is counted by
+var rateLimitScopeColumns = map[string]string{
+	RateLimitScopeGlobal:          "",
+	RateLimitScopeRecipient:       "recipient",
+	RateLimitScopeRecipientDomain: "recipient_domain",
+	RateLimitScopeRequesterIP:     "requester_ip",
+	RateLimitScopeUser:            "user_id",
+}
+
+// The budget reported for the global limits, they apply to all budgets
+const rateLimitUsageBudgetAll = "all"
+
+type RateLimitUsage struct {
+	Scope  string
+	Budget string
+	Window time.Duration
+	Limit  int
+	Top    []*RateLimitUsageEntry
+}
+
+type RateLimitUsageEntry struct {
+	Key   string
+	Count int
+}
+
+// Enforces the global and the scoped sliding window limits. An attempt is
+// reserved before the email is sent and released if sending fails, so that
+// concurrent senders can't exceed the limits between the check and the send.
+type limiter struct {
+	globalLimits []config.TransactionalEmailsGlobalLimit
+	rateLimits   []config.TransactionalEmailsRateLimit
+	// Attempts older than the largest window don't affect any limit
+	retention time.Duration
+}
+
+func newLimiter(
+	globalLimits []config.TransactionalEmailsGlobalLimit,
+	rateLimits []config.TransactionalEmailsRateLimit,
+) (*limiter, error) {
+	var retention time.Duration
+
+	for _, globalLimit := range globalLimits {
+		if globalLimit.Window <= 0 {
+			return nil, fmt.Errorf("%w: window must be positive", ErrInvalidRateLimit)
+		}
+
+		retention = max(retention, globalLimit.Window)
+	}
+
+	for _, rateLimit := range rateLimits {
+		if _, ok := rateLimitScopeColumns[rateLimit.Scope]; !ok {
+			return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidRateLimit, rateLimit.Scope)
+		}
+
+		if rateLimit.Budget != BudgetCritical && rateLimit.Budget != BudgetNonCritical {
+			return nil, fmt.Errorf("%w: unknown budget %q", ErrInvalidRateLimit, rateLimit.Budget)
+		}
+
+		if rateLimit.Window <= 0 {
+			return nil, fmt.Errorf("%w: window must be positive", ErrInvalidRateLimit)
+		}
+
+		retention = max(retention, rateLimit.Window)
+	}
+
+	return &limiter{
+		globalLimits: globalLimits,
+		rateLimits:   rateLimits,
+		retention:    retention,
+	}, nil
+}
+
+func newEmailSendAttempt(email string, userID string, requesterIP string, budget string) *models.EmailSendAttempt {
+	email = strings.ToLower(email)
+
+	attempt := &models.EmailSendAttempt{
+		Budget:    budget,
+		Recipient: sql.NullString{String: email, Valid: true},
+	}
+
+	if _, domain, found := strings.Cut(email, "@"); found {
+		attempt.RecipientDomain = sql.NullString{String: domain, Valid: true}
+	}
+
+	if requesterIP != "" {
+		attempt.RequesterIP = sql.NullString{String: requesterIP, Valid: true}
+	}
+
+	if userID != "" {
+		attempt.UserID = sql.NullString{String: userID, Valid: true}
+	}
+
+	return attempt
+}
+
+// Returns the value of the attempt the scope is counted by. Attempts without a
+// value (e.g. no requester IP for scheduled emails) are not limited by the
+// scope.
+func rateLimitScopeValue(attempt *models.EmailSendAttempt, scope string) (string, bool) {
+	switch scope {
+	case RateLimitScopeGlobal:
+		return "", true
+	case RateLimitScopeRecipient:
+		return attempt.Recipient.String, attempt.Recipient.Valid
+	case RateLimitScopeRecipientDomain:
+		return attempt.RecipientDomain.String, attempt.RecipientDomain.Valid
+	case RateLimitScopeRequesterIP:
+		return attempt.RequesterIP.String, attempt.RequesterIP.Valid
+	case RateLimitScopeUser:
+		return attempt.UserID.String, attempt.UserID.Valid
+	default:
+		return "", false
+	}
+}
+
+// Checks all limits and records the attempt. Every limit is checked under an
+// advisory lock on its key, e.g. the recipient, so that the count can't change
+// before the insert. Senders only wait for each other when they share a key.
+// The global limits are checked last, so that their lock is held as briefly as
+// possible. The expired attempts are deleted by the cleanup task.
+func (l *limiter) reserve(
+	ctx context.Context,
+	db bun.IDB,
+	repoFactory repo.RepoFactory,
+	attempt *models.EmailSendAttempt,
+) error {
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		emailSendAttemptRepo := repoFactory.NewEmailSendAttemptRepo(tx)
+		currentTime := time.Now().UTC()
+
+		// The locks are always taken in the same order to avoid deadlocks
+		for _, key := range l.rateLimitLockKeys(attempt) {
+			if err := emailSendAttemptRepo.Lock(ctx, key); err != nil {
+				return err
+			}
+		}
+
+		if err := l.checkRateLimits(ctx, emailSendAttemptRepo, attempt, currentTime); err != nil {
+			return err
+		}
+
+		if len(l.globalLimits) > 0 {
+			if err := emailSendAttemptRepo.Lock(ctx, RateLimitScopeGlobal); err != nil {
+				return err
+			}
+
+			if err := l.checkGlobalLimits(ctx, emailSendAttemptRepo, currentTime); err != nil {
+				return err
+			}
+		}
+
+		return emailSendAttemptRepo.Create(ctx, attempt)
+	})
+}
+
+// Returns the sorted keys of the scoped limits that apply to the attempt, e.g.
+// "recipient:user@example.com". The rate limits with the global scope are
+// locked by budget.
+func (l *limiter) rateLimitLockKeys(attempt *models.EmailSendAttempt) []string {
+	var keys []string
+
+	for _, rateLimit := range l.rateLimits {
+		if rateLimit.Budget != attempt.Budget {
+			continue
+		}
+
+		value, ok := rateLimitScopeValue(attempt, rateLimit.Scope)
+		if !ok {
+			continue
+		}
+
+		if rateLimit.Scope == RateLimitScopeGlobal {
+			value = rateLimit.Budget
+		}
+
+		key := rateLimit.Scope + ":" + value
+		if !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+
+	slices.Sort(keys)
+
+	return keys
+}
+
+// Gives the reserved attempt back when the email was not sent
+func (l *limiter) release(
+	ctx context.Context,
+	emailSendAttemptRepo repo.EmailSendAttemptRepo,
+	attempt *models.EmailSendAttempt,
+) {
+	if err := emailSendAttemptRepo.Delete(ctx, attempt.ID); err != nil {
+		logger.MustFromContext(ctx).ErrorContext(
+			ctx,
+			"Failed to release email send attempt",
+			"email_send_attempt_id", attempt.ID,
+			"error", err,
+		)
+	}
+}
+
+func (l *limiter) cleanup(ctx context.Context, emailSendAttemptRepo repo.EmailSendAttemptRepo) error {
+	if err := emailSendAttemptRepo.DeleteBefore(ctx, time.Now().UTC().Add(-l.retention)); err != nil {
+		return err
+	}
+
+	logger.MustFromContext(ctx).InfoContext(ctx, "Expired email send attempts deleted", "retention", l.retention)
+
+	return nil
+}
+
+func (l *limiter) checkGlobalLimits(
+	ctx context.Context,
+	emailSendAttemptRepo repo.EmailSendAttemptRepo,
+	currentTime time.Time,
+) error {
+	for _, globalLimit := range l.globalLimits {
+		if globalLimit.Limit <= 0 {
+			logger.MustWarnContext(
+				ctx,
+				"Global email limit is <= 0, no emails will be sent",
+				"window", globalLimit.Window,
+			)
+
+			return ErrGlobalLimitReached
+		}
+
+		currentCount, err := emailSendAttemptRepo.CountAllSince(ctx, currentTime.Add(-globalLimit.Window))
+		if err != nil {
+			return err
+		}
+
+		if currentCount >= globalLimit.Limit {
+			logger.MustWarnContext(
+				ctx,
+				"Global email limit reached, no emails will be sent",
+				"window", globalLimit.Window,
+				"limit", globalLimit.Limit,
+				"current_count", currentCount,
+			)
+
+			return ErrGlobalLimitReached
+		}
+	}
+
+	return nil
+}
+
+func (l *limiter) checkRateLimits(
+	ctx context.Context,
+	emailSendAttemptRepo repo.EmailSendAttemptRepo,
+	attempt *models.EmailSendAttempt,
+	currentTime time.Time,
+) error {
+	for _, rateLimit := range l.rateLimits {
+		if rateLimit.Budget != attempt.Budget {
+			continue
+		}
+
+		value, ok := rateLimitScopeValue(attempt, rateLimit.Scope)
+		if !ok {
+			continue
+		}
+
+		currentCount, err := emailSendAttemptRepo.CountSince(
+			ctx,
+			rateLimit.Budget,
+			rateLimitScopeColumns[rateLimit.Scope],
+			value,
+			currentTime.Add(-rateLimit.Window),
+		)
+		if err != nil {
+			return err
+		}
+
+		if currentCount >= rateLimit.Limit {
+			logger.MustWarnContext(
+				ctx,
+				"Email rate limit reached, the email will not be sent",
+				"scope", rateLimit.Scope,
+				"budget", rateLimit.Budget,
+				"window", rateLimit.Window,
+				"limit", rateLimit.Limit,
+				"current_count", currentCount,
+				"user_id", attempt.UserID.String,
+			)
+
+			return fmt.Errorf("%w: %s %s", ErrRateLimitReached, rateLimit.Budget, rateLimit.Scope)
+		}
+	}
+
+	return nil
+}
+
+func (l *limiter) usage(ctx context.Context, emailSendAttemptRepo repo.EmailSendAttemptRepo) ([]*RateLimitUsage, error) {
+	currentTime := time.Now().UTC()
+	usages := make([]*RateLimitUsage, 0, len(l.globalLimits)+len(l.rateLimits))
+
+	for _, globalLimit := range l.globalLimits {
+		count, err := emailSendAttemptRepo.CountAllSince(ctx, currentTime.Add(-globalLimit.Window))
+		if err != nil {
+			return nil, err
+		}
+
+		usages = append(usages, &RateLimitUsage{
+			Scope:  RateLimitScopeGlobal,
+			Budget: rateLimitUsageBudgetAll,
+			Window: globalLimit.Window,
+			Limit:  globalLimit.Limit,
+			Top:    []*RateLimitUsageEntry{{Key: RateLimitScopeGlobal, Count: count}},
+		})
+	}
+
+	for _, rateLimit := range l.rateLimits {
+		since := currentTime.Add(-rateLimit.Window)
+		column := rateLimitScopeColumns[rateLimit.Scope]
+
+		usage := &RateLimitUsage{
+			Scope:  rateLimit.Scope,
+			Budget: rateLimit.Budget,
+			Window: rateLimit.Window,
+			Limit:  rateLimit.Limit,
+			Top:    []*RateLimitUsageEntry{},
+		}
+
+		if column == "" {
+			count, err := emailSendAttemptRepo.CountSince(ctx, rateLimit.Budget, "", "", since)
+			if err != nil {
+				return nil, err
+			}
+
+			usage.Top = append(usage.Top, &RateLimitUsageEntry{Key: RateLimitScopeGlobal, Count: count})
+		} else {
+			keyCounts, err := emailSendAttemptRepo.CountTopKeysSince(
+				ctx,
+				rateLimit.Budget,
+				column,
+				since,
+				rateLimitUsageTopSize,
+			)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, keyCount := range keyCounts {
+				usage.Top = append(usage.Top, &RateLimitUsageEntry{Key: keyCount.Key, Count: keyCount.Count})
+			}
+		}
+
+		usages = append(usages, usage)
+	}
+
+	return usages, nil
+}
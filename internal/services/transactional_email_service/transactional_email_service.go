@@ -2,76 +2,150 @@ package transactional_email_service
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
 	"time"
 
 	scalewayTransactionalEmails "github.com/scaleway/scaleway-sdk-go/api/tem/v1alpha1"
 	"github.com/scaleway/scaleway-sdk-go/scw"
 	"github.com/uptrace/bun"
 
+	"prutya/go-api-template/internal/config"
 	"prutya/go-api-template/internal/logger"
+	"prutya/go-api-template/internal/metrics"
+	"prutya/go-api-template/internal/models"
 	"prutya/go-api-template/internal/repo"
 )
 
 type TransactionalEmailService interface {
-	SendEmail(ctx context.Context, email string, userID string, subject string, textBody string, htmlBody string) error
-	ResetDailyGlobalLimit(ctx context.Context) error
+	SendEmail(
+		ctx context.Context,
+		email string,
+		userID string,
+		requesterIP string,
+		budget string,
+		template string,
+		subject string,
+		textBody string,
+		htmlBody string,
+	) error
+	// Deletes the attempts that no longer affect any limit. The limits only
+	// count the attempts in their windows, so this only keeps the table small.
+	CleanupEmailSendAttempts(ctx context.Context) error
+	GetRateLimitsUsage(ctx context.Context) ([]*RateLimitUsage, error)
+	VerifyWebhookSignature(timestamp string, signature string, body []byte) error
+	// Replaces the limits, e.g. after the config is reloaded. The attempts
+	// already recorded count towards the new limits.
+	SetLimits(globalLimits []config.TransactionalEmailsGlobalLimit, rateLimits []config.TransactionalEmailsRateLimit) error
+	HandleDeliveryEvent(ctx context.Context, event *DeliveryEvent) error
 }
 
 type transactionalEmailService struct {
-	dailyGlobalLimit int
+	limiter          atomic.Pointer[limiter]
 	senderEmail      string
 	senderName       string
+	webhookSecret    func() string
+	webhookTolerance time.Duration
 	db               bun.IDB
 	repoFactory      repo.RepoFactory
 
 	scwTransactionalEmailsAPI *scalewayTransactionalEmails.API
+	scalewayAccessKeyID       string
+	scalewaySecretKey         func() string
 }
 
 func NewTransactionalEmailService(
 	ctx context.Context,
 	enabled bool,
-	dailyGlobalLimit int,
+	globalLimits []config.TransactionalEmailsGlobalLimit,
+	rateLimits []config.TransactionalEmailsRateLimit,
 	senderEmail string,
 	senderName string,
+	webhookSecret func() string,
+	webhookTolerance time.Duration,
 	scalewayAccessKeyID string,
-	scalewaySecretKey string,
+	scalewaySecretKey func() string,
 	scalewayRegion scw.Region,
 	scalewayProjectID string,
+	httpClient *http.Client,
 	db bun.IDB,
 	repoFactory repo.RepoFactory,
 ) (TransactionalEmailService, error) {
-	if !enabled {
-		return newNoopTransactionalEmailService(ctx, dailyGlobalLimit, db, repoFactory)
+	if webhookSecret() == "" {
+		logger.MustWarnContext(ctx, "Transactional emails webhook secret is not set, delivery events will be rejected")
+	}
+
+	limiter, err := newLimiter(globalLimits, rateLimits)
+	if err != nil {
+		return nil, err
 	}
 
-	if dailyGlobalLimit <= 0 {
-		logger.MustWarnContext(ctx, "Daily global email limit is <= 0, no emails will be sent")
+	if !enabled {
+		return newNoopTransactionalEmailService(
+			ctx,
+			limiter,
+			webhookSecret,
+			webhookTolerance,
+			db,
+			repoFactory,
+		)
 	}
 
 	scwClient, err := scw.NewClient(
-		scw.WithAuth(scalewayAccessKeyID, scalewaySecretKey),
+		scw.WithAuth(scalewayAccessKeyID, scalewaySecretKey()),
 		scw.WithDefaultRegion(scalewayRegion),
 		scw.WithDefaultProjectID(scalewayProjectID),
+		scw.WithHTTPClient(httpClient),
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	return &transactionalEmailService{
-		dailyGlobalLimit: dailyGlobalLimit,
+	s := &transactionalEmailService{
 		senderEmail:      senderEmail,
 		senderName:       senderName,
+		webhookSecret:    webhookSecret,
+		webhookTolerance: webhookTolerance,
 		db:               db,
 		repoFactory:      repoFactory,
 
 		scwTransactionalEmailsAPI: scalewayTransactionalEmails.NewAPI(scwClient),
-	}, nil
+		scalewayAccessKeyID:       scalewayAccessKeyID,
+		scalewaySecretKey:         scalewaySecretKey,
+	}
+
+	s.limiter.Store(limiter)
+
+	return s, nil
 }
 
 func (s *transactionalEmailService) SendEmail(
 	ctx context.Context,
 	email string,
 	userID string,
+	requesterIP string,
+	budget string,
+	template string,
+	subject string,
+	textBody string,
+	htmlBody string,
+) error {
+	err := s.sendEmail(ctx, email, userID, requesterIP, budget, template, subject, textBody, htmlBody)
+
+	metrics.TransactionalEmailsTotal.WithLabelValues(template, sendEmailResult(err)).Inc()
+
+	return err
+}
+
+func (s *transactionalEmailService) sendEmail(
+	ctx context.Context,
+	email string,
+	userID string,
+	requesterIP string,
+	budget string,
+	template string,
 	subject string,
 	textBody string,
 	htmlBody string,
@@ -79,16 +153,51 @@ func (s *transactionalEmailService) SendEmail(
 	logger := logger.MustFromContext(ctx)
 
 	emailSendAttemptRepo := s.repoFactory.NewEmailSendAttemptRepo(s.db)
+	emailSuppressionRepo := s.repoFactory.NewEmailSuppressionRepo(s.db)
+	emailMessageRepo := s.repoFactory.NewEmailMessageRepo(s.db)
 
-	if err := checkGlobalLimit(ctx, s.dailyGlobalLimit, emailSendAttemptRepo, time.Now().UTC()); err != nil {
+	if err := checkSuppression(ctx, emailSuppressionRepo, email, userID); err != nil {
 		return err
 	}
 
-	logger.DebugContext(ctx, "Sending transactional email", "subject", subject, "user_id", userID)
+	emailSendAttempt := newEmailSendAttempt(email, userID, requesterIP, budget)
+
+	// A reservation is released by the limiter that made it, even if the limits
+	// are replaced in between
+	limiter := s.limiter.Load()
+
+	if err := limiter.reserve(ctx, s.db, s.repoFactory, emailSendAttempt); err != nil {
+		return err
+	}
+
+	emailMessageID, err := createEmailMessage(ctx, emailMessageRepo, email, userID, template)
+	if err != nil {
+		limiter.release(ctx, emailSendAttemptRepo, emailSendAttempt)
+
+		return err
+	}
+
+	logger.DebugContext(
+		ctx,
+		"Sending transactional email",
+		"subject", subject,
+		"user_id", userID,
+		"email_message_id", emailMessageID,
+	)
+
+	// Until the request is written, the email certainly isn't sent
+	var requestWritten atomic.Bool
+	traceCtx := httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			if info.Err == nil {
+				requestWritten.Store(true)
+			}
+		},
+	})
 
 	startTime := time.Now()
 
-	_, err := s.scwTransactionalEmailsAPI.CreateEmail(
+	response, err := s.scwTransactionalEmailsAPI.CreateEmail(
 		&scalewayTransactionalEmails.CreateEmailRequest{
 			From: &scalewayTransactionalEmails.CreateEmailRequestAddress{
 				Email: s.senderEmail,
@@ -101,24 +210,110 @@ func (s *transactionalEmailService) SendEmail(
 			Text:    textBody,
 			HTML:    htmlBody,
 		},
-		scw.WithContext(ctx),
+		scw.WithContext(traceCtx),
+		// Picks up a rotated secret key
+		scw.WithAuthRequest(s.scalewayAccessKeyID, s.scalewaySecretKey()),
 	)
 	if err != nil {
+		// After a timeout or a server error the email may have been sent, so it
+		// keeps counting towards the limits
+		if !requestWritten.Load() || isRejected(err) {
+			limiter.release(ctx, emailSendAttemptRepo, emailSendAttempt)
+		}
+
+		if _, updateErr := emailMessageRepo.UpdateStatus(
+			ctx,
+			emailMessageID,
+			models.EmailMessageStatusFailed,
+			err.Error(),
+		); updateErr != nil {
+			logger.ErrorContext(ctx, "Failed to mark email message as failed", "email_message_id", emailMessageID, "error", updateErr)
+		}
+
 		return err
 	}
 
 	duration := time.Since(startTime)
 	logger.DebugContext(ctx, "Transactional email sent", "duration", duration, "user_id", userID)
 
-	if err := emailSendAttemptRepo.Create(ctx); err != nil {
+	// Scaleway creates one email per recipient
+	var providerMessageID string
+	if len(response.Emails) > 0 {
+		providerMessageID = response.Emails[0].ID
+	}
+
+	if err := emailMessageRepo.MarkSent(ctx, emailMessageID, providerMessageID); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (s *transactionalEmailService) ResetDailyGlobalLimit(ctx context.Context) error {
+// Reports whether Scaleway refused to send the email, e.g. because of an
+// invalid request or an exceeded quota
+func isRejected(err error) bool {
+	var responseError *scw.ResponseError
+	if errors.As(err, &responseError) {
+		return responseError.StatusCode >= http.StatusBadRequest &&
+			responseError.StatusCode < http.StatusInternalServerError
+	}
+
+	var invalidArgumentsError *scw.InvalidArgumentsError
+	var quotasExceededError *scw.QuotasExceededError
+	var permissionsDeniedError *scw.PermissionsDeniedError
+	var deniedAuthenticationError *scw.DeniedAuthenticationError
+
+	return errors.As(err, &invalidArgumentsError) ||
+		errors.As(err, &quotasExceededError) ||
+		errors.As(err, &permissionsDeniedError) ||
+		errors.As(err, &deniedAuthenticationError)
+}
+
+func sendEmailResult(err error) string {
+	switch {
+	case err == nil:
+		return "sent"
+	case errors.Is(err, ErrRecipientSuppressed):
+		return "suppressed"
+	case errors.Is(err, ErrGlobalLimitReached):
+		return "global_limit_reached"
+	case errors.Is(err, ErrRateLimitReached):
+		return "rate_limit_reached"
+	default:
+		return "failed"
+	}
+}
+
+func (s *transactionalEmailService) CleanupEmailSendAttempts(ctx context.Context) error {
 	emailSendAttemptRepo := s.repoFactory.NewEmailSendAttemptRepo(s.db)
 
-	return resetDailyGlobalLimit(ctx, emailSendAttemptRepo)
+	return s.limiter.Load().cleanup(ctx, emailSendAttemptRepo)
+}
+
+func (s *transactionalEmailService) GetRateLimitsUsage(ctx context.Context) ([]*RateLimitUsage, error) {
+	emailSendAttemptRepo := s.repoFactory.NewEmailSendAttemptRepo(s.db)
+
+	return s.limiter.Load().usage(ctx, emailSendAttemptRepo)
+}
+
+func (s *transactionalEmailService) VerifyWebhookSignature(timestamp string, signature string, body []byte) error {
+	return verifyWebhookSignature(s.webhookSecret(), s.webhookTolerance, timestamp, signature, body)
+}
+
+func (s *transactionalEmailService) HandleDeliveryEvent(ctx context.Context, event *DeliveryEvent) error {
+	return handleDeliveryEvent(ctx, s.db, s.repoFactory, event)
+}
+
+func (s *transactionalEmailService) SetLimits(
+	globalLimits []config.TransactionalEmailsGlobalLimit,
+	rateLimits []config.TransactionalEmailsRateLimit,
+) error {
+	limiter, err := newLimiter(globalLimits, rateLimits)
+	if err != nil {
+		return err
+	}
+
+	s.limiter.Store(limiter)
+
+	return nil
 }
@@ -0,0 +1,33 @@
+package transactional_email_service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/scaleway/scaleway-sdk-go/scw"
+)
+
+func TestIsRejected(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"invalid arguments", &scw.InvalidArgumentsError{}, true},
+		{"quotas exceeded", &scw.QuotasExceededError{}, true},
+		{"denied authentication", &scw.DeniedAuthenticationError{}, true},
+		{"client error", &scw.ResponseError{StatusCode: 429}, true},
+		{"wrapped client error", fmt.Errorf("send: %w", &scw.ResponseError{StatusCode: 400}), true},
+		{"server error", &scw.ResponseError{StatusCode: 503}, false},
+		{"timeout", context.DeadlineExceeded, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isRejected(test.err); got != test.want {
+				t.Errorf("isRejected(%v) = %v, want %v", test.err, got, test.want)
+			}
+		})
+	}
+}
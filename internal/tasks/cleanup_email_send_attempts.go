@@ -1,3 +1,7 @@
 package tasks
 
 const TypeCleanupEmailSendAttempts = "cleanup_email_send_attempts"
+
+func NewCleanupEmailSendAttemptsTask() *Task {
+	return newTaskWithPolicy(TypeCleanupEmailSendAttempts, nil)
+}
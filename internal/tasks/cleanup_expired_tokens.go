@@ -0,0 +1,7 @@
+package tasks
+
+const TypeCleanupExpiredTokens = "cleanup_expired_tokens"
+
+func NewCleanupExpiredTokensTask() *Task {
+	return newTaskWithPolicy(TypeCleanupExpiredTokens, nil)
+}
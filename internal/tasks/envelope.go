@@ -0,0 +1,61 @@
+package tasks
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/hibiken/asynq"
+)
+
+const envelopeVersion = 1
+
+// Wraps the payload of a task with metadata that follows it from the enqueuer
+// to the worker, e.g. the request ID and the trace context
+type envelope struct {
+	Envelope int               `json:"envelope"`
+	Metadata map[string]string `json:"metadata"`
+	Payload  json.RawMessage   `json:"payload,omitempty"`
+}
+
+// Returns a copy of the task with the metadata in its payload. Unique tasks are
+// returned as is, because asynq derives their uniqueness from the payload.
+func (t *Task) WithMetadata(metadata map[string]string) *Task {
+	if len(metadata) == 0 || PolicyFor(t.AsynqTask.Type()).UniqueTTL > 0 {
+		return t
+	}
+
+	// A task recreated from a recorded payload, e.g. a retried failed task,
+	// gets the new metadata
+	_, payload := UnwrapPayload(t.AsynqTask.Payload())
+
+	wrappedPayload, err := json.Marshal(&envelope{
+		Envelope: envelopeVersion,
+		Metadata: metadata,
+		Payload:  payload,
+	})
+	if err != nil {
+		return t
+	}
+
+	return &Task{
+		AsynqTask: asynq.NewTask(t.AsynqTask.Type(), wrappedPayload, t.options...),
+		options:   t.options,
+	}
+}
+
+// Splits the payload into the metadata and the payload of the task. Payloads
+// without an envelope are returned as is.
+func UnwrapPayload(payload []byte) (map[string]string, []byte) {
+	// Envelopes are marshalled with the version first, so most payloads are told
+	// apart without unmarshalling them
+	if !bytes.HasPrefix(payload, []byte(`{"envelope":`)) {
+		return nil, payload
+	}
+
+	var wrapped envelope
+	if err := json.Unmarshal(payload, &wrapped); err != nil || wrapped.Envelope != envelopeVersion {
+		return nil, payload
+	}
+
+	return wrapped.Metadata, wrapped.Payload
+}
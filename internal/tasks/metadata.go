@@ -0,0 +1,109 @@
+package tasks
+
+import (
+	"context"
+	"time"
+
+	loggerpkg "prutya/go-api-template/internal/logger"
+)
+
+// Keys of the metadata in the envelope. The trace context is stored next to
+// them under its own keys.
+const (
+	metadataKeyRequestID  = "request_id"
+	metadataKeyUserID     = "user_id"
+	metadataKeyOrigin     = "origin"
+	metadataKeyEnqueuedAt = "enqueued_at"
+)
+
+// Ties a task to the flow that enqueued it, so that the flow can be followed
+// in the logs from the request to the worker
+type Metadata struct {
+	RequestID string
+	UserID    string
+	// Where the task was enqueued, e.g. "http POST /account/register" or
+	// "task send_verification_email"
+	Origin     string
+	EnqueuedAt time.Time
+}
+
+type metadataContextKeyType struct{}
+
+var metadataContextKey = metadataContextKeyType{}
+
+// Stores the metadata passed along with the tasks enqueued with the context
+func NewContextWithMetadata(ctx context.Context, metadata Metadata) context.Context {
+	return context.WithValue(ctx, metadataContextKey, metadata)
+}
+
+// Ties the tasks enqueued with the context to the user, e.g. to the user that
+// just registered
+func NewContextWithUserID(ctx context.Context, userID string) context.Context {
+	metadata := MetadataFromContext(ctx)
+	metadata.UserID = userID
+
+	return NewContextWithMetadata(ctx, metadata)
+}
+
+// Returns empty metadata if there's none in the context
+func MetadataFromContext(ctx context.Context) Metadata {
+	metadata, _ := ctx.Value(metadataContextKey).(Metadata)
+
+	return metadata
+}
+
+// Parses the metadata from the envelope. Missing or malformed values are left
+// empty.
+func MetadataFromMap(values map[string]string) Metadata {
+	metadata := Metadata{
+		RequestID: values[metadataKeyRequestID],
+		UserID:    values[metadataKeyUserID],
+		Origin:    values[metadataKeyOrigin],
+	}
+
+	if enqueuedAt, err := time.Parse(time.RFC3339Nano, values[metadataKeyEnqueuedAt]); err == nil {
+		metadata.EnqueuedAt = enqueuedAt
+	}
+
+	return metadata
+}
+
+// Adds the metadata to the values stored in the envelope
+func (m Metadata) AddTo(values map[string]string) {
+	if m.RequestID != "" {
+		values[metadataKeyRequestID] = m.RequestID
+	}
+
+	if m.UserID != "" {
+		values[metadataKeyUserID] = m.UserID
+	}
+
+	if m.Origin != "" {
+		values[metadataKeyOrigin] = m.Origin
+	}
+
+	if !m.EnqueuedAt.IsZero() {
+		values[metadataKeyEnqueuedAt] = m.EnqueuedAt.UTC().Format(time.RFC3339Nano)
+	}
+}
+
+// Returns a logger that adds the non-empty metadata to its output
+func (m Metadata) AddToLogger(logger *loggerpkg.Logger) *loggerpkg.Logger {
+	if m.RequestID != "" {
+		logger = logger.With(metadataKeyRequestID, m.RequestID)
+	}
+
+	if m.UserID != "" {
+		logger = logger.With(metadataKeyUserID, m.UserID)
+	}
+
+	if m.Origin != "" {
+		logger = logger.With(metadataKeyOrigin, m.Origin)
+	}
+
+	if !m.EnqueuedAt.IsZero() {
+		logger = logger.With(metadataKeyEnqueuedAt, m.EnqueuedAt)
+	}
+
+	return logger
+}
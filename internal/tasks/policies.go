@@ -0,0 +1,130 @@
+package tasks
+
+import (
+	"math/rand/v2"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// Queues the tasks are routed to. The worker processes them in proportion to
+// the weights from the config.
+const QueueCritical = "critical"
+const QueueDefault = "default"
+const QueueLow = "low"
+
+// How a task type is enqueued and retried
+type Policy struct {
+	Queue    string
+	MaxRetry int
+	// Deadline of a single attempt
+	Timeout time.Duration
+	// Identical tasks (same type, payload and queue) enqueued within this window
+	// are rejected. Disabled when it's 0.
+	UniqueTTL time.Duration
+	// Delay before the n-th retry. `asynq.DefaultRetryDelayFunc` is used when
+	// it's nil.
+	RetryDelay asynq.RetryDelayFunc
+}
+
+var policies = map[string]*Policy{
+	// Users are waiting for the code, so retry quickly and never let other
+	// tasks get in the way
+	TypeSendPasswordResetEmail: {
+		Queue:      QueueCritical,
+		MaxRetry:   5,
+		Timeout:    30 * time.Second,
+		RetryDelay: exponentialRetryDelay(2*time.Second, 1*time.Minute),
+	},
+	TypeSendVerificationEmail: {
+		Queue:      QueueDefault,
+		MaxRetry:   5,
+		Timeout:    30 * time.Second,
+		RetryDelay: exponentialRetryDelay(5*time.Second, 5*time.Minute),
+	},
+	// Runs every hour, a run that piles up behind a slow one is useless
+	TypeCleanupEmailSendAttempts: {
+		Queue:     QueueLow,
+		MaxRetry:  3,
+		Timeout:   5 * time.Minute,
+		UniqueTTL: 55 * time.Minute,
+	},
+	// Deletes in batches, a run that times out is continued by the next one
+	TypeCleanupExpiredTokens: {
+		Queue:     QueueLow,
+		MaxRetry:  3,
+		Timeout:   15 * time.Minute,
+		UniqueTTL: 55 * time.Minute,
+	},
+	TypeSyncEmailBlocklist: {
+		Queue:      QueueLow,
+		MaxRetry:   3,
+		Timeout:    2 * time.Minute,
+		UniqueTTL:  1 * time.Hour,
+		RetryDelay: exponentialRetryDelay(1*time.Minute, 30*time.Minute),
+	},
+}
+
+var defaultPolicy = &Policy{
+	Queue:    QueueDefault,
+	MaxRetry: 25,
+	Timeout:  30 * time.Minute,
+}
+
+func PolicyFor(taskType string) *Policy {
+	if policy, found := policies[taskType]; found {
+		return policy
+	}
+
+	return defaultPolicy
+}
+
+// Returns the queues referenced by the policies
+func PolicyQueues() []string {
+	queues := []string{defaultPolicy.Queue}
+
+	for _, policy := range policies {
+		queues = append(queues, policy.Queue)
+	}
+
+	return queues
+}
+
+// Dispatches to the retry delay of the task type. Meant to be used as the
+// `RetryDelayFunc` of the worker.
+func RetryDelay(n int, err error, task *asynq.Task) time.Duration {
+	if policy := PolicyFor(task.Type()); policy.RetryDelay != nil {
+		return policy.RetryDelay(n, err, task)
+	}
+
+	return asynq.DefaultRetryDelayFunc(n, err, task)
+}
+
+func (p *Policy) options() []asynq.Option {
+	options := []asynq.Option{
+		asynq.Queue(p.Queue),
+		asynq.MaxRetry(p.MaxRetry),
+		asynq.Timeout(p.Timeout),
+	}
+
+	if p.UniqueTTL > 0 {
+		options = append(options, asynq.Unique(p.UniqueTTL))
+	}
+
+	return options
+}
+
+// Doubles the delay with every retry up to the maximum, with up to 25% of
+// jitter so that failed tasks don't retry in lockstep
+func exponentialRetryDelay(base time.Duration, maximum time.Duration) asynq.RetryDelayFunc {
+	return func(n int, _ error, _ *asynq.Task) time.Duration {
+		delay := base
+		for i := 0; i < n && delay < maximum; i++ {
+			delay *= 2
+		}
+
+		delay = min(delay, maximum)
+
+		return delay + rand.N(delay/4+1)
+	}
+}
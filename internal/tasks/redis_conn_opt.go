@@ -0,0 +1,29 @@
+package tasks
+
+import (
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+)
+
+type redisConnOpt struct {
+	addr     string
+	password func() string
+}
+
+// Connects asynq to Redis with the current password, so that new connections
+// pick up a rotated one
+func NewRedisConnOpt(addr string, password func() string) asynq.RedisConnOpt {
+	return &redisConnOpt{
+		addr:     addr,
+		password: password,
+	}
+}
+
+func (o *redisConnOpt) MakeRedisClient() any {
+	return redis.NewClient(&redis.Options{
+		Addr: o.addr,
+		CredentialsProvider: func() (string, string) {
+			return "", o.password()
+		},
+	})
+}
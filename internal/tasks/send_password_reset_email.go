@@ -2,6 +2,7 @@ package tasks
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/hibiken/asynq"
 )
@@ -9,17 +10,29 @@ import (
 const TypeSendPasswordResetEmail = "send_password_reset_email"
 
 type SendPasswordResetEmailPayload struct {
-	UserID string
+	UserID      string
+	RequesterIP string
+	// Identifies the password reset round the email is sent for. Zero in tasks
+	// enqueued before it was introduced.
+	ExpiresAt time.Time
 }
 
-func NewSendPasswordResetEmailTask(userID string) (*Task, error) {
+// At most one task exists per user and password reset round, enqueueing it again
+// is a no-op
+func NewSendPasswordResetEmailTask(userID string, requesterIP string, expiresAt time.Time) (*Task, error) {
 	payload, err := json.Marshal(SendPasswordResetEmailPayload{
-		UserID: userID,
+		UserID:      userID,
+		RequesterIP: requesterIP,
+		ExpiresAt:   expiresAt,
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	return NewTask(asynq.NewTask(TypeSendPasswordResetEmail, payload)), nil
+	return newTaskWithPolicy(
+		TypeSendPasswordResetEmail,
+		payload,
+		asynq.TaskID(roundTaskID(TypeSendPasswordResetEmail, userID, expiresAt)),
+	), nil
 }
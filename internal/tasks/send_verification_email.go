@@ -2,6 +2,7 @@ package tasks
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/hibiken/asynq"
 )
@@ -9,17 +10,29 @@ import (
 const TypeSendVerificationEmail = "send_verification_email"
 
 type SendVerificationEmailPayload struct {
-	UserID string
+	UserID      string
+	RequesterIP string
+	// Identifies the verification round the email is sent for. Zero in tasks
+	// enqueued before it was introduced.
+	ExpiresAt time.Time
 }
 
-func NewSendVerificationEmailTask(userID string) (*Task, error) {
+// At most one task exists per user and verification round, enqueueing it again
+// is a no-op
+func NewSendVerificationEmailTask(userID string, requesterIP string, expiresAt time.Time) (*Task, error) {
 	payload, err := json.Marshal(SendVerificationEmailPayload{
-		UserID: userID,
+		UserID:      userID,
+		RequesterIP: requesterIP,
+		ExpiresAt:   expiresAt,
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	return NewTask(asynq.NewTask(TypeSendVerificationEmail, payload)), nil
+	return newTaskWithPolicy(
+		TypeSendVerificationEmail,
+		payload,
+		asynq.TaskID(roundTaskID(TypeSendVerificationEmail, userID, expiresAt)),
+	), nil
 }
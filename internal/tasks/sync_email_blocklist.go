@@ -0,0 +1,7 @@
+package tasks
+
+const TypeSyncEmailBlocklist = "sync_email_blocklist"
+
+func NewSyncEmailBlocklistTask() *Task {
+	return newTaskWithPolicy(TypeSyncEmailBlocklist, nil)
+}
@@ -1,9 +1,33 @@
 package tasks
 
-import "github.com/hibiken/asynq"
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// Returned when the same task is already enqueued
+var ErrDuplicateTask = errors.New("duplicate task")
+
+// Returned when the task is not in the queue
+var ErrTaskNotFound = errors.New("task not found")
+
+// Task types the worker has handlers for
+var types = []string{
+	TypeCleanupEmailSendAttempts,
+	TypeCleanupExpiredTokens,
+	TypeSendPasswordResetEmail,
+	TypeSendVerificationEmail,
+	TypeSyncEmailBlocklist,
+}
 
 type Task struct {
 	AsynqTask *asynq.Task
+	// Kept to recreate the task with another payload
+	options []asynq.Option
 }
 
 func NewTask(t *asynq.Task) *Task {
@@ -12,6 +36,42 @@ func NewTask(t *asynq.Task) *Task {
 	}
 }
 
+// Creates a task with the options of its policy. The extra options take
+// precedence.
+func newTaskWithPolicy(taskType string, payload []byte, extraOptions ...asynq.Option) *Task {
+	options := append(PolicyFor(taskType).options(), extraOptions...)
+
+	return &Task{
+		AsynqTask: asynq.NewTask(taskType, payload, options...),
+		options:   options,
+	}
+}
+
+// Recreates a task from its type and payload, e.g. to retry a failed task
+func NewTaskFromPayload(taskType string, payload []byte) *Task {
+	return newTaskWithPolicy(taskType, payload)
+}
+
+// Creates a task enqueued by the scheduler. The queue overrides the one from
+// the policy unless it's empty.
+func NewScheduledTask(taskType string, payload []byte, queue string) *Task {
+	if queue == "" {
+		return newTaskWithPolicy(taskType, payload)
+	}
+
+	return newTaskWithPolicy(taskType, payload, asynq.Queue(queue))
+}
+
+func IsKnownType(taskType string) bool {
+	return slices.Contains(types, taskType)
+}
+
+// Deterministic ID of the task sent for a round of a flow, e.g. an email
+// verification, identified by its expiration time
+func roundTaskID(taskType string, userID string, expiresAt time.Time) string {
+	return fmt.Sprintf("%s:%s:%d", taskType, userID, expiresAt.UnixMicro())
+}
+
 type TaskInfo struct {
 	asynqTaskInfo *asynq.TaskInfo
 }
@@ -2,32 +2,43 @@ package tasks_client
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/hibiken/asynq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 
 	"prutya/go-api-template/internal/logger"
+	"prutya/go-api-template/internal/metrics"
 	"prutya/go-api-template/internal/tasks"
+	"prutya/go-api-template/internal/tracing"
 )
 
 type Client interface {
 	Ping() error
+	// Returns `tasks.ErrDuplicateTask` if the task is already enqueued
 	Enqueue(ctx context.Context, task *tasks.Task) (*tasks.TaskInfo, error)
+	// Moves an archived task back to the queue. Returns `tasks.ErrTaskNotFound`
+	// if it's no longer archived.
+	RunArchived(queue string, taskID string) error
+	// Returns `tasks.ErrTaskNotFound` if the task is no longer archived
+	DeleteArchived(queue string, taskID string) error
 	Close() error
 }
 
 type client struct {
-	asynqClient *asynq.Client
+	asynqClient    *asynq.Client
+	asynqInspector *asynq.Inspector
 }
 
-func NewClient(redisAddr string, redisPassword string) Client {
-	// TODO: Support more configuration options
-	asynqClient := asynq.NewClient(asynq.RedisClientOpt{
-		Addr:     redisAddr,
-		Password: redisPassword,
-	})
+func NewClient(redisAddr string, redisPassword func() string) Client {
+	redisClientOpt := tasks.NewRedisConnOpt(redisAddr, redisPassword)
 
 	return &client{
-		asynqClient: asynqClient,
+		asynqClient:    asynq.NewClient(redisClientOpt),
+		asynqInspector: asynq.NewInspector(redisClientOpt),
 	}
 }
 
@@ -41,8 +52,39 @@ func (c *client) Enqueue(ctx context.Context, task *tasks.Task) (*tasks.TaskInfo
 
 	logger.DebugContext(ctx, "Enqueueing task", "task_type", taskType)
 
-	asynqTaskInfo, err := c.asynqClient.EnqueueContext(ctx, task.AsynqTask)
+	ctx, span := tracing.Start(
+		ctx,
+		"enqueue "+taskType,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("asynq"),
+			semconv.MessagingOperationName("enqueue"),
+		),
+	)
+	defer span.End()
+
+	// The worker restores the request and user IDs and continues the trace from
+	// the metadata
+	metadata := tasks.MetadataFromContext(ctx)
+	metadata.EnqueuedAt = time.Now()
+
+	values := map[string]string{}
+	metadata.AddTo(values)
+	tracing.Inject(ctx, values)
+
+	asynqTaskInfo, err := c.asynqClient.EnqueueContext(ctx, task.WithMetadata(values).AsynqTask)
 	if err != nil {
+		// A task with the same ID or a unique task with the same payload is
+		// already enqueued
+		if errors.Is(err, asynq.ErrTaskIDConflict) || errors.Is(err, asynq.ErrDuplicateTask) {
+			logger.InfoContext(ctx, "Dropped duplicate task", "task_type", taskType, "error", err)
+			metrics.TasksDuplicatesTotal.WithLabelValues(taskType).Inc()
+
+			return nil, fmt.Errorf("%w: %v", tasks.ErrDuplicateTask, err)
+		}
+
+		tracing.RecordError(span, err)
+
 		return nil, err
 	}
 
@@ -51,6 +93,22 @@ func (c *client) Enqueue(ctx context.Context, task *tasks.Task) (*tasks.TaskInfo
 	return tasks.NewTaskInfo(asynqTaskInfo), nil
 }
 
+func (c *client) RunArchived(queue string, taskID string) error {
+	return mapInspectorError(c.asynqInspector.RunTask(queue, taskID))
+}
+
+func (c *client) DeleteArchived(queue string, taskID string) error {
+	return mapInspectorError(c.asynqInspector.DeleteTask(queue, taskID))
+}
+
 func (c *client) Close() error {
-	return c.asynqClient.Close()
+	return errors.Join(c.asynqClient.Close(), c.asynqInspector.Close())
+}
+
+func mapInspectorError(err error) error {
+	if errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, asynq.ErrQueueNotFound) {
+		return fmt.Errorf("%w: %v", tasks.ErrTaskNotFound, err)
+	}
+
+	return err
 }
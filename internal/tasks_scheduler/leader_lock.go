@@ -0,0 +1,69 @@
+package tasks_scheduler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const leaderLockKey = "tasks_scheduler:leader"
+
+// Extends the lock only if it's still held by this replica
+var renewLeaderLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// Deletes the lock only if it's still held by this replica
+var releaseLeaderLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// A lock in Redis that expires unless its holder renews it
+type leaderLock struct {
+	redisClient *redis.Client
+	holderID    string
+	ttl         time.Duration
+}
+
+func newLeaderLock(redisClient *redis.Client, holderID string, ttl time.Duration) *leaderLock {
+	return &leaderLock{
+		redisClient: redisClient,
+		holderID:    holderID,
+		ttl:         ttl,
+	}
+}
+
+func (l *leaderLock) acquire(ctx context.Context) (bool, error) {
+	err := l.redisClient.SetArgs(ctx, leaderLockKey, l.holderID, redis.SetArgs{Mode: "NX", TTL: l.ttl}).Err()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Returns false if the lock expired and was possibly taken by another replica
+func (l *leaderLock) renew(ctx context.Context) (bool, error) {
+	renewed, err := renewLeaderLockScript.Run(ctx, l.redisClient, []string{leaderLockKey}, l.holderID, l.ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+
+	return renewed == 1, nil
+}
+
+func (l *leaderLock) release(ctx context.Context) error {
+	return releaseLeaderLockScript.Run(ctx, l.redisClient, []string{leaderLockKey}, l.holderID).Err()
+}
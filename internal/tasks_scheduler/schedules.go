@@ -0,0 +1,169 @@
+package tasks_scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/robfig/cron/v3"
+
+	"prutya/go-api-template/internal/config"
+	"prutya/go-api-template/internal/tasks"
+)
+
+var ErrInvalidSchedule = errors.New("invalid task schedule")
+
+// A schedule with the time of its next run
+type ScheduleInfo struct {
+	Name      string    `json:"name"`
+	Cron      string    `json:"cron"`
+	Timezone  string    `json:"timezone"`
+	TaskType  string    `json:"taskType"`
+	Queue     string    `json:"queue"`
+	NextRunAt time.Time `json:"nextRunAt"`
+}
+
+type periodicTask struct {
+	schedule config.TasksSchedule
+	cronspec string
+	cron     cron.Schedule
+	task     *tasks.Task
+}
+
+// Provides the periodic tasks to the asynq periodic task manager
+type periodicTaskConfigProvider struct {
+	periodicTasks []*periodicTask
+}
+
+func (p *periodicTaskConfigProvider) GetConfigs() ([]*asynq.PeriodicTaskConfig, error) {
+	configs := make([]*asynq.PeriodicTaskConfig, len(p.periodicTasks))
+
+	for i, periodicTask := range p.periodicTasks {
+		configs[i] = &asynq.PeriodicTaskConfig{
+			Cronspec: periodicTask.cronspec,
+			Task:     periodicTask.task.AsynqTask,
+		}
+	}
+
+	return configs, nil
+}
+
+// Lists the schedules with their next run times without starting a scheduler
+func ListSchedules(
+	schedules []config.TasksSchedule,
+	emailBlocklistSyncURL string,
+	emailBlocklistSyncSchedule string,
+	now time.Time,
+) ([]*ScheduleInfo, error) {
+	periodicTasks, err := newPeriodicTasks(schedules, emailBlocklistSyncURL, emailBlocklistSyncSchedule)
+	if err != nil {
+		return nil, err
+	}
+
+	return scheduleInfos(periodicTasks, now), nil
+}
+
+func newPeriodicTasks(
+	schedules []config.TasksSchedule,
+	emailBlocklistSyncURL string,
+	emailBlocklistSyncSchedule string,
+) ([]*periodicTask, error) {
+	// Sync the email blocklist from the configured URL
+	if emailBlocklistSyncURL != "" {
+		schedules = append(schedules, config.TasksSchedule{
+			Name:     tasks.TypeSyncEmailBlocklist,
+			Cron:     emailBlocklistSyncSchedule,
+			TaskType: tasks.TypeSyncEmailBlocklist,
+		})
+	}
+
+	periodicTasks := make([]*periodicTask, len(schedules))
+	names := make(map[string]bool, len(schedules))
+
+	for i, schedule := range schedules {
+		periodicTask, err := newPeriodicTask(schedule)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %w", ErrInvalidSchedule, schedule.Name, err)
+		}
+
+		if names[schedule.Name] {
+			return nil, fmt.Errorf("%w %q: duplicate name", ErrInvalidSchedule, schedule.Name)
+		}
+
+		names[schedule.Name] = true
+		periodicTasks[i] = periodicTask
+	}
+
+	return periodicTasks, nil
+}
+
+func newPeriodicTask(schedule config.TasksSchedule) (*periodicTask, error) {
+	if schedule.Name == "" {
+		return nil, errors.New("name is required")
+	}
+
+	if !tasks.IsKnownType(schedule.TaskType) {
+		return nil, fmt.Errorf("unknown task type %q", schedule.TaskType)
+	}
+
+	cronspec := schedule.Cron
+
+	if schedule.Timezone != "" {
+		if _, err := time.LoadLocation(schedule.Timezone); err != nil {
+			return nil, err
+		}
+
+		// Supported by the cron parser asynq uses
+		cronspec = "CRON_TZ=" + schedule.Timezone + " " + cronspec
+	}
+
+	cronSchedule, err := cron.ParseStandard(cronspec)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload []byte
+
+	if len(schedule.Payload) > 0 {
+		payload, err = json.Marshal(schedule.Payload)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &periodicTask{
+		schedule: schedule,
+		cronspec: cronspec,
+		cron:     cronSchedule,
+		task:     tasks.NewScheduledTask(schedule.TaskType, payload, schedule.Queue),
+	}, nil
+}
+
+func scheduleInfos(periodicTasks []*periodicTask, now time.Time) []*ScheduleInfo {
+	infos := make([]*ScheduleInfo, len(periodicTasks))
+
+	for i, periodicTask := range periodicTasks {
+		queue := periodicTask.schedule.Queue
+		if queue == "" {
+			queue = tasks.PolicyFor(periodicTask.schedule.TaskType).Queue
+		}
+
+		timezone := periodicTask.schedule.Timezone
+		if timezone == "" {
+			timezone = time.UTC.String()
+		}
+
+		infos[i] = &ScheduleInfo{
+			Name:      periodicTask.schedule.Name,
+			Cron:      periodicTask.schedule.Cron,
+			Timezone:  timezone,
+			TaskType:  periodicTask.schedule.TaskType,
+			Queue:     queue,
+			NextRunAt: periodicTask.cron.Next(now.UTC()),
+		}
+	}
+
+	return infos
+}
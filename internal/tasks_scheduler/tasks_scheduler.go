@@ -2,51 +2,214 @@ package tasks_scheduler
 
 import (
 	"context"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	"github.com/gofrs/uuid/v5"
 	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
 
-	"prutya/go-api-template/internal/logger"
+	"prutya/go-api-template/internal/config"
+	loggerpkg "prutya/go-api-template/internal/logger"
+	"prutya/go-api-template/internal/metrics"
 	"prutya/go-api-template/internal/tasks"
 )
 
 type Scheduler interface {
+	// Enqueues the periodic tasks while holding the leader lock, until the
+	// process receives SIGINT or SIGTERM
 	Run() error
+	// Checks the connection to Redis
+	Ping(ctx context.Context) error
+	// Lists the schedules with their next run times
+	Schedules(now time.Time) []*ScheduleInfo
+	// Reports whether this replica enqueues the periodic tasks
+	IsLeader() bool
 }
 
 type scheduler struct {
-	asynqScheduler *asynq.Scheduler
+	baseCtx       context.Context
+	logger        *loggerpkg.Logger
+	redisClient   *redis.Client
+	periodicTasks []*periodicTask
+	leaderLock    *leaderLock
+
+	// Only accessed by Run
+	manager *asynq.PeriodicTaskManager
+
+	isLeader atomic.Bool
 }
 
 func NewScheduler(
 	baseCtx context.Context,
-	redisAddr string,
-	redisPassword string,
+	schedules []config.TasksSchedule,
+	leaderLockTTL time.Duration,
+	emailBlocklistSyncURL string,
+	emailBlocklistSyncSchedule string,
+	redisClient *redis.Client,
 ) (Scheduler, error) {
-	logger := logger.MustFromContext(baseCtx)
+	logger := loggerpkg.MustFromContext(baseCtx)
 
-	asynqScheduler := asynq.NewScheduler(
-		asynq.RedisClientOpt{
-			Addr:     redisAddr,
-			Password: redisPassword,
-		},
-		&asynq.SchedulerOpts{
-			Logger: tasks.NewSlogLoggerAdapter(logger),
-		},
-	)
+	periodicTasks, err := newPeriodicTasks(schedules, emailBlocklistSyncURL, emailBlocklistSyncSchedule)
+	if err != nil {
+		return nil, err
+	}
 
-	// Cleanup email send attempts ever day at 02:00
-	if _, err := asynqScheduler.Register(
-		"0 2 * * *",
-		asynq.NewTask(tasks.TypeCleanupEmailSendAttempts, nil),
-	); err != nil {
+	holderID, err := newHolderID()
+	if err != nil {
 		return nil, err
 	}
 
 	return &scheduler{
-		asynqScheduler: asynqScheduler,
+		baseCtx:       baseCtx,
+		logger:        logger.With("holder_id", holderID),
+		redisClient:   redisClient,
+		periodicTasks: periodicTasks,
+		leaderLock:    newLeaderLock(redisClient, holderID, leaderLockTTL),
 	}, nil
 }
 
 func (s *scheduler) Run() error {
-	return s.asynqScheduler.Run()
+	shutdownCh := make(chan os.Signal, 1)
+	signal.Notify(shutdownCh, syscall.SIGINT, syscall.SIGTERM)
+
+	// Renew well before the lock expires so that a slow round trip doesn't
+	// lose it
+	ticker := time.NewTicker(s.leaderLock.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		if err := s.elect(); err != nil {
+			return err
+		}
+
+		select {
+		case <-shutdownCh:
+			s.logger.InfoContext(s.baseCtx, "Scheduler is shutting down")
+
+			s.resign()
+
+			s.logger.InfoContext(s.baseCtx, "Scheduler stopped")
+
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *scheduler) Ping(ctx context.Context) error {
+	return s.redisClient.Ping(ctx).Err()
+}
+
+func (s *scheduler) Schedules(now time.Time) []*ScheduleInfo {
+	return scheduleInfos(s.periodicTasks, now)
+}
+
+func (s *scheduler) IsLeader() bool {
+	return s.isLeader.Load()
+}
+
+// Acquires or renews the leader lock and starts or stops enqueuing the
+// periodic tasks accordingly
+func (s *scheduler) elect() error {
+	if s.manager != nil {
+		renewed, err := s.leaderLock.renew(s.baseCtx)
+		if err != nil {
+			// Another replica takes over once the lock expires, so stop before
+			// it does to never enqueue the tasks twice
+			s.logger.WarnContext(s.baseCtx, "Failed to renew the scheduler leader lock", "error", err)
+		}
+
+		if !renewed {
+			s.logger.WarnContext(s.baseCtx, "Scheduler lost the leadership")
+			s.stopManager()
+		}
+
+		return nil
+	}
+
+	acquired, err := s.leaderLock.acquire(s.baseCtx)
+	if err != nil {
+		s.logger.WarnContext(s.baseCtx, "Failed to acquire the scheduler leader lock", "error", err)
+
+		return nil
+	}
+
+	if !acquired {
+		return nil
+	}
+
+	s.logger.InfoContext(s.baseCtx, "Scheduler became the leader")
+
+	return s.startManager()
+}
+
+func (s *scheduler) resign() {
+	if s.manager == nil {
+		return
+	}
+
+	s.stopManager()
+
+	// The lock would expire anyway, releasing it lets another replica take over
+	// right away
+	if err := s.leaderLock.release(context.WithoutCancel(s.baseCtx)); err != nil {
+		s.logger.WarnContext(s.baseCtx, "Failed to release the scheduler leader lock", "error", err)
+	}
+}
+
+// A manager can't be restarted, so a new one is created on every election
+func (s *scheduler) startManager() error {
+	manager, err := asynq.NewPeriodicTaskManager(asynq.PeriodicTaskManagerOpts{
+		PeriodicTaskConfigProvider: &periodicTaskConfigProvider{periodicTasks: s.periodicTasks},
+		RedisUniversalClient:       s.redisClient,
+		SchedulerOpts: &asynq.SchedulerOpts{
+			Logger: tasks.NewSlogLoggerAdapter(s.logger),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := manager.Start(); err != nil {
+		return err
+	}
+
+	s.manager = manager
+	s.setLeader(true)
+
+	return nil
+}
+
+func (s *scheduler) stopManager() {
+	s.manager.Shutdown()
+	s.manager = nil
+	s.setLeader(false)
+}
+
+func (s *scheduler) setLeader(isLeader bool) {
+	s.isLeader.Store(isLeader)
+
+	if isLeader {
+		metrics.TasksSchedulerLeader.Set(1)
+	} else {
+		metrics.TasksSchedulerLeader.Set(0)
+	}
+}
+
+func newHolderID() (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", err
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return "", err
+	}
+
+	return hostname + ":" + id.String(), nil
 }
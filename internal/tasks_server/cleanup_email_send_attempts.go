@@ -20,5 +20,5 @@ func newCleanupEmailSendAttemptsHandler(
 }
 
 func (h *cleanupEmailSendAttemptsHandler) ProcessTask(ctx context.Context, task *asynq.Task) error {
-	return h.transactionalEmailService.ResetDailyGlobalLimit(ctx)
+	return h.transactionalEmailService.CleanupEmailSendAttempts(ctx)
 }
@@ -0,0 +1,25 @@
+package tasks_server
+
+import (
+	"context"
+
+	"github.com/hibiken/asynq"
+
+	"prutya/go-api-template/internal/services/authentication_service"
+)
+
+type cleanupExpiredTokensHandler struct {
+	authenticationService authentication_service.AuthenticationService
+}
+
+func newCleanupExpiredTokensHandler(
+	authenticationService authentication_service.AuthenticationService,
+) *cleanupExpiredTokensHandler {
+	return &cleanupExpiredTokensHandler{
+		authenticationService: authenticationService,
+	}
+}
+
+func (h *cleanupExpiredTokensHandler) ProcessTask(ctx context.Context, task *asynq.Task) error {
+	return h.authenticationService.CleanupExpiredTokens(ctx)
+}
@@ -0,0 +1,58 @@
+package tasks_server
+
+import (
+	"context"
+
+	"github.com/hibiken/asynq"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+
+	loggerpkg "prutya/go-api-template/internal/logger"
+	"prutya/go-api-template/internal/tasks"
+	"prutya/go-api-template/internal/tracing"
+)
+
+// Unwraps the payload of the task and restores what the enqueuer passed along
+// in the envelope: the request and user IDs go to the logger, so that the task
+// can be tied to the flow that enqueued it, and the task is processed in a span
+// that joins the trace of the enqueuer
+func envelopeMiddleware(h asynq.Handler) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		values, payload := tasks.UnwrapPayload(task.Payload())
+		metadata := tasks.MetadataFromMap(values)
+
+		logger := metadata.AddToLogger(loggerpkg.MustFromContext(ctx))
+		ctx = loggerpkg.NewContext(ctx, logger)
+
+		// The tasks enqueued while processing this one belong to the same flow
+		ctx = tasks.NewContextWithMetadata(ctx, tasks.Metadata{
+			RequestID: metadata.RequestID,
+			UserID:    metadata.UserID,
+			Origin:    "task " + task.Type(),
+		})
+
+		taskID, _ := asynq.GetTaskID(ctx)
+		queue, _ := asynq.GetQueueName(ctx)
+		retried, _ := asynq.GetRetryCount(ctx)
+
+		ctx, span := tracing.Start(
+			tracing.Extract(ctx, values),
+			"process "+task.Type(),
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(
+				semconv.MessagingSystemKey.String("asynq"),
+				semconv.MessagingOperationName("process"),
+				semconv.MessagingDestinationName(queue),
+				semconv.MessagingMessageID(taskID),
+				attribute.Int("task.retried", retried),
+			),
+		)
+		defer span.End()
+
+		err := h.ProcessTask(ctx, asynq.NewTask(task.Type(), payload))
+		tracing.RecordError(span, err)
+
+		return err
+	})
+}
@@ -0,0 +1,64 @@
+package tasks_server
+
+import (
+	"context"
+	"errors"
+
+	"github.com/hibiken/asynq"
+
+	loggerpkg "prutya/go-api-template/internal/logger"
+	"prutya/go-api-template/internal/metrics"
+	"prutya/go-api-template/internal/services/failed_task_service"
+	"prutya/go-api-template/internal/tasks"
+)
+
+// Counts every failed attempt and records the tasks that exhausted their
+// retries. Tasks that skip retries fail for an expected reason (e.g. the user
+// is gone) and are not recorded.
+func newErrorHandler(failedTaskService failed_task_service.FailedTaskService) asynq.ErrorHandler {
+	return asynq.ErrorHandlerFunc(func(ctx context.Context, task *asynq.Task, err error) {
+		metrics.TasksFailuresTotal.WithLabelValues(task.Type()).Inc()
+
+		if errors.Is(err, asynq.SkipRetry) || errors.Is(err, asynq.RevokeTask) {
+			return
+		}
+
+		retried, _ := asynq.GetRetryCount(ctx)
+		maxRetry, _ := asynq.GetMaxRetry(ctx)
+
+		if retried < maxRetry {
+			return
+		}
+
+		taskID, _ := asynq.GetTaskID(ctx)
+		queue, _ := asynq.GetQueueName(ctx)
+
+		// The failed task is recorded without its envelope and retried with the
+		// metadata of the retry
+		values, payload := tasks.UnwrapPayload(task.Payload())
+
+		logger := tasks.MetadataFromMap(values).AddToLogger(loggerpkg.MustFromContext(ctx))
+
+		logger.ErrorContext(
+			ctx,
+			"Task exhausted its retries",
+			"task_id", taskID,
+			"task_type", task.Type(),
+			"attempts", retried+1,
+			"error", err,
+		)
+
+		// The task context is done if the task timed out
+		if recordErr := failedTaskService.Record(context.WithoutCancel(ctx), &failed_task_service.Failure{
+			TaskID:   taskID,
+			TaskType: task.Type(),
+			Queue:    queue,
+			Payload:  payload,
+			Err:      err,
+			Attempts: retried + 1,
+			MaxRetry: maxRetry,
+		}); recordErr != nil {
+			logger.ErrorContext(ctx, "Failed to record failed task", "task_id", taskID, "error", recordErr)
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package tasks_server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+
+	loggerpkg "prutya/go-api-template/internal/logger"
+)
+
+type inFlightTask struct {
+	taskType  string
+	startedAt time.Time
+}
+
+// Keeps track of the tasks being processed so that shutdown can report them
+type inFlightTasks struct {
+	mutex sync.Mutex
+	tasks map[string]*inFlightTask
+}
+
+func newInFlightTasks() *inFlightTasks {
+	return &inFlightTasks{
+		tasks: make(map[string]*inFlightTask),
+	}
+}
+
+func (t *inFlightTasks) middleware(h asynq.Handler) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		taskID, _ := asynq.GetTaskID(ctx)
+
+		t.mutex.Lock()
+		t.tasks[taskID] = &inFlightTask{taskType: task.Type(), startedAt: time.Now()}
+		t.mutex.Unlock()
+
+		defer func() {
+			t.mutex.Lock()
+			delete(t.tasks, taskID)
+			t.mutex.Unlock()
+		}()
+
+		return h.ProcessTask(ctx, task)
+	})
+}
+
+func (t *inFlightTasks) log(ctx context.Context, logger *loggerpkg.Logger, message string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for taskID, task := range t.tasks {
+		logger.InfoContext(
+			ctx,
+			message,
+			"task_id", taskID,
+			"task_type", task.taskType,
+			"running_for", time.Since(task.startedAt),
+		)
+	}
+}
@@ -28,13 +28,16 @@ func (h *sendPasswordResetEmailTaskHandler) ProcessTask(ctx context.Context, tas
 		return err
 	}
 
-	if err := h.authenticationService.SendPasswordResetEmail(ctx, payload.UserID); err != nil {
+	if err := h.authenticationService.SendPasswordResetEmail(ctx, payload.UserID, payload.RequesterIP, payload.ExpiresAt); err != nil {
 		if skipped, wrappedErr := skipRetry(
 			err,
 			authentication_service.ErrUserNotFound,
 			authentication_service.ErrPasswordResetNotRequested,
 			authentication_service.ErrPasswordResetExpired,
+			authentication_service.ErrPasswordResetSuperseded,
 			transactional_email_service.ErrGlobalLimitReached,
+			transactional_email_service.ErrRecipientSuppressed,
+			transactional_email_service.ErrRateLimitReached,
 		); skipped {
 			return wrappedErr
 		}
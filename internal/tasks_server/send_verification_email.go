@@ -27,13 +27,16 @@ func (h *sendVerificationEmailTaskHandler) ProcessTask(ctx context.Context, task
 		return err
 	}
 
-	if err := h.authenticationService.SendVerificationEmail(ctx, payload.UserID); err != nil {
+	if err := h.authenticationService.SendVerificationEmail(ctx, payload.UserID, payload.RequesterIP, payload.ExpiresAt); err != nil {
 		if skipped, wrappedErr := skipRetry(
 			err,
 			authentication_service.ErrUserNotFound,
 			authentication_service.ErrEmailAlreadyVerified,
 			authentication_service.ErrEmailVerificationExpired,
+			authentication_service.ErrEmailVerificationSuperseded,
 			transactional_email_service.ErrGlobalLimitReached,
+			transactional_email_service.ErrRecipientSuppressed,
+			transactional_email_service.ErrRateLimitReached,
 		); skipped {
 			return wrappedErr
 		}
@@ -0,0 +1,33 @@
+package tasks_server
+
+import (
+	"context"
+
+	"github.com/hibiken/asynq"
+
+	"prutya/go-api-template/internal/services/email_blocklist_service"
+)
+
+type syncEmailBlocklistHandler struct {
+	emailBlocklistService email_blocklist_service.EmailBlocklistService
+}
+
+func newSyncEmailBlocklistHandler(
+	emailBlocklistService email_blocklist_service.EmailBlocklistService,
+) *syncEmailBlocklistHandler {
+	return &syncEmailBlocklistHandler{
+		emailBlocklistService: emailBlocklistService,
+	}
+}
+
+func (h *syncEmailBlocklistHandler) ProcessTask(ctx context.Context, task *asynq.Task) error {
+	if err := h.emailBlocklistService.Sync(ctx); err != nil {
+		if skipped, wrappedErr := skipRetry(err, email_blocklist_service.ErrSyncURLNotConfigured); skipped {
+			return wrappedErr
+		}
+
+		return err
+	}
+
+	return nil
+}
@@ -4,68 +4,123 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/hibiken/asynq"
 
 	loggerpkg "prutya/go-api-template/internal/logger"
+	"prutya/go-api-template/internal/metrics"
 	"prutya/go-api-template/internal/services/authentication_service"
+	"prutya/go-api-template/internal/services/email_blocklist_service"
+	"prutya/go-api-template/internal/services/failed_task_service"
 	"prutya/go-api-template/internal/services/transactional_email_service"
 	"prutya/go-api-template/internal/tasks"
 )
 
+var ErrQueueNotConfigured = errors.New("task queue is not configured")
+
 type Server interface {
+	// Processes tasks until the process receives SIGINT or SIGTERM, then waits
+	// for the tasks in flight up to the shutdown timeout
 	Run() error
 }
 
 type server struct {
-	asynqServer *asynq.Server
-	asynqMux    *asynq.ServeMux
+	asynqServer   *asynq.Server
+	asynqMux      *asynq.ServeMux
+	inFlightTasks *inFlightTasks
+	logger        *loggerpkg.Logger
 }
 
-// TODO: Support more configuration parameters
 func NewServer(
 	baseCtx context.Context,
 	redisAddr string,
-	redisPassword string,
+	redisPassword func() string,
+	concurrency int,
+	queues map[string]int,
+	strictPriority bool,
+	shutdownTimeout time.Duration,
 	authenticationService authentication_service.AuthenticationService,
 	transactionalEmailService transactional_email_service.TransactionalEmailService,
-) Server {
+	emailBlocklistService email_blocklist_service.EmailBlocklistService,
+	failedTaskService failed_task_service.FailedTaskService,
+) (Server, error) {
 	logger := loggerpkg.MustFromContext(baseCtx)
 
+	// Tasks routed to a queue the worker doesn't listen on would never run
+	for _, queue := range tasks.PolicyQueues() {
+		if queues[queue] <= 0 {
+			return nil, fmt.Errorf("%w: %s", ErrQueueNotConfigured, queue)
+		}
+	}
+
 	srv := asynq.NewServer(
-		asynq.RedisClientOpt{
-			Addr:     redisAddr,
-			Password: redisPassword,
-		},
+		tasks.NewRedisConnOpt(redisAddr, redisPassword),
 		asynq.Config{
-			Concurrency: 10,
-			BaseContext: func() context.Context { return baseCtx },
-			Logger:      tasks.NewSlogLoggerAdapter(logger),
+			Concurrency:     concurrency,
+			Queues:          queues,
+			StrictPriority:  strictPriority,
+			ShutdownTimeout: shutdownTimeout,
+			RetryDelayFunc:  tasks.RetryDelay,
+			ErrorHandler:    newErrorHandler(failedTaskService),
+			BaseContext:     func() context.Context { return baseCtx },
+			Logger:          tasks.NewSlogLoggerAdapter(logger),
 		},
 	)
 
+	inFlightTasks := newInFlightTasks()
+
 	mux := asynq.NewServeMux()
+	mux.Use(envelopeMiddleware)
+	mux.Use(inFlightTasks.middleware)
 	mux.Use(loggingMiddleware)
 	mux.Handle(tasks.TypeCleanupEmailSendAttempts, newCleanupEmailSendAttemptsHandler(transactionalEmailService))
+	mux.Handle(tasks.TypeCleanupExpiredTokens, newCleanupExpiredTokensHandler(authenticationService))
 	mux.Handle(tasks.TypeSendVerificationEmail, newSendVerificationEmailTaskHandler(authenticationService))
 	mux.Handle(tasks.TypeSendPasswordResetEmail, newSendPasswordResetEmailTaskHandler(authenticationService))
+	mux.Handle(tasks.TypeSyncEmailBlocklist, newSyncEmailBlocklistHandler(emailBlocklistService))
 
 	return &server{
-		asynqServer: srv,
-		asynqMux:    mux,
-	}
+		asynqServer:   srv,
+		asynqMux:      mux,
+		inFlightTasks: inFlightTasks,
+		logger:        logger,
+	}, nil
 }
 
 func (s *server) Run() error {
-	return s.asynqServer.Run(s.asynqMux)
+	if err := s.asynqServer.Start(s.asynqMux); err != nil {
+		return err
+	}
+
+	shutdownCh := make(chan os.Signal, 1)
+	signal.Notify(shutdownCh, syscall.SIGINT, syscall.SIGTERM)
+
+	<-shutdownCh
+
+	ctx := context.Background()
+
+	s.logger.InfoContext(ctx, "Worker is shutting down")
+	s.inFlightTasks.log(ctx, s.logger, "Waiting for task in flight")
+
+	// Stops pulling new tasks and waits for the ones in flight up to the
+	// shutdown timeout. The unfinished ones go back to their queues.
+	s.asynqServer.Shutdown()
+
+	s.inFlightTasks.log(ctx, s.logger, "Task did not finish in time and was requeued")
+	s.logger.InfoContext(ctx, "Worker stopped")
+
+	return nil
 }
 
 func loggingMiddleware(h asynq.Handler) asynq.Handler {
 	return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
-		logger := loggerpkg.MustFromContext(ctx)
+		logger := loggerpkg.MustFromContext(ctx).Component("tasks")
 
-		taskID := t.ResultWriter().TaskID()
+		taskID, _ := asynq.GetTaskID(ctx)
 
 		// Add task ID to the logger context for better traceability
 		logger = logger.With("task_id", taskID)
@@ -76,6 +131,9 @@ func loggingMiddleware(h asynq.Handler) asynq.Handler {
 
 		err := h.ProcessTask(ctx, t)
 
+		metrics.TasksProcessedTotal.WithLabelValues(t.Type(), taskResult(err)).Inc()
+		metrics.TasksProcessingDuration.WithLabelValues(t.Type()).Observe(time.Since(start).Seconds())
+
 		if err != nil {
 			logger.ErrorContext(ctx, "Failed to process task", "error", err, "duration", time.Since(start))
 
@@ -88,6 +146,17 @@ func loggingMiddleware(h asynq.Handler) asynq.Handler {
 	})
 }
 
+func taskResult(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, asynq.SkipRetry), errors.Is(err, asynq.RevokeTask):
+		return "skipped"
+	default:
+		return "failure"
+	}
+}
+
 func skipRetry(err error, skippedErrors ...error) (bool, error) {
 	return skipRetryWithError(err, asynq.SkipRetry, skippedErrors)
 }